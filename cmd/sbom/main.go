@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	iofs "io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/ubuntu-nix-sbom/internal/merge"
-	"github.com/ubuntu-nix-sbom/internal/nix"
-	"github.com/ubuntu-nix-sbom/internal/ubuntu"
+	"github.com/ubuntu-nix-sbom/internal/diff"
+	"github.com/ubuntu-nix-sbom/internal/enrich"
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/sign"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/stats"
+	"github.com/ubuntu-nix-sbom/internal/upload"
+	"github.com/ubuntu-nix-sbom/internal/version"
+	"github.com/ubuntu-nix-sbom/pkg/sbom"
 )
 
 func main() {
@@ -24,8 +39,24 @@ func main() {
 		ubuntuCommand(os.Args[2:])
 	case "nix":
 		nixCommand(os.Args[2:])
+	case "snap":
+		snapCommand(os.Args[2:])
+	case "flatpak":
+		flatpakCommand(os.Args[2:])
+	case "python":
+		pythonCommand(os.Args[2:])
 	case "combined":
 		combinedCommand(os.Args[2:])
+	case "merge":
+		mergeCommand(os.Args[2:])
+	case "stats":
+		statsCommand(os.Args[2:])
+	case "validate":
+		validateCommand(os.Args[2:])
+	case "diff":
+		diffCommand(os.Args[2:])
+	case "version", "--version":
+		fmt.Printf("sbom %s\n", version.String())
 	case "help", "--help", "-h":
 		printUsage()
 	default:
@@ -44,18 +75,71 @@ func printUsage() {
 	fmt.Println("Subcommands:")
 	fmt.Println("  ubuntu     Generate Ubuntu-only SBOM")
 	fmt.Println("  nix        Generate Nix-only SBOM")
+	fmt.Println("  snap       Generate SBOM of installed snaps")
+	fmt.Println("  flatpak    Generate SBOM of installed flatpaks")
+	fmt.Println("  python     Generate SBOM of Python distributions installed outside dpkg")
 	fmt.Println("  combined   Generate and merge both Ubuntu and Nix SBOMs")
+	fmt.Println("  merge      Merge existing SBOMs into one system SBOM")
+	fmt.Println("  stats      Aggregate package and license statistics across many SBOMs")
+	fmt.Println("  validate   Check that an SBOM is well-formed")
+	fmt.Println("  diff       Compare the packages of two SBOMs")
+	fmt.Println("  version    Print the version and commit of this build")
 	fmt.Println("  help       Show this help message")
 	fmt.Println()
 	fmt.Println("Run 'sbom <subcommand> --help' for subcommand-specific help")
 }
 
+// defaultChecksumAlgo is the --checksum-algo default
+const defaultChecksumAlgo = "sha1,sha256"
+
 func ubuntuCommand(args []string) {
 	fs := flag.NewFlagSet("ubuntu", flag.ExitOnError)
-	outputFile := fs.String("output", "ubuntu-sbom.spdx.json", "Output file path")
+	logOptions := addLogFlags(fs)
+	outputs := addOutputFlags(fs, "ubuntu-sbom.spdx.json")
 	includeFiles := fs.Bool("include-files", false, "Include file checksums for each package")
-	progress := fs.Bool("progress", true, "Show progress indicators")
-	noProgress := fs.Bool("no-progress", false, "Disable progress indicators")
+	progress := fs.Bool("progress", true, "Show a progress bar when stderr is a terminal")
+	noProgress := fs.Bool("no-progress", false, "Log progress lines instead of a progress bar")
+	fromSelections := fs.String("from-selections", "", "Build the SBOM from a 'dpkg --get-selections' capture instead of the local dpkg database")
+	strict := fs.Bool("strict", false, "Fail instead of warning when the generated document is internally inconsistent or dpkg-query fails")
+	usnDB := fs.String("usn-db", "", "Path to a local Ubuntu Security Notices database (database.json) for advisory references")
+	hashPaths := fs.String("hash-paths", "", "Comma-separated globs limiting which files are hashed with --include-files (e.g. '/usr/bin/*,/usr/sbin/*')")
+	downloadSizes := fs.Bool("download-size", false, "Annotate packages and the root with .deb download sizes from apt metadata")
+	resolveDownloadURLs := fs.Bool("resolve-download-urls", false, "Set each package's downloadLocation to its .deb URL from the local apt lists")
+	requireReadable := fs.Bool("require-readable", false, "Fail if any copyright or package file is unreadable due to permissions")
+	aptOrigins := fs.Bool("apt-origins", false, "Annotate packages with the apt repository origin they were installed from")
+	thirdPartyOnly := fs.Bool("third-party-only", false, "Only include packages not from the official distribution archive")
+	since := fs.String("since", "", "Only include packages installed or upgraded at or after this RFC3339 time (e.g. 2024-05-01T00:00:00Z)")
+	strictSince := fs.Bool("strict-since", false, "With --since, also exclude packages whose install time cannot be determined")
+	includeConfigFiles := fs.Bool("include-config-files", false, "Include removed packages whose configuration files remain (dpkg state config-files)")
+	kernelModules := fs.Bool("kernel-modules", false, "Include the running kernel's loaded modules with their dependencies and firmware")
+	allArchAs := fs.String("all-arch-as", "all", "Purl arch qualifier for architecture-independent packages: all or host")
+	skippedReport := fs.String("skipped-report", "", "Write a JSON list of enumerated packages left out of the SBOM, with reasons")
+	validFor := fs.String("valid-for", "", "Record an expiry this long after creation (e.g. 720h or 30d)")
+	noDescription := fs.Bool("no-description", false, "Omit package descriptions from the output")
+	debugLinks := fs.Bool("debug-links", false, "Relate packages to installed -dbgsym packages by ELF build-id (slow)")
+	swid := fs.Bool("swid", false, "Attach a SWID tag ID external reference to each package")
+	maxOpenFiles := fs.Int("max-open-files", 64, "Maximum number of files held open at once while reading licenses and hashing")
+	maxCopyrightLength := fs.Int("max-copyright-length", 200, "Truncate copyright text to this many characters (0 for the full text)")
+	licenseIgnore := fs.String("license-ignore", "", "File of raw License: values (or 're:' regexes) that always map to NOASSERTION")
+	supplement := fs.String("supplement", "", "Hand-maintained SPDX document whose packages are added as manually-declared software")
+	jobs := fs.Int("jobs", runtime.NumCPU(), "Number of workers reading copyright files and hashing with --include-files")
+	emitFiles := fs.Bool("emit-files", false, "With --include-files, add an SPDX File element with checksums for every hashed file (large output)")
+	checksumAlgo := fs.String("checksum-algo", defaultChecksumAlgo, "With --emit-files, comma-separated file checksum algorithms: sha1, sha256, sha512 (SHA1 is always included)")
+	dryRun := fs.Bool("dry-run", false, "Print how many packages (and with --include-files, files) would be processed, without generating or writing anything")
+	var includePackages, excludePackages globList
+	fs.Var(&includePackages, "include", "Only include packages whose name matches this glob (repeatable)")
+	fs.Var(&excludePackages, "exclude", "Exclude packages whose name matches this glob, overriding --include (repeatable)")
+	var architectures stringList
+	fs.Var(&architectures, "arch", "Only include packages of this dpkg architecture, plus Architecture: all packages (repeatable)")
+	dpkgRoot := fs.String("dpkg-root", "", "Read the dpkg database and copyright files under this root (e.g. a mounted image) instead of querying the host")
+	image := fs.String("image", "", "Describe a container image from its unpacked root directory or an exported rootfs tar (.tar, .tar.gz) instead of the host")
+	reproducible := fs.Bool("reproducible", false, "Produce identical output for identical packages: fixed creation time (SOURCE_DATE_EPOCH), content-derived namespace and SPDXIDs")
+	osv := addOSVFlags(fs)
+	summary := addSummaryFlags(fs)
+	licenseGate := addLicenseGateFlags(fs)
+	trust := addTrustFlags(fs)
+	upload := addUploadFlags(fs)
+	signing := addSignFlags(fs)
 
 	fs.Usage = func() {
 		fmt.Println("Usage: sbom ubuntu [flags]")
@@ -69,26 +153,112 @@ func ubuntuCommand(args []string) {
 	if err := fs.Parse(args); err != nil {
 		os.Exit(1)
 	}
+	logOptions.apply()
 
 	showProgress := *progress && !*noProgress
+	outputs.check()
+	licenseGate.check()
+	trust.check()
+	checkStdoutOutput(*outputs.output, upload, signing)
+	if *dpkgRoot != "" && *fromSelections != "" {
+		logging.Fatalf("--dpkg-root and --from-selections cannot be combined")
+	}
+	if *image != "" && (*dpkgRoot != "" || *fromSelections != "") {
+		logging.Fatalf("--image cannot be combined with --dpkg-root or --from-selections")
+	}
 
-	generator := ubuntu.NewGenerator(*includeFiles, showProgress)
-
-	doc, err := generator.Generate()
+	if *allArchAs != "all" && *allArchAs != "host" {
+		logging.Fatalf("Invalid --all-arch-as %q: expected all or host", *allArchAs)
+	}
+	if *emitFiles && !*includeFiles {
+		logging.Fatalf("--emit-files requires --include-files")
+	}
+	checksumAlgorithms, err := spdx.ParseChecksumAlgorithms(*checksumAlgo)
 	if err != nil {
-		log.Fatalf("Failed to generate SBOM: %v", err)
+		logging.Fatalf("Invalid --checksum-algo: %v", err)
+	}
+	if *checksumAlgo != defaultChecksumAlgo && !*emitFiles {
+		logging.Fatalf("--checksum-algo requires --emit-files")
+	}
+
+	options := sbom.Options{
+		IncludeFiles:           *includeFiles,
+		ShowProgress:           showProgress,
+		HashPaths:              parseGlobs(*hashPaths),
+		EmitFiles:              *emitFiles,
+		ChecksumAlgorithms:     checksumAlgorithms,
+		Jobs:                   *jobs,
+		MaxOpenFiles:           *maxOpenFiles,
+		DpkgRoot:               *dpkgRoot,
+		Image:                  *image,
+		SelectionsFile:         *fromSelections,
+		IncludePackages:        includePackages,
+		ExcludePackages:        excludePackages,
+		Architectures:          architectures,
+		ThirdPartyOnly:         *thirdPartyOnly,
+		Since:                  sinceOption(*since, *strictSince),
+		StrictSince:            *strictSince,
+		IncludeConfigFiles:     *includeConfigFiles,
+		USNDatabase:            *usnDB,
+		DownloadSizes:          *downloadSizes,
+		ResolveDownloadURLs:    *resolveDownloadURLs,
+		AptOrigins:             *aptOrigins,
+		KernelModules:          *kernelModules,
+		DebugLinks:             *debugLinks,
+		SWID:                   *swid,
+		AllArchAs:              *allArchAs,
+		LicenseIgnoreFile:      *licenseIgnore,
+		TrustedMaintainersFile: *trust.file,
+		MaxCopyrightLength:     copyrightLengthOption(*maxCopyrightLength),
+		RequireReadable:        *requireReadable,
+		Strict:                 *strict,
+		Reproducible:           *reproducible,
+		Supplement:             *supplement,
+		SkippedReport:          *skippedReport,
 	}
 
-	if err := generator.Save(doc, *outputFile); err != nil {
-		log.Fatalf("Failed to save SBOM: %v", err)
+	if *dryRun {
+		estimate, err := sbom.EstimateUbuntu(options)
+		if err != nil {
+			logging.Fatalf("Failed to enumerate packages: %v", err)
+		}
+		fmt.Printf("Packages: %d (%d skipped)\n", estimate.Packages, estimate.Skipped)
+		if *includeFiles {
+			fmt.Printf("Files to hash: %d\n", estimate.Files)
+		}
+		return
 	}
 
-	fmt.Printf("Ubuntu SBOM generated successfully: %s\n", *outputFile)
+	doc, err := sbom.GenerateUbuntu(options)
+	if err != nil {
+		logging.Fatalf("Failed to generate SBOM: %v", err)
+	}
+	checkConsistency(doc, *strict)
+	osv.run(doc)
+	doc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
+	setValidFor(doc, *validFor)
+	if *noDescription {
+		stripDescriptions(doc)
+	}
+
+	paths := outputs.save(doc, signing)
+
+	logging.Infof("Ubuntu SBOM generated successfully: %s", strings.Join(paths, ", "))
+	summary.run(doc)
+	licenseGate.run(doc)
+	trust.run(doc)
+
+	upload.run(paths[0], showProgress)
 }
 
 func nixCommand(args []string) {
 	fs := flag.NewFlagSet("nix", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
 	outputFile := fs.String("output", "nix-sbom.spdx.json", "Output file path")
+	sbomnixPath := fs.String("sbomnix-path", "sbomnix", "sbomnix executable to run, looked up in PATH unless it contains a slash")
+	retries := fs.Int("retries", 2, "Rerun sbomnix this many times when it fails with a transient error (e.g. Nix daemon unreachable)")
+	timeout := fs.Duration("timeout", 0, "Kill sbomnix when a run takes longer than this (0 for no limit)")
+	signing := addSignFlags(fs)
 
 	fs.Usage = func() {
 		fmt.Println("Usage: sbom nix <derivation-path> [flags]")
@@ -105,6 +275,7 @@ func nixCommand(args []string) {
 	if err := fs.Parse(args); err != nil {
 		os.Exit(1)
 	}
+	logOptions.apply()
 
 	if fs.NArg() < 1 {
 		fmt.Println("Error: derivation path required")
@@ -114,24 +285,173 @@ func nixCommand(args []string) {
 	}
 
 	derivationPath := fs.Arg(0)
+	checkStdoutOutput(*outputFile, nil, signing)
 
-	// Use sbomnix from PATH
-	wrapper := nix.NewWrapper("sbomnix")
+	if err := sbom.WriteNix(derivationPath, *outputFile, sbom.NixOptions{SbomnixPath: *sbomnixPath, Retries: *retries, Timeout: *timeout}); err != nil {
+		logging.Fatalf("Failed to generate Nix SBOM: %v", err)
+	}
+	signing.run(*outputFile)
+
+	logging.Infof("Nix SBOM generated successfully: %s", *outputFile)
+}
 
-	if err := wrapper.Generate(derivationPath, *outputFile); err != nil {
-		log.Fatalf("Failed to generate Nix SBOM: %v", err)
+func snapCommand(args []string) {
+	fs := flag.NewFlagSet("snap", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
+	outputs := addOutputFlags(fs, "snap-sbom.spdx.json")
+	snapPath := fs.String("snap-path", "snap", "snap executable to run, looked up in PATH unless it contains a slash")
+	reproducible := fs.Bool("reproducible", false, "Fixed creation time (SOURCE_DATE_EPOCH) and content-derived namespace")
+	summary := addSummaryFlags(fs)
+	signing := addSignFlags(fs)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: sbom snap [flags]")
+		fmt.Println()
+		fmt.Println("Generate SBOM of the snaps installed on this system")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
 	}
+	logOptions.apply()
+
+	outputs.check()
+	checkStdoutOutput(*outputs.output, nil, signing)
 
-	fmt.Printf("Nix SBOM generated successfully: %s\n", *outputFile)
+	doc, err := sbom.GenerateSnap(sbom.SnapOptions{SnapPath: *snapPath, Reproducible: *reproducible})
+	if err != nil {
+		logging.Fatalf("Failed to generate snap SBOM: %v", err)
+	}
+	doc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
+
+	paths := outputs.save(doc, signing)
+
+	logging.Infof("Snap SBOM generated successfully: %s", strings.Join(paths, ", "))
+	summary.run(doc)
+}
+
+func flatpakCommand(args []string) {
+	fs := flag.NewFlagSet("flatpak", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
+	outputs := addOutputFlags(fs, "flatpak-sbom.spdx.json")
+	flatpakPath := fs.String("flatpak-path", "flatpak", "flatpak executable to run, looked up in PATH unless it contains a slash")
+	includeRuntimes := fs.Bool("include-runtimes", false, "Also include installed runtimes, not only applications")
+	reproducible := fs.Bool("reproducible", false, "Fixed creation time (SOURCE_DATE_EPOCH) and content-derived namespace")
+	summary := addSummaryFlags(fs)
+	signing := addSignFlags(fs)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: sbom flatpak [flags]")
+		fmt.Println()
+		fmt.Println("Generate SBOM of the flatpaks installed on this system")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	logOptions.apply()
+
+	outputs.check()
+	checkStdoutOutput(*outputs.output, nil, signing)
+
+	doc, err := sbom.GenerateFlatpak(sbom.FlatpakOptions{
+		FlatpakPath:     *flatpakPath,
+		IncludeRuntimes: *includeRuntimes,
+		Reproducible:    *reproducible,
+	})
+	if err != nil {
+		logging.Fatalf("Failed to generate flatpak SBOM: %v", err)
+	}
+	doc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
+
+	paths := outputs.save(doc, signing)
+
+	logging.Infof("Flatpak SBOM generated successfully: %s", strings.Join(paths, ", "))
+	summary.run(doc)
+}
+
+func pythonCommand(args []string) {
+	fs := flag.NewFlagSet("python", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
+	outputs := addOutputFlags(fs, "python-sbom.spdx.json")
+	var pythonPaths stringList
+	fs.Var(&pythonPaths, "python-path", "site-packages directory to scan (repeatable, default: from 'python3 -m site')")
+	reproducible := fs.Bool("reproducible", false, "Fixed creation time (SOURCE_DATE_EPOCH) and content-derived namespace")
+	summary := addSummaryFlags(fs)
+	signing := addSignFlags(fs)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: sbom python [flags]")
+		fmt.Println()
+		fmt.Println("Generate SBOM of the Python distributions installed in site-packages")
+		fmt.Println("directories, such as by pip")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	logOptions.apply()
+
+	outputs.check()
+	checkStdoutOutput(*outputs.output, nil, signing)
+
+	doc, err := sbom.GeneratePython(sbom.PythonOptions{Paths: pythonPaths, Reproducible: *reproducible})
+	if err != nil {
+		logging.Fatalf("Failed to generate Python SBOM: %v", err)
+	}
+	doc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
+
+	paths := outputs.save(doc, signing)
+
+	logging.Infof("Python SBOM generated successfully: %s", strings.Join(paths, ", "))
+	summary.run(doc)
 }
 
 func combinedCommand(args []string) {
 	fs := flag.NewFlagSet("combined", flag.ExitOnError)
-	nixTarget := fs.String("nix-target", "", "Path to Nix derivation (required)")
-	outputFile := fs.String("output", "merged-sbom.spdx.json", "Output file path")
+	logOptions := addLogFlags(fs)
+	var nixTargets stringList
+	fs.Var(&nixTargets, "nix-target", "Path to Nix derivation (required, repeatable to merge several closures)")
+	sbomnixPath := fs.String("sbomnix-path", "sbomnix", "sbomnix executable to run, looked up in PATH unless it contains a slash")
+	retries := fs.Int("retries", 2, "Rerun sbomnix this many times when it fails with a transient error (e.g. Nix daemon unreachable)")
+	timeout := fs.Duration("timeout", 0, "Kill sbomnix when a run takes longer than this (0 for no limit)")
+	snaps := fs.Bool("snaps", true, "Include installed snaps (skipped with a warning when snap is not installed)")
+	snapPath := fs.String("snap-path", "snap", "snap executable to run, looked up in PATH unless it contains a slash")
+	flatpaks := fs.Bool("flatpaks", true, "Include installed flatpaks (skipped with a warning when flatpak is not installed)")
+	flatpakPath := fs.String("flatpak-path", "flatpak", "flatpak executable to run, looked up in PATH unless it contains a slash")
+	includeRuntimes := fs.Bool("include-runtimes", false, "With --flatpaks, also include installed flatpak runtimes")
+	includePython := fs.Bool("python", false, "Include Python distributions installed outside dpkg, e.g. by pip")
+	var pythonPaths stringList
+	fs.Var(&pythonPaths, "python-path", "With --python, site-packages directory to scan (repeatable, default: from 'python3 -m site')")
+	var extras stringList
+	fs.Var(&extras, "extra", "SPDX JSON document from another tool (e.g. syft) to merge in (repeatable)")
+	outputs := addOutputFlags(fs, "merged-sbom.spdx.json")
 	includeFiles := fs.Bool("include-files", false, "Include file checksums for Ubuntu packages")
-	progress := fs.Bool("progress", true, "Show progress indicators")
-	noProgress := fs.Bool("no-progress", false, "Disable progress indicators")
+	hashPaths := fs.String("hash-paths", "", "Comma-separated globs limiting which files are hashed with --include-files (e.g. '/usr/bin/*,/usr/sbin/*')")
+	jobs := fs.Int("jobs", runtime.NumCPU(), "Number of workers reading copyright files and hashing with --include-files")
+	supplement := fs.String("supplement", "", "Hand-maintained SPDX document whose packages are added as manually-declared software")
+	progress := fs.Bool("progress", true, "Show a progress bar when stderr is a terminal")
+	noProgress := fs.Bool("no-progress", false, "Log progress lines instead of a progress bar")
+	strict := fs.Bool("strict", false, "Fail instead of warning when a generated document is internally inconsistent or dpkg-query fails")
+	validFor := fs.String("valid-for", "", "Record an expiry this long after creation (e.g. 720h or 30d)")
+	noDescription := fs.Bool("no-description", false, "Omit package descriptions from the output")
+	dedupe := fs.String("dedupe", sbom.DedupeLink, "Packages in both sources: link (relate as equivalent), drop (keep the Ubuntu copy) or off")
+	keepIntermediates := fs.String("keep-intermediates", "", "Also write each generated source SBOM (ubuntu-sbom.spdx.json, nix-sbom.spdx.json, ...) to this directory")
+	reproducible := fs.Bool("reproducible", false, "Produce identical output for identical packages: fixed creation time (SOURCE_DATE_EPOCH), content-derived namespace and SPDXIDs")
+	osv := addOSVFlags(fs)
+	summary := addSummaryFlags(fs)
+	licenseGate := addLicenseGateFlags(fs)
+	upload := addUploadFlags(fs)
+	signing := addSignFlags(fs)
 
 	fs.Usage = func() {
 		fmt.Println("Usage: sbom combined --nix-target <derivation> [flags]")
@@ -145,55 +465,859 @@ func combinedCommand(args []string) {
 	if err := fs.Parse(args); err != nil {
 		os.Exit(1)
 	}
+	logOptions.apply()
 
-	if *nixTarget == "" {
+	if len(nixTargets) == 0 {
 		fmt.Println("Error: --nix-target is required")
 		fmt.Println()
 		fs.Usage()
 		os.Exit(1)
 	}
+	// Fail before the generators run rather than at the merge
+	for _, path := range extras {
+		if _, err := os.Stat(path); err != nil {
+			logging.Fatalf("Invalid --extra: %v", err)
+		}
+	}
 
 	showProgress := *progress && !*noProgress
+	outputs.check()
+	licenseGate.check()
+	checkStdoutOutput(*outputs.output, upload, signing)
+	if *dedupe != sbom.DedupeLink && *dedupe != sbom.DedupeDrop && *dedupe != sbom.DedupeOff {
+		logging.Fatalf("Invalid --dedupe %q: expected link, drop or off", *dedupe)
+	}
+	if *keepIntermediates != "" {
+		if err := os.MkdirAll(*keepIntermediates, 0o755); err != nil {
+			logging.Fatalf("Invalid --keep-intermediates: %v", err)
+		}
+	}
 
-	// Create temporary directory
-	tmpDir, err := os.MkdirTemp("", "sbom-combined-*")
+	// Generate Ubuntu SBOM
+	logging.Infof("Generating Ubuntu SBOM...")
+	ubuntuDoc, err := sbom.GenerateUbuntu(sbom.Options{
+		IncludeFiles: *includeFiles,
+		ShowProgress: showProgress,
+		HashPaths:    parseGlobs(*hashPaths),
+		Jobs:         *jobs,
+		Reproducible: *reproducible,
+		Supplement:   *supplement,
+		Strict:       *strict,
+	})
 	if err != nil {
-		log.Fatalf("Failed to create temp directory: %v", err)
+		logging.Fatalf("Failed to generate Ubuntu SBOM: %v", err)
 	}
-	defer os.RemoveAll(tmpDir)
+	checkConsistency(ubuntuDoc, *strict)
+	ubuntuDoc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
+	keepIntermediate(*keepIntermediates, "ubuntu-sbom.spdx.json", ubuntuDoc)
 
-	ubuntuSBOM := fmt.Sprintf("%s/ubuntu-sbom.spdx.json", tmpDir)
-	nixSBOM := fmt.Sprintf("%s/nix-sbom.spdx.json", tmpDir)
+	// Generate one Nix SBOM per target
+	docs := []*sbom.Document{ubuntuDoc}
+	nixOptions := sbom.NixOptions{SbomnixPath: *sbomnixPath, Retries: *retries, Timeout: *timeout}
+	for i, target := range nixTargets {
+		logging.Infof("Generating Nix SBOM for %s...", target)
+		nixDoc, err := sbom.GenerateNixWithOptions(target, nixOptions)
+		if err != nil {
+			logging.Fatalf("Failed to generate Nix SBOM: %v", err)
+		}
+		name := "nix-sbom.spdx.json"
+		if i > 0 {
+			name = fmt.Sprintf("nix-sbom-%d.spdx.json", i+1)
+		}
+		keepIntermediate(*keepIntermediates, name, nixDoc)
+		docs = append(docs, nixDoc)
+	}
 
-	// Generate Ubuntu SBOM
-	fmt.Println("Generating Ubuntu SBOM...")
-	ubuntuGen := ubuntu.NewGenerator(*includeFiles, showProgress)
-	ubuntuDoc, err := ubuntuGen.Generate()
-	if err != nil {
-		log.Fatalf("Failed to generate Ubuntu SBOM: %v", err)
+	if *snaps {
+		logging.Infof("Generating snap SBOM...")
+		snapDoc, err := sbom.GenerateSnap(sbom.SnapOptions{SnapPath: *snapPath, Reproducible: *reproducible})
+		if err != nil {
+			logging.Fatalf("Failed to generate snap SBOM: %v", err)
+		}
+		keepIntermediate(*keepIntermediates, "snap-sbom.spdx.json", snapDoc)
+		// Only the root: no snaps, nothing worth a source of its own
+		if len(snapDoc.Packages) > 1 {
+			docs = append(docs, snapDoc)
+		}
 	}
-	if err := ubuntuGen.Save(ubuntuDoc, ubuntuSBOM); err != nil {
-		log.Fatalf("Failed to save Ubuntu SBOM: %v", err)
+
+	if *flatpaks {
+		logging.Infof("Generating flatpak SBOM...")
+		flatpakDoc, err := sbom.GenerateFlatpak(sbom.FlatpakOptions{
+			FlatpakPath:     *flatpakPath,
+			IncludeRuntimes: *includeRuntimes,
+			Reproducible:    *reproducible,
+		})
+		if err != nil {
+			logging.Fatalf("Failed to generate flatpak SBOM: %v", err)
+		}
+		keepIntermediate(*keepIntermediates, "flatpak-sbom.spdx.json", flatpakDoc)
+		if len(flatpakDoc.Packages) > 1 {
+			docs = append(docs, flatpakDoc)
+		}
 	}
 
-	// Generate Nix SBOM
-	fmt.Println("Generating Nix SBOM...")
-	nixWrapper := nix.NewWrapper("sbomnix")
-	if err := nixWrapper.Generate(*nixTarget, nixSBOM); err != nil {
-		log.Fatalf("Failed to generate Nix SBOM: %v", err)
+	if *includePython {
+		logging.Infof("Generating Python SBOM...")
+		pythonDoc, err := sbom.GeneratePython(sbom.PythonOptions{Paths: pythonPaths, Reproducible: *reproducible})
+		if err != nil {
+			logging.Fatalf("Failed to generate Python SBOM: %v", err)
+		}
+		keepIntermediate(*keepIntermediates, "python-sbom.spdx.json", pythonDoc)
+		if len(pythonDoc.Packages) > 1 {
+			docs = append(docs, pythonDoc)
+		}
 	}
 
 	// Merge SBOMs
-	fmt.Println("Merging SBOMs...")
-	merger := merge.NewMerger()
-	mergedDoc, err := merger.Merge(ubuntuSBOM, nixSBOM)
+	logging.Infof("Merging SBOMs...")
+	mergeOptions := sbom.MergeOptions{Dedupe: *dedupe, Reproducible: *reproducible, Extras: extras}
+	mergedDoc, err := sbom.MergeWithOptions(mergeOptions, docs...)
 	if err != nil {
-		log.Fatalf("Failed to merge SBOMs: %v", err)
+		logging.Fatalf("Failed to merge SBOMs: %v", err)
+	}
+	checkConsistency(mergedDoc, *strict)
+	osv.run(mergedDoc)
+	mergedDoc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
+	setValidFor(mergedDoc, *validFor)
+	if *noDescription {
+		stripDescriptions(mergedDoc)
 	}
 
-	if err := merger.Save(mergedDoc, *outputFile); err != nil {
-		log.Fatalf("Failed to save merged SBOM: %v", err)
+	paths := outputs.save(mergedDoc, signing)
+
+	logging.Infof("Merged SBOM generated successfully: %s", strings.Join(paths, ", "))
+	summary.run(mergedDoc)
+	licenseGate.run(mergedDoc)
+
+	upload.run(paths[0], showProgress)
+}
+
+// keepIntermediate writes a source SBOM generated by combined to dir,
+// under the name its own subcommand would give it, when
+// --keep-intermediates was given. It is written before merging, exactly
+// as generated.
+func keepIntermediate(dir, name string, doc *sbom.Document) {
+	if dir == "" {
+		return
 	}
+	path := filepath.Join(dir, name)
+	if err := sbom.Save(doc, path, sbom.FormatSPDX); err != nil {
+		logging.Fatalf("Failed to write intermediate SBOM: %v", err)
+	}
+	logging.Infof("Intermediate SBOM written: %s", path)
+}
+
+func mergeCommand(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
+	var inputs stringList
+	fs.Var(&inputs, "input", "SPDX document to merge (repeatable)")
+	var extras stringList
+	fs.Var(&extras, "extra", "SPDX JSON document from another tool (e.g. syft) to merge in (repeatable)")
+	ubuntuInput := fs.String("ubuntu", "", "Ubuntu SPDX document, merged before any --input")
+	nixInput := fs.String("nix", "", "Nix SPDX document, merged after --ubuntu and before any --input")
+	outputs := addOutputFlags(fs, "merged-sbom.spdx.json")
+	strict := fs.Bool("strict", false, "Fail instead of warning when the merged document is internally inconsistent")
+	dedupe := fs.String("dedupe", sbom.DedupeLink, "Packages in both Ubuntu and another source: link (relate as equivalent), drop (keep the Ubuntu copy) or off")
+	reproducible := fs.Bool("reproducible", false, "Fixed creation time (SOURCE_DATE_EPOCH) and content-derived namespace")
+	licenseGate := addLicenseGateFlags(fs)
+	signing := addSignFlags(fs)
 
-	fmt.Printf("Merged SBOM generated successfully: %s\n", *outputFile)
+	fs.Usage = func() {
+		fmt.Println("Usage: sbom merge [--ubuntu <file>] [--nix <file>] [--input <file>]... [flags]")
+		fmt.Println()
+		fmt.Println("Merge existing SBOMs, e.g. a host's Ubuntu SBOM and the Nix closures of")
+		fmt.Println("several services, under a single system root")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	logOptions.apply()
+
+	var paths []string
+	for _, path := range []string{*ubuntuInput, *nixInput} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	paths = append(paths, inputs...)
+	if len(paths)+len(extras) < 2 {
+		fmt.Println("Error: at least two documents required")
+		fmt.Println()
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	outputs.check()
+	licenseGate.check()
+	checkStdoutOutput(*outputs.output, nil, signing)
+	if *dedupe != sbom.DedupeLink && *dedupe != sbom.DedupeDrop && *dedupe != sbom.DedupeOff {
+		logging.Fatalf("Invalid --dedupe %q: expected link, drop or off", *dedupe)
+	}
+
+	mergeOptions := sbom.MergeOptions{Dedupe: *dedupe, Reproducible: *reproducible, Extras: extras}
+	mergedDoc, err := sbom.MergeFiles(mergeOptions, paths...)
+	if err != nil {
+		logging.Fatalf("Failed to merge SBOMs: %v", err)
+	}
+	checkConsistency(mergedDoc, *strict)
+	mergedDoc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
+
+	written := outputs.save(mergedDoc, signing)
+
+	logging.Infof("Merged SBOM generated successfully: %s", strings.Join(written, ", "))
+	licenseGate.run(mergedDoc)
+}
+
+// checkStdoutOutput rejects options that need an output file when the
+// document is written to stdout. Log messages go to stderr, so they cannot
+// corrupt the document stream.
+func checkStdoutOutput(outputPath string, upload *uploadFlags, signing *signFlags) {
+	if outputPath != spdx.StdoutPath {
+		return
+	}
+	if upload != nil && *upload.url != "" {
+		logging.Fatalf("--upload-url needs an output file, not stdout")
+	}
+	if signing != nil && *signing.key != "" {
+		logging.Fatalf("--sign-key needs an output file, not stdout")
+	}
+}
+
+// logFlags holds the diagnostics options shared by every subcommand
+type logFlags struct {
+	quiet *bool
+	json  *bool
+}
+
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	return &logFlags{
+		quiet: fs.Bool("quiet", false, "Only log errors"),
+		json:  fs.Bool("log-json", false, "Log one JSON object per line (time, level, msg) to stderr"),
+	}
+}
+
+// apply configures the logger; call it right after parsing flags
+func (f *logFlags) apply() {
+	logging.SetQuiet(*f.quiet)
+	logging.SetJSON(*f.json)
+}
+
+func validateCommand(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
+	schema := fs.Bool("schema", false, "Also check the document against the SPDX 2.3 JSON schema")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: sbom validate [flags] <file>")
+		fmt.Println()
+		fmt.Println("Check that an SPDX document is well-formed: required fields, unique and")
+		fmt.Println("well-formed SPDXIDs, resolvable relationships and a single DESCRIBES")
+		fmt.Println()
+		fmt.Println("Arguments:")
+		fmt.Println("  file    SPDX JSON document, optionally gzip or zstd compressed (required)")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	logOptions.apply()
+
+	if fs.NArg() < 1 {
+		fmt.Println("Error: file required")
+		fmt.Println()
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	path := fs.Arg(0)
+	invalid := func(violations []string) {
+		fmt.Printf("%s is invalid (%d violations):\n", path, len(violations))
+		for _, v := range violations {
+			fmt.Printf("  - %s\n", v)
+		}
+		os.Exit(1)
+	}
+
+	// The schema is checked first, as it explains documents with wrongly
+	// typed fields that cannot be loaded at all
+	var schemaViolations []string
+	if *schema {
+		data, err := spdx.ReadDocumentBytes(path)
+		if err != nil {
+			logging.Fatalf("Failed to load SBOM: %v", err)
+		}
+		found, err := spdx.ValidateSchema(data)
+		if err != nil {
+			logging.Fatalf("Schema validation failed: %v", err)
+		}
+		for _, v := range found {
+			schemaViolations = append(schemaViolations, "schema: "+v)
+		}
+	}
+
+	doc, err := spdx.Load(path)
+	if err != nil {
+		// Content that is not a readable SPDX document is invalid rather
+		// than an error of the command
+		var decodeErr *spdx.DecodeError
+		if errors.As(err, &decodeErr) {
+			invalid(append([]string{decodeErr.Error()}, schemaViolations...))
+		}
+		if len(schemaViolations) > 0 {
+			invalid(schemaViolations)
+		}
+		logging.Fatalf("Failed to load SBOM: %v", err)
+	}
+
+	notes, _ := spdx.CheckDuplicates(doc)
+	for _, note := range notes {
+		logging.Infof("Note: %s", note)
+	}
+	if err := spdx.CheckExpiry(doc, time.Now()); err != nil {
+		logging.Warnf("%v", err)
+	}
+
+	violations := append(spdx.Validate(doc), schemaViolations...)
+	if len(violations) > 0 {
+		invalid(violations)
+	}
+
+	fmt.Printf("%s is valid (%d packages, %d relationships)\n", path, len(doc.Packages), len(doc.Relationships))
+}
+
+func diffCommand(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
+	jsonOutput := fs.Bool("json", false, "Write the differences as JSON")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: sbom diff [flags] <old> <new>")
+		fmt.Println()
+		fmt.Println("Report packages added, removed or changed in version between two SBOMs,")
+		fmt.Println("matched by name. Exits 1 when they differ.")
+		fmt.Println()
+		fmt.Println("Arguments:")
+		fmt.Println("  old    Earlier SPDX JSON document (required)")
+		fmt.Println("  new    Later SPDX JSON document (required)")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	logOptions.apply()
+
+	if fs.NArg() < 2 {
+		fmt.Println("Error: two files required")
+		fmt.Println()
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	oldDoc, err := spdx.Load(fs.Arg(0))
+	if err != nil {
+		logging.Fatalf("Failed to load SBOM: %v", err)
+	}
+	newDoc, err := spdx.Load(fs.Arg(1))
+	if err != nil {
+		logging.Fatalf("Failed to load SBOM: %v", err)
+	}
+
+	report := diff.Compare(oldDoc, newDoc)
+	if *jsonOutput {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		logging.Fatalf("Failed to write diff: %v", err)
+	}
+
+	if report.HasChanges() {
+		os.Exit(1)
+	}
+}
+
+func statsCommand(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	logOptions := addLogFlags(fs)
+	format := fs.String("format", "text", "Report format: text, json, or csv")
+	top := fs.Int("top", 20, "Number of entries to show per section (0 for all)")
+	outputFile := fs.String("output", "", "Write the report to a file instead of stdout")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: sbom stats [flags] <directory>")
+		fmt.Println()
+		fmt.Println("Aggregate package, version, and license statistics across a directory of SBOMs")
+		fmt.Println()
+		fmt.Println("Arguments:")
+		fmt.Println("  directory    Directory containing one SPDX JSON document per host (required)")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	logOptions.apply()
+
+	if fs.NArg() < 1 {
+		fmt.Println("Error: directory required")
+		fmt.Println()
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *format != "text" && *format != "json" && *format != "csv" {
+		logging.Fatalf("Unknown format %q: expected text, json, or csv", *format)
+	}
+
+	aggregator := stats.NewAggregator()
+
+	// Documents are loaded and folded in one at a time to keep memory
+	// bounded on large fleets
+	err := filepath.WalkDir(fs.Arg(0), func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isDocumentFile(path) {
+			return nil
+		}
+
+		doc, err := spdx.Load(path)
+		if err != nil {
+			logging.Warnf("skipping %s: %v", path, err)
+			return nil
+		}
+		if err := spdx.CheckExpiry(doc, time.Now()); err != nil {
+			logging.Warnf("%s: %v", path, err)
+		}
+		aggregator.Add(doc)
+		return nil
+	})
+	if err != nil {
+		logging.Fatalf("Failed to read SBOM directory: %v", err)
+	}
+
+	report := aggregator.Report(*top)
+
+	write := func(w io.Writer) error {
+		switch *format {
+		case "json":
+			return report.WriteJSON(w)
+		case "csv":
+			return report.WriteCSV(w)
+		default:
+			return report.WriteText(w)
+		}
+	}
+
+	if *outputFile == "" {
+		if err := write(os.Stdout); err != nil {
+			logging.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+
+	if err := spdx.WriteFileAtomic(*outputFile, write); err != nil {
+		logging.Fatalf("Failed to write report: %v", err)
+	}
+	logging.Infof("Stats report written: %s", *outputFile)
+}
+
+// setValidFor records an expiry on doc from a --valid-for value, which is
+// a Go duration optionally using a "d" suffix for days
+func setValidFor(doc *spdx.Document, value string) {
+	if value == "" {
+		return
+	}
+
+	var validFor time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			logging.Fatalf("Invalid --valid-for %q: %v", value, err)
+		}
+		validFor = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if validFor, err = time.ParseDuration(value); err != nil {
+			logging.Fatalf("Invalid --valid-for %q: %v", value, err)
+		}
+	}
+
+	if err := spdx.SetValidFor(doc, validFor, version.Tool()); err != nil {
+		logging.Fatalf("Failed to record validity: %v", err)
+	}
+}
+
+// stripDescriptions removes package descriptions, which can reveal what a
+// system is used for, from documents meant for external sharing
+func stripDescriptions(doc *spdx.Document) {
+	for i := range doc.Packages {
+		doc.Packages[i].Description = ""
+	}
+}
+
+// uploadFlags holds the options for sending the generated SBOM to an HTTP
+// endpoint such as Dependency-Track
+type uploadFlags struct {
+	url     *string
+	apiKey  *string
+	timeout *time.Duration
+}
+
+func addUploadFlags(fs *flag.FlagSet) *uploadFlags {
+	return &uploadFlags{
+		url:     fs.String("upload-url", "", "POST the generated SBOM to this URL"),
+		apiKey:  fs.String("upload-api-key", "", "API key sent as X-Api-Key with --upload-url (defaults to $SBOM_UPLOAD_API_KEY)"),
+		timeout: fs.Duration("upload-timeout", 5*time.Minute, "Timeout for --upload-url"),
+	}
+}
+
+// summaryFlags holds the options for printing a breakdown of the final
+// document
+type summaryFlags struct {
+	text *bool
+	json *bool
+}
+
+func addSummaryFlags(fs *flag.FlagSet) *summaryFlags {
+	return &summaryFlags{
+		text: fs.Bool("stats", false, "Print package, license and relationship counts of the result to stderr"),
+		json: fs.Bool("stats-json", false, "Print the --stats counts to stderr as a JSON object"),
+	}
+}
+
+// run prints the summary of doc to stderr if requested. It is written
+// regardless of --quiet, since it was asked for explicitly.
+func (f *summaryFlags) run(doc *spdx.Document) {
+	if !*f.text && !*f.json {
+		return
+	}
+
+	summary := stats.Summarize(doc)
+	var err error
+	if *f.json {
+		err = summary.WriteJSON(os.Stderr)
+	} else {
+		err = summary.WriteText(os.Stderr)
+	}
+	if err != nil {
+		logging.Warnf("failed to write stats: %v", err)
+	}
+}
+
+// licenseGateFlags holds the options for failing a run with too many
+// unresolved licenses
+type licenseGateFlags struct {
+	enabled   *bool
+	threshold *float64
+}
+
+func addLicenseGateFlags(fs *flag.FlagSet) *licenseGateFlags {
+	return &licenseGateFlags{
+		enabled:   fs.Bool("fail-on-noassertion", false, "Exit 1, after writing the output, when too many packages have a NOASSERTION concluded license"),
+		threshold: fs.Float64("noassertion-threshold", 0, "Percentage of packages allowed a NOASSERTION concluded license with --fail-on-noassertion"),
+	}
+}
+
+// check validates the threshold before generation
+func (f *licenseGateFlags) check() {
+	if *f.threshold < 0 || *f.threshold > 100 {
+		logging.Fatalf("Invalid --noassertion-threshold %v: expected a percentage between 0 and 100", *f.threshold)
+	}
+}
+
+// run exits with a report of the packages without a resolved license when
+// --fail-on-noassertion was given and they exceed the threshold. It only
+// reads doc, so the written document is the same either way.
+func (f *licenseGateFlags) run(doc *spdx.Document) {
+	if !*f.enabled {
+		return
+	}
+
+	unresolved, total := stats.Unresolved(doc)
+	percent := 0.0
+	if total > 0 {
+		percent = 100 * float64(len(unresolved)) / float64(total)
+	}
+	if percent <= *f.threshold {
+		logging.Infof("License check passed: %d of %d packages (%.1f%%) have a NOASSERTION license, threshold %.1f%%", len(unresolved), total, percent, *f.threshold)
+		return
+	}
+
+	logging.Errorf("License check failed: %d of %d packages (%.1f%%) have a NOASSERTION license, threshold %.1f%%:", len(unresolved), total, percent, *f.threshold)
+	for _, pkg := range unresolved {
+		logging.Errorf("  %s %s", pkg.Name, pkg.PackageVersion)
+	}
+	os.Exit(1)
+}
+
+// trustFlags holds the options for flagging packages from untrusted
+// maintainers
+type trustFlags struct {
+	file *string
+	fail *bool
+}
+
+func addTrustFlags(fs *flag.FlagSet) *trustFlags {
+	return &trustFlags{
+		file: fs.String("trusted-maintainers", "", "File of trusted maintainer email domains; packages maintained from other domains are annotated untrusted-maintainer"),
+		fail: fs.Bool("fail-on-untrusted", false, "With --trusted-maintainers, exit 1, after writing the output, when any package has an untrusted maintainer"),
+	}
+}
+
+// check validates the options before generation
+func (f *trustFlags) check() {
+	if *f.fail && *f.file == "" {
+		logging.Fatalf("--fail-on-untrusted requires --trusted-maintainers")
+	}
+}
+
+// run lists the packages annotated as having an untrusted maintainer,
+// exiting 1 when there are any and --fail-on-untrusted was given. Like
+// the license gate, it only reads doc.
+func (f *trustFlags) run(doc *spdx.Document) {
+	if *f.file == "" {
+		return
+	}
+
+	var untrusted []spdx.Package
+	for _, pkg := range doc.Packages {
+		for _, annotation := range pkg.Annotations {
+			if annotation.Comment == sbom.UntrustedMaintainer {
+				untrusted = append(untrusted, pkg)
+				break
+			}
+		}
+	}
+	if len(untrusted) == 0 {
+		logging.Infof("Maintainer check passed: every package has a trusted maintainer")
+		return
+	}
+
+	report := logging.Warnf
+	if *f.fail {
+		report = logging.Errorf
+	}
+	report("%d packages have an untrusted maintainer:", len(untrusted))
+	for _, pkg := range untrusted {
+		supplier := strings.TrimPrefix(pkg.Supplier, "Organization: ")
+		if supplier == "" {
+			supplier = "no maintainer"
+		}
+		report("  %s %s (%s)", pkg.Name, pkg.PackageVersion, supplier)
+	}
+	if *f.fail {
+		os.Exit(1)
+	}
+}
+
+// osvFlags holds the options for annotating packages with known
+// vulnerabilities from OSV.dev
+type osvFlags struct {
+	enabled *bool
+	timeout *time.Duration
+}
+
+func addOSVFlags(fs *flag.FlagSet) *osvFlags {
+	return &osvFlags{
+		enabled: fs.Bool("enrich-osv", false, "Add advisory references for vulnerabilities OSV.dev reports against each package's purl"),
+		timeout: fs.Duration("osv-timeout", 30*time.Second, "Timeout for --enrich-osv queries"),
+	}
+}
+
+// run annotates doc if --enrich-osv was given. A failed lookup only warns,
+// so that generating the SBOM does not depend on network access.
+func (f *osvFlags) run(doc *spdx.Document) {
+	if !*f.enabled {
+		return
+	}
+
+	logging.Infof("Querying OSV.dev for known vulnerabilities...")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	annotated, err := enrich.NewOSV(*f.timeout).Enrich(ctx, doc)
+	if err != nil {
+		logging.Warnf("OSV enrichment incomplete: %v", err)
+	}
+	logging.Infof("Added OSV advisories to %d packages", annotated)
+}
+
+// signFlags holds the options for a detached signature over the output
+type signFlags struct {
+	key *string
+}
+
+func addSignFlags(fs *flag.FlagSet) *signFlags {
+	return &signFlags{
+		key: fs.String("sign-key", "", "Write a detached signature of the output to <output>.sig with this ed25519 (PEM) or armored PGP private key"),
+	}
+}
+
+// run signs outputPath as written if a signing key was given. It must run
+// after the document is saved and before anything else reads it.
+func (f *signFlags) run(outputPath string) {
+	if *f.key == "" {
+		return
+	}
+
+	signer, err := sign.LoadSigner(*f.key)
+	if err != nil {
+		logging.Fatalf("Failed to load signing key: %v", err)
+	}
+	sigPath, err := sign.SignFile(signer, outputPath)
+	if err != nil {
+		logging.Fatalf("Failed to sign SBOM: %v", err)
+	}
+
+	logging.Infof("Signature written: %s", sigPath)
+}
+
+// run uploads outputPath if an upload URL was given
+func (f *uploadFlags) run(outputPath string, showProgress bool) {
+	if *f.url == "" {
+		return
+	}
+
+	apiKey := *f.apiKey
+	if apiKey == "" {
+		apiKey = os.Getenv("SBOM_UPLOAD_API_KEY")
+	}
+
+	uploader := upload.NewUploader(*f.url, apiKey, *f.timeout)
+	if showProgress {
+		lastPercent := int64(-1)
+		uploader.Progress = func(sent, total int64) {
+			if total == 0 {
+				return
+			}
+			if percent := sent * 100 / total; percent/10 != lastPercent/10 {
+				logging.Infof("Uploading SBOM... %d%%", percent)
+				lastPercent = percent
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := uploader.Upload(ctx, outputPath); err != nil {
+		logging.Fatalf("Failed to upload SBOM: %v", err)
+	}
+
+	logging.Infof("SBOM uploaded to %s", spdx.RedactURL(*f.url))
+}
+
+// isDocumentFile reports whether path looks like an SPDX JSON document,
+// optionally compressed
+func isDocumentFile(path string) bool {
+	for _, suffix := range []string{".json", ".json.gz", ".json.zst"} {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConsistency verifies the relationship invariants and SPDXID
+// uniqueness of a generated document, exiting in strict mode and warning
+// otherwise
+func checkConsistency(doc *spdx.Document, strict bool) {
+	notes, dupErr := spdx.CheckDuplicates(doc)
+	for _, note := range notes {
+		logging.Infof("Note: %s", note)
+	}
+
+	for _, err := range []error{spdx.CheckConsistency(doc), dupErr} {
+		if err == nil {
+			continue
+		}
+		if strict {
+			logging.Fatalf("%v", err)
+		}
+		logging.Warnf("%v", err)
+	}
+}
+
+// copyrightLengthOption maps --max-copyright-length, where 0 means no
+// limit, to sbom.Options, where 0 means the default
+func copyrightLengthOption(length int) int {
+	if length == 0 {
+		return -1
+	}
+	return length
+}
+
+// sinceOption parses --since, exiting on an invalid time or a
+// --strict-since without it
+func sinceOption(value string, strict bool) time.Time {
+	if value == "" {
+		if strict {
+			logging.Fatalf("--strict-since requires --since")
+		}
+		return time.Time{}
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logging.Fatalf("Invalid --since %q: expected an RFC3339 time such as 2024-05-01T00:00:00Z", value)
+	}
+	return since
+}
+
+// stringList is a repeatable flag collecting every value given
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// globList is a repeatable flag collecting validated glob patterns
+type globList []string
+
+func (l *globList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *globList) Set(pattern string) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	*l = append(*l, pattern)
+	return nil
+}
+
+// parseGlobs splits a comma-separated glob list, exiting on malformed patterns
+func parseGlobs(value string) []string {
+	var globs []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			logging.Fatalf("Invalid glob %q: %v", pattern, err)
+		}
+		globs = append(globs, pattern)
+	}
+	return globs
 }
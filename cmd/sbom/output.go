@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/stats"
+	"github.com/ubuntu-nix-sbom/pkg/sbom"
+)
+
+// formatExtensions are the file extensions of each output format
+var formatExtensions = map[string]string{
+	sbom.FormatSPDX:      ".spdx.json",
+	sbom.FormatTagValue:  ".spdx",
+	sbom.FormatCycloneDX: ".cdx.json",
+}
+
+// formatList is a repeatable flag of comma-separated output formats
+type formatList []string
+
+func (l *formatList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *formatList) Set(value string) error {
+	for _, format := range strings.Split(value, ",") {
+		format = strings.TrimSpace(format)
+		if _, ok := formatExtensions[format]; !ok {
+			return fmt.Errorf("unknown format %q: expected spdx, tag-value or cyclonedx", format)
+		}
+		*l = append(*l, format)
+	}
+	return nil
+}
+
+// outputFlags holds where and in which formats a document is written
+type outputFlags struct {
+	output      *string
+	dir         *string
+	manifest    *string
+	spdxVersion *string
+	formats     formatList
+	creators    stringList
+}
+
+func addOutputFlags(fs *flag.FlagSet, defaultOutput string) *outputFlags {
+	f := &outputFlags{
+		output:      fs.String("output", defaultOutput, "Output file path, or - for stdout"),
+		dir:         fs.String("output-dir", "", "Write one file per --format into this directory, named after --output with the format's extension"),
+		manifest:    fs.String("emit-manifest", "", "Also write a JSON manifest with the SHA256 of each output file, the creation time, tools and package count to this path"),
+		spdxVersion: fs.String("spdx-version", "2.3", "SPDX version of spdx and tag-value output: 2.3 or 2.2 (drops fields 2.2 consumers reject)"),
+	}
+	fs.Var(&f.formats, "format", "Output format: spdx, tag-value or cyclonedx (repeatable or comma-separated, default spdx)")
+	fs.Var(&f.creators, "creator", "Also credit this person or organization as a creator, e.g. 'Person: Jane Doe (jane@example.com)' or 'Organization: Example Inc.' (repeatable)")
+	return f
+}
+
+// outputTarget is a file to write and its format
+type outputTarget struct {
+	path   string
+	format string
+}
+
+// targets resolves the file written for each format, exiting on
+// conflicts. A single format without --output-dir is written to --output
+// as given. Otherwise each format gets the base name of --output (without
+// its extension) plus its own extension, in --output-dir or next to
+// --output.
+func (f *outputFlags) targets() []outputTarget {
+	formats := f.formats
+	if len(formats) == 0 {
+		formats = formatList{sbom.FormatSPDX}
+	}
+
+	if *f.manifest != "" && *f.output == spdx.StdoutPath {
+		logging.Fatalf("--emit-manifest needs an output file, not stdout")
+	}
+	if len(formats) == 1 && *f.dir == "" {
+		return []outputTarget{{path: *f.output, format: formats[0]}}
+	}
+	if *f.output == spdx.StdoutPath {
+		logging.Fatalf("several formats or --output-dir need an output file name, not stdout")
+	}
+
+	dir := *f.dir
+	if dir == "" {
+		dir = filepath.Dir(*f.output)
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		logging.Fatalf("Failed to create output directory: %v", err)
+	}
+	base := filepath.Base(*f.output)
+	for _, ext := range []string{".json", ".spdx", ".cdx"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	var targets []outputTarget
+	formatOf := make(map[string]string)
+	for _, format := range formats {
+		path := filepath.Join(dir, base+formatExtensions[format])
+		if other, ok := formatOf[path]; ok {
+			logging.Fatalf("formats %s and %s would both write %s", other, format, path)
+		}
+		formatOf[path] = format
+		targets = append(targets, outputTarget{path: path, format: format})
+	}
+	return targets
+}
+
+// check resolves the targets and SPDX version up front so mistakes are
+// reported before any expensive generation
+func (f *outputFlags) check() {
+	f.targets()
+	f.version()
+	for _, creator := range f.creators {
+		if err := checkCreator(creator); err != nil {
+			logging.Fatalf("Invalid --creator: %v", err)
+		}
+	}
+}
+
+// checkCreator accepts the SPDX creator forms for people and
+// organizations; the tool creator is always recorded by the generators
+func checkCreator(creator string) error {
+	kind, name, ok := strings.Cut(creator, ":")
+	if !ok || (kind != "Person" && kind != "Organization") {
+		return fmt.Errorf("%q must start with Person: or Organization:", creator)
+	}
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%q names no one", creator)
+	}
+	return nil
+}
+
+// version returns the spdxVersion to write, exiting if it is unsupported
+func (f *outputFlags) version() string {
+	version, err := spdx.ParseVersion(*f.spdxVersion)
+	if err != nil {
+		logging.Fatalf("Invalid --spdx-version: %v", err)
+	}
+	return version
+}
+
+// save writes doc once per target, signs each file and writes the
+// manifest if requested, returning the paths written
+func (f *outputFlags) save(doc *spdx.Document, signing *signFlags) []string {
+	targets := f.targets()
+	doc.SPDXVersion = f.version()
+	for _, creator := range f.creators {
+		kind, name, _ := strings.Cut(creator, ":")
+		doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, kind+": "+strings.TrimSpace(name))
+	}
+	var paths []string
+	for _, target := range targets {
+		if err := sbom.Save(doc, target.path, target.format); err != nil {
+			logging.Fatalf("Failed to save %s: %v", target.path, err)
+		}
+		signing.run(target.path)
+		paths = append(paths, target.path)
+	}
+
+	if *f.manifest != "" {
+		if err := writeManifest(*f.manifest, doc, targets); err != nil {
+			logging.Fatalf("Failed to write manifest: %v", err)
+		}
+		logging.Infof("Manifest written: %s", *f.manifest)
+	}
+	return paths
+}
+
+// manifest pins the written SBOM files for release and attestation
+// manifests
+type manifest struct {
+	Files    []manifestFile `json:"files"`
+	Created  string         `json:"created"`
+	Tools    []string       `json:"tools"`
+	Packages int            `json:"packages"`
+}
+
+type manifestFile struct {
+	// Name is the file's path relative to the manifest
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeManifest records the SHA256 of every target as stored on disk,
+// so the hash covers the final serialized bytes
+func writeManifest(path string, doc *spdx.Document, targets []outputTarget) error {
+	m := manifest{
+		Files:    []manifestFile{},
+		Created:  doc.CreationInfo.Created,
+		Tools:    []string{},
+		Packages: stats.Summarize(doc).Packages,
+	}
+	for _, creator := range doc.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(creator, "Tool:"); ok {
+			m.Tools = append(m.Tools, strings.TrimSpace(tool))
+		}
+	}
+
+	for _, target := range targets {
+		sum, err := fileSHA256(target.path)
+		if err != nil {
+			return err
+		}
+		name := target.path
+		if rel, err := filepath.Rel(filepath.Dir(path), target.path); err == nil {
+			name = rel
+		}
+		m.Files = append(m.Files, manifestFile{Name: name, Format: target.format, SHA256: sum})
+	}
+
+	return spdx.WriteFileAtomic(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(m)
+	})
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
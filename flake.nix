@@ -40,15 +40,20 @@
         let
           sbomnix = inputs.sbomnix.packages.${system}.default;
 
+          version = "1.0.0";
+
+          # Identifies the build in the Tool: creator of generated SBOMs
+          versionLdflags = "-X github.com/ubuntu-nix-sbom/internal/version.Version=${version} -X github.com/ubuntu-nix-sbom/internal/version.Commit=${inputs.self.shortRev or "dirty"}";
+
           # Build the Ubuntu SBOM generator
           ubuntu-sbom = pkgs.buildGoModule {
             pname = "ubuntu-sbom-generator";
-            version = "1.0.0";
+            inherit version;
             src = ./.;
             vendorHash = null;
 
             buildPhase = ''
-              go build -o ubuntu-sbom main.go
+              go build -ldflags "${versionLdflags}" -o ubuntu-sbom main.go
             '';
 
             installPhase = ''
@@ -65,12 +70,12 @@
           # Build the full-featured sbom binary
           sbom = pkgs.buildGoModule {
             pname = "sbom";
-            version = "1.0.0";
+            inherit version;
             src = ./.;
             vendorHash = null;
 
             buildPhase = ''
-              go build -o sbom ./cmd/sbom
+              go build -ldflags "${versionLdflags}" -o sbom ./cmd/sbom
             '';
 
             installPhase = ''
@@ -104,13 +109,13 @@
           # Static binary for current system
           ubuntu-sbom-static-current = pkgs.buildGoModule {
             pname = "ubuntu-sbom-generator";
-            version = "1.0.0";
+            inherit version;
             src = ./.;
             vendorHash = null;
 
             # Build static binary with no CGO
             buildPhase = ''
-              CGO_ENABLED=0 go build -a -ldflags '-s -w -extldflags "-static"' -o ubuntu-sbom main.go
+              CGO_ENABLED=0 go build -a -ldflags '-s -w -extldflags "-static" ${versionLdflags}' -o ubuntu-sbom main.go
             '';
 
             installPhase = ''
@@ -135,13 +140,13 @@
             in
             targetPkgs.buildGoModule {
               pname = "ubuntu-sbom-generator";
-              version = "1.0.0";
+              inherit version;
               src = ./.;
               vendorHash = null;
 
               # Build static binary with no CGO
               buildPhase = ''
-                CGO_ENABLED=0 go build -a -ldflags '-s -w -extldflags "-static"' -o ubuntu-sbom main.go
+                CGO_ENABLED=0 go build -a -ldflags '-s -w -extldflags "-static" ${versionLdflags}' -o ubuntu-sbom main.go
               '';
 
               installPhase = ''
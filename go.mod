@@ -1,3 +1,5 @@
 module github.com/ubuntu-nix-sbom
 
 go 1.21
+
+require github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
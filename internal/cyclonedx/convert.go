@@ -0,0 +1,189 @@
+package cyclonedx
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// hashAlgorithms maps SPDX checksum algorithms to CycloneDX hash algorithms
+var hashAlgorithms = map[string]string{
+	"MD5":    "MD5",
+	"SHA1":   "SHA-1",
+	"SHA256": "SHA-256",
+	"SHA384": "SHA-384",
+	"SHA512": "SHA-512",
+}
+
+// FromSPDX converts an SPDX document to a CycloneDX BOM. The package the
+// document DESCRIBES becomes the metadata component, every other package a
+// component, and CONTAINS/DEPENDS_ON relationships become dependencies.
+// Purls are carried over verbatim, and the serial number is derived from
+// the document namespace so reproducible documents stay reproducible.
+func FromSPDX(doc *spdx.Document) *Document {
+	bom := &Document{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + spdx.NameUUID(doc.DocumentNamespace),
+		Version:      1,
+		Metadata: Metadata{
+			Timestamp: doc.CreationInfo.Created,
+		},
+		Components: []Component{},
+	}
+
+	var tools []Component
+	for _, creator := range doc.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(creator, "Tool:"); ok {
+			tools = append(tools, Component{Type: "application", Name: strings.TrimSpace(tool)})
+		}
+	}
+	if len(tools) > 0 {
+		bom.Metadata.Tools = &Tools{Components: tools}
+	}
+
+	rootID := ""
+	for _, rel := range doc.Relationships {
+		if rel.SPDXElementID == doc.SPDXID && rel.RelationshipType == "DESCRIBES" {
+			rootID = rel.RelatedSPDXElement
+			break
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, pkg := range doc.Packages {
+		component := packageToComponent(pkg)
+		known[pkg.SPDXID] = true
+
+		if pkg.SPDXID == rootID {
+			component.Type = "operating-system"
+			bom.Metadata.Component = &component
+			continue
+		}
+		bom.Components = append(bom.Components, component)
+	}
+
+	// Preserve relationship order so output is stable
+	dependsOn := make(map[string][]string)
+	var refs []string
+	for _, rel := range doc.Relationships {
+		if rel.RelationshipType != "CONTAINS" && rel.RelationshipType != "DEPENDS_ON" {
+			continue
+		}
+		if !known[rel.SPDXElementID] || !known[rel.RelatedSPDXElement] {
+			continue
+		}
+		if _, ok := dependsOn[rel.SPDXElementID]; !ok {
+			refs = append(refs, rel.SPDXElementID)
+		}
+		dependsOn[rel.SPDXElementID] = append(dependsOn[rel.SPDXElementID], rel.RelatedSPDXElement)
+	}
+
+	for _, ref := range refs {
+		bom.Dependencies = append(bom.Dependencies, Dependency{Ref: ref, DependsOn: dependsOn[ref]})
+	}
+
+	return bom
+}
+
+// purposeTypes maps SPDX primary package purposes to CycloneDX component
+// types; anything else is treated as a library
+var purposeTypes = map[string]string{
+	"APPLICATION":      "application",
+	"FRAMEWORK":        "framework",
+	"CONTAINER":        "container",
+	"OPERATING-SYSTEM": "operating-system",
+	"DEVICE":           "device",
+	"FIRMWARE":         "firmware",
+	"FILE":             "file",
+}
+
+func packageToComponent(pkg spdx.Package) Component {
+	componentType := "library"
+	if t, ok := purposeTypes[pkg.PrimaryPackagePurpose]; ok {
+		componentType = t
+	}
+
+	component := Component{
+		Type:        componentType,
+		BOMRef:      pkg.SPDXID,
+		Name:        pkg.Name,
+		Version:     pkg.PackageVersion,
+		Description: pkg.Description,
+	}
+
+	if supplier, ok := strings.CutPrefix(pkg.Supplier, "Organization:"); ok {
+		component.Supplier = &OrganizationalEntity{Name: strings.TrimSpace(supplier)}
+	} else if supplier, ok := strings.CutPrefix(pkg.Supplier, "Person:"); ok {
+		component.Supplier = &OrganizationalEntity{Name: strings.TrimSpace(supplier)}
+	}
+
+	if pkg.CopyrightText != "" && pkg.CopyrightText != "NOASSERTION" && pkg.CopyrightText != "NONE" {
+		component.Copyright = pkg.CopyrightText
+	}
+
+	// NOASSERTION means no license information, which CycloneDX expresses
+	// by omitting the licenses array
+	license := pkg.LicenseConcluded
+	if license == "" || license == "NOASSERTION" || license == "NONE" {
+		license = pkg.LicenseDeclared
+	}
+	if license != "" && license != "NOASSERTION" && license != "NONE" {
+		switch {
+		case strings.ContainsAny(license, " ()"):
+			component.Licenses = []LicenseChoice{{Expression: license}}
+		case strings.HasPrefix(license, "LicenseRef-"):
+			// CycloneDX license IDs come from the SPDX list only
+			component.Licenses = []LicenseChoice{{License: &License{Name: license}}}
+		default:
+			component.Licenses = []LicenseChoice{{License: &License{ID: license}}}
+		}
+	}
+
+	for _, checksum := range pkg.Checksums {
+		if alg, ok := hashAlgorithms[checksum.Algorithm]; ok {
+			component.Hashes = append(component.Hashes, Hash{Algorithm: alg, Content: checksum.Value})
+		}
+	}
+
+	for _, ref := range pkg.ExternalRefs {
+		switch ref.Type {
+		case "purl":
+			if component.PURL == "" {
+				component.PURL = ref.Locator
+			}
+		case "cpe23Type", "cpe22Type":
+			if component.CPE == "" {
+				component.CPE = ref.Locator
+			}
+		case "advisory":
+			component.ExternalReferences = append(component.ExternalReferences, ExternalReference{Type: "advisories", URL: ref.Locator})
+		}
+	}
+
+	if pkg.HomePage != "" {
+		component.ExternalReferences = append(component.ExternalReferences, ExternalReference{Type: "website", URL: pkg.HomePage})
+	}
+
+	return component
+}
+
+// Encode writes the BOM as indented JSON
+func Encode(w io.Writer, bom *Document) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	// Purls join qualifiers with &, keep them readable
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(bom)
+}
+
+// SaveDocument converts doc to CycloneDX and writes it to outputPath
+// atomically
+func SaveDocument(doc *spdx.Document, outputPath string) error {
+	bom := FromSPDX(doc)
+	return spdx.WriteFileAtomic(outputPath, func(w io.Writer) error {
+		return Encode(w, bom)
+	})
+}
@@ -0,0 +1,69 @@
+package cyclonedx
+
+// CycloneDX 1.5 JSON BOM structure
+type Document struct {
+	BOMFormat    string       `json:"bomFormat"`
+	SpecVersion  string       `json:"specVersion"`
+	SerialNumber string       `json:"serialNumber"`
+	Version      int          `json:"version"`
+	Metadata     Metadata     `json:"metadata"`
+	Components   []Component  `json:"components"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+type Metadata struct {
+	Timestamp string     `json:"timestamp"`
+	Tools     *Tools     `json:"tools,omitempty"`
+	Component *Component `json:"component,omitempty"`
+}
+
+type Tools struct {
+	Components []Component `json:"components"`
+}
+
+type Component struct {
+	Type               string                `json:"type"`
+	BOMRef             string                `json:"bom-ref,omitempty"`
+	Name               string                `json:"name"`
+	Version            string                `json:"version,omitempty"`
+	Description        string                `json:"description,omitempty"`
+	Supplier           *OrganizationalEntity `json:"supplier,omitempty"`
+	Hashes             []Hash                `json:"hashes,omitempty"`
+	Licenses           []LicenseChoice       `json:"licenses,omitempty"`
+	Copyright          string                `json:"copyright,omitempty"`
+	PURL               string                `json:"purl,omitempty"`
+	CPE                string                `json:"cpe,omitempty"`
+	ExternalReferences []ExternalReference   `json:"externalReferences,omitempty"`
+}
+
+type OrganizationalEntity struct {
+	Name string `json:"name"`
+}
+
+type Hash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// LicenseChoice holds either a single license or an SPDX expression
+type LicenseChoice struct {
+	License    *License `json:"license,omitempty"`
+	Expression string   `json:"expression,omitempty"`
+}
+
+// License is a license by SPDX identifier, or by name when it is not on
+// the SPDX list
+type License struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type ExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
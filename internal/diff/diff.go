@@ -0,0 +1,198 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// Package is a package present in only one of the compared documents
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Change is a package whose version differs between the documents
+type Change struct {
+	Name       string `json:"name"`
+	OldVersion string `json:"oldVersion"`
+	NewVersion string `json:"newVersion"`
+}
+
+// Report lists the package differences between two documents
+type Report struct {
+	Added   []Package `json:"added"`
+	Removed []Package `json:"removed"`
+	Changed []Change  `json:"changed"`
+}
+
+// Compare matches the packages of two documents by name and reports what
+// was added, removed or changed version. SPDXIDs are not compared since
+// they are not stable across runs, and root packages describing the system
+// are ignored. A name installed in several versions (e.g. multiple
+// architectures, or both apt and Nix) is reported as a version change only
+// when each side has exactly one version; otherwise the differing versions
+// are listed as added and removed.
+func Compare(oldDoc, newDoc *spdx.Document) *Report {
+	oldVersions := packageVersions(oldDoc)
+	newVersions := packageVersions(newDoc)
+
+	report := &Report{
+		Added:   []Package{},
+		Removed: []Package{},
+		Changed: []Change{},
+	}
+
+	for name, oldSet := range oldVersions {
+		newSet, ok := newVersions[name]
+		if !ok {
+			for _, v := range oldSet {
+				report.Removed = append(report.Removed, Package{Name: name, Version: v})
+			}
+			continue
+		}
+
+		if len(oldSet) == 1 && len(newSet) == 1 {
+			if oldSet[0] != newSet[0] {
+				report.Changed = append(report.Changed, Change{Name: name, OldVersion: oldSet[0], NewVersion: newSet[0]})
+			}
+			continue
+		}
+
+		removed, added := setDifference(oldSet, newSet)
+		for _, v := range removed {
+			report.Removed = append(report.Removed, Package{Name: name, Version: v})
+		}
+		for _, v := range added {
+			report.Added = append(report.Added, Package{Name: name, Version: v})
+		}
+	}
+
+	for name, newSet := range newVersions {
+		if _, ok := oldVersions[name]; ok {
+			continue
+		}
+		for _, v := range newSet {
+			report.Added = append(report.Added, Package{Name: name, Version: v})
+		}
+	}
+
+	sortPackages(report.Added)
+	sortPackages(report.Removed)
+	sort.Slice(report.Changed, func(i, j int) bool {
+		return report.Changed[i].Name < report.Changed[j].Name
+	})
+
+	return report
+}
+
+// HasChanges reports whether the documents differ
+func (r *Report) HasChanges() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0 || len(r.Changed) > 0
+}
+
+// packageVersions maps each non-root package name to its sorted, distinct
+// versions
+func packageVersions(doc *spdx.Document) map[string][]string {
+	roots := make(map[string]bool)
+	for _, rel := range doc.Relationships {
+		if rel.RelationshipType == "DESCRIBES" {
+			roots[rel.RelatedSPDXElement] = true
+		}
+	}
+
+	versions := make(map[string][]string)
+	seen := make(map[Package]bool)
+	for _, pkg := range doc.Packages {
+		if roots[pkg.SPDXID] {
+			continue
+		}
+		key := Package{Name: pkg.Name, Version: pkg.PackageVersion}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		versions[pkg.Name] = append(versions[pkg.Name], pkg.PackageVersion)
+	}
+
+	for _, v := range versions {
+		sort.Strings(v)
+	}
+	return versions
+}
+
+// setDifference returns the entries only in a and only in b
+func setDifference(a, b []string) (onlyA, onlyB []string) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+		if !inA[v] {
+			onlyB = append(onlyB, v)
+		}
+	}
+	for _, v := range a {
+		if !inB[v] {
+			onlyA = append(onlyA, v)
+		}
+	}
+	return onlyA, onlyB
+}
+
+func sortPackages(packages []Package) {
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].Version < packages[j].Version
+	})
+}
+
+// WriteText writes a human-readable summary
+func (r *Report) WriteText(w io.Writer) error {
+	if !r.HasChanges() {
+		fmt.Fprintln(w, "No package differences")
+		return nil
+	}
+
+	fmt.Fprintf(w, "%d added, %d removed, %d changed\n", len(r.Added), len(r.Removed), len(r.Changed))
+
+	if len(r.Added) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Added:")
+		for _, p := range r.Added {
+			fmt.Fprintf(w, "  + %-40s %s\n", p.Name, p.Version)
+		}
+	}
+
+	if len(r.Removed) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Removed:")
+		for _, p := range r.Removed {
+			fmt.Fprintf(w, "  - %-40s %s\n", p.Name, p.Version)
+		}
+	}
+
+	if len(r.Changed) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Changed:")
+		for _, c := range r.Changed {
+			fmt.Fprintf(w, "  ~ %-40s %s -> %s\n", c.Name, c.OldVersion, c.NewVersion)
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
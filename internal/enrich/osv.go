@@ -0,0 +1,221 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// DefaultOSVURL is the OSV.dev batch query endpoint
+const DefaultOSVURL = "https://api.osv.dev/v1/querybatch"
+
+// osvBatchSize is the maximum number of queries OSV accepts per request
+const osvBatchSize = 1000
+
+// maxErrorBody bounds how much of a failed response is included in errors
+const maxErrorBody = 4096
+
+// OSV looks up known vulnerabilities for each package's purl in the OSV.dev
+// database and attaches them as advisory external references
+type OSV struct {
+	URL     string
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+func NewOSV(timeout time.Duration) *OSV {
+	return &OSV{
+		URL:     DefaultOSVURL,
+		Timeout: timeout,
+		Client:  http.DefaultClient,
+	}
+}
+
+type osvQuery struct {
+	Package   osvPackage `json:"package"`
+	PageToken string     `json:"page_token,omitempty"`
+}
+
+type osvPackage struct {
+	PURL string `json:"purl"`
+}
+
+type osvBatchRequest struct {
+	Queries []osvQuery `json:"queries"`
+}
+
+type osvBatchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+		NextPageToken string `json:"next_page_token"`
+	} `json:"results"`
+}
+
+// Enrich queries OSV for every package with a purl and adds a SECURITY
+// advisory reference per matching vulnerability. It returns the number of
+// packages annotated. On error the document is left as it was for the
+// batches that did not complete.
+func (o *OSV) Enrich(ctx context.Context, doc *spdx.Document) (int, error) {
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	var indexes []int
+	var queries []osvQuery
+	for i, pkg := range doc.Packages {
+		purl := queryPurl(pkg)
+		if purl == "" {
+			continue
+		}
+		indexes = append(indexes, i)
+		queries = append(queries, osvQuery{Package: osvPackage{PURL: purl}})
+	}
+
+	annotated := 0
+	for start := 0; start < len(queries); start += osvBatchSize {
+		end := start + osvBatchSize
+		if end > len(queries) {
+			end = len(queries)
+		}
+
+		ids, err := o.queryAll(ctx, queries[start:end])
+		if err != nil {
+			return annotated, err
+		}
+		for j := range ids {
+			if len(ids[j]) > 0 && addAdvisoryRefs(&doc.Packages[indexes[start+j]], ids[j]) {
+				annotated++
+			}
+		}
+	}
+
+	return annotated, nil
+}
+
+// queryAll runs one batch of queries, following each result's
+// next_page_token until OSV has returned every page, and returns the
+// vulnerability IDs found for each query
+func (o *OSV) queryAll(ctx context.Context, queries []osvQuery) ([][]string, error) {
+	ids := make([][]string, len(queries))
+
+	// pending holds the indexes of the queries with pages left to fetch
+	pending := make([]int, len(queries))
+	for i := range pending {
+		pending[i] = i
+	}
+	page := queries
+	for len(page) > 0 {
+		resp, err := o.queryBatch(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Results) != len(page) {
+			return nil, fmt.Errorf("OSV returned %d results for %d queries", len(resp.Results), len(page))
+		}
+
+		var next []int
+		page = nil
+		for j, result := range resp.Results {
+			i := pending[j]
+			for _, vuln := range result.Vulns {
+				ids[i] = append(ids[i], vuln.ID)
+			}
+			if result.NextPageToken != "" {
+				query := queries[i]
+				query.PageToken = result.NextPageToken
+				next = append(next, i)
+				page = append(page, query)
+			}
+		}
+		pending = next
+	}
+
+	return ids, nil
+}
+
+func (o *OSV) queryBatch(ctx context.Context, queries []osvQuery) (*osvBatchResponse, error) {
+	body, err := json.Marshal(osvBatchRequest{Queries: queries})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OSV query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OSV request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OSV query to %s failed: %w", o.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, fmt.Errorf("OSV query to %s failed: HTTP %s: %s", o.URL, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var result osvBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode OSV response: %w", err)
+	}
+	return &result, nil
+}
+
+// queryPurl returns the package's purl in the form OSV matches on: with a
+// version, and without qualifiers or subpath, which OSV does not use
+func queryPurl(pkg spdx.Package) string {
+	for _, ref := range pkg.ExternalRefs {
+		if ref.Type != "purl" {
+			continue
+		}
+		purl := ref.Locator
+		if i := strings.IndexAny(purl, "?#"); i >= 0 {
+			purl = purl[:i]
+		}
+		if !strings.Contains(purl, "@") {
+			return ""
+		}
+		return purl
+	}
+	return ""
+}
+
+// addAdvisoryRefs appends an OSV advisory reference for each ID the package
+// does not already reference, reporting whether any were added
+func addAdvisoryRefs(pkg *spdx.Package, ids []string) bool {
+	existing := make(map[string]bool)
+	for _, ref := range pkg.ExternalRefs {
+		if ref.Category == "SECURITY" {
+			existing[ref.Locator] = true
+		}
+	}
+
+	added := false
+	for _, id := range ids {
+		locator := "https://osv.dev/vulnerability/" + id
+		if existing[locator] {
+			continue
+		}
+		existing[locator] = true
+		pkg.ExternalRefs = append(pkg.ExternalRefs, spdx.ExternalRef{
+			Category: "SECURITY",
+			Type:     "advisory",
+			Locator:  locator,
+		})
+		added = true
+	}
+	return added
+}
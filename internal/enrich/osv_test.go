@@ -0,0 +1,235 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// osvServer answers OSV batch queries with respond, recording the
+// requests it was sent
+func osvServer(t *testing.T, respond func(w http.ResponseWriter, req osvBatchRequest)) (*OSV, *[]osvBatchRequest) {
+	t.Helper()
+	var requests []osvBatchRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req osvBatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req)
+		respond(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	o := NewOSV(5 * time.Second)
+	o.URL = server.URL
+	o.Client = server.Client()
+	return o, &requests
+}
+
+// vulnsByPurl answers each query with the IDs listed for its purl
+func vulnsByPurl(vulns map[string][]string) func(http.ResponseWriter, osvBatchRequest) {
+	type vuln struct {
+		ID string `json:"id"`
+	}
+	type result struct {
+		Vulns []vuln `json:"vulns,omitempty"`
+	}
+	return func(w http.ResponseWriter, req osvBatchRequest) {
+		results := make([]result, len(req.Queries))
+		for i, query := range req.Queries {
+			for _, id := range vulns[query.Package.PURL] {
+				results[i].Vulns = append(results[i].Vulns, vuln{id})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string][]result{"results": results})
+	}
+}
+
+// purlDocument returns a document with a package per purl
+func purlDocument(purls ...string) *spdx.Document {
+	doc := &spdx.Document{}
+	for i, purl := range purls {
+		doc.Packages = append(doc.Packages, spdx.Package{
+			SPDXID:       fmt.Sprintf("SPDXRef-%d", i),
+			ExternalRefs: []spdx.ExternalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: purl}},
+		})
+	}
+	return doc
+}
+
+// advisories lists the advisory locators of pkg
+func advisories(pkg spdx.Package) []string {
+	var locators []string
+	for _, ref := range pkg.ExternalRefs {
+		if ref.Type == "advisory" {
+			locators = append(locators, strings.TrimPrefix(ref.Locator, "https://osv.dev/vulnerability/"))
+		}
+	}
+	return locators
+}
+
+func TestOSVEnrichBatches(t *testing.T) {
+	var purls []string
+	for i := 0; i < 2500; i++ {
+		purls = append(purls, fmt.Sprintf("pkg:deb/ubuntu/pkg%d@1.0?arch=amd64", i))
+	}
+	doc := purlDocument(purls...)
+	o, requests := osvServer(t, vulnsByPurl(map[string][]string{
+		"pkg:deb/ubuntu/pkg0@1.0":    {"OSV-0"},
+		"pkg:deb/ubuntu/pkg1999@1.0": {"OSV-1999"},
+		"pkg:deb/ubuntu/pkg2499@1.0": {"OSV-2499a", "OSV-2499b"},
+	}))
+
+	annotated, err := o.Enrich(context.Background(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if annotated != 3 {
+		t.Errorf("annotated %d packages, want 3", annotated)
+	}
+
+	var sizes []int
+	for _, req := range *requests {
+		sizes = append(sizes, len(req.Queries))
+	}
+	if want := []int{1000, 1000, 500}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("batch sizes %v, want %v", sizes, want)
+	}
+	// Qualifiers are dropped from the queried purls
+	if got := (*requests)[0].Queries[0].Package.PURL; got != "pkg:deb/ubuntu/pkg0@1.0" {
+		t.Errorf("queried %q", got)
+	}
+
+	for i, want := range map[int][]string{0: {"OSV-0"}, 1: nil, 1999: {"OSV-1999"}, 2499: {"OSV-2499a", "OSV-2499b"}} {
+		if got := advisories(doc.Packages[i]); !reflect.DeepEqual(got, want) {
+			t.Errorf("package %d: advisories %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestOSVEnrichPages(t *testing.T) {
+	doc := purlDocument("pkg:deb/ubuntu/openssl@3.0.2", "pkg:deb/ubuntu/bash@5.1", "pkg:deb/ubuntu/zlib1g@1.2.11")
+	o, requests := osvServer(t, func(w http.ResponseWriter, req osvBatchRequest) {
+		// openssl has three pages of results, zlib1g two
+		pages := map[string]string{
+			"pkg:deb/ubuntu/openssl@3.0.2 ":          `{"vulns": [{"id": "OSV-1"}], "next_page_token": "openssl-2"}`,
+			"pkg:deb/ubuntu/openssl@3.0.2 openssl-2": `{"vulns": [{"id": "OSV-2"}, {"id": "OSV-1"}], "next_page_token": "openssl-3"}`,
+			"pkg:deb/ubuntu/openssl@3.0.2 openssl-3": `{"vulns": [{"id": "OSV-3"}]}`,
+			"pkg:deb/ubuntu/bash@5.1 ":               `{}`,
+			"pkg:deb/ubuntu/zlib1g@1.2.11 ":          `{"vulns": [{"id": "OSV-4"}], "next_page_token": "zlib1g-2"}`,
+			"pkg:deb/ubuntu/zlib1g@1.2.11 zlib1g-2":  `{"vulns": [{"id": "OSV-5"}]}`,
+		}
+		var results []string
+		for _, query := range req.Queries {
+			result, ok := pages[query.Package.PURL+" "+query.PageToken]
+			if !ok {
+				t.Errorf("unexpected query %+v", query)
+			}
+			results = append(results, result)
+		}
+		fmt.Fprintf(w, `{"results": [%s]}`, strings.Join(results, ","))
+	})
+
+	if _, err := o.Enrich(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("sent %d requests, want 3", len(*requests))
+	}
+	// Only queries with pages left are repeated
+	if got := len((*requests)[1].Queries); got != 2 {
+		t.Errorf("second request has %d queries, want 2", got)
+	}
+
+	for i, want := range [][]string{{"OSV-1", "OSV-2", "OSV-3"}, nil, {"OSV-4", "OSV-5"}} {
+		if got := advisories(doc.Packages[i]); !reflect.DeepEqual(got, want) {
+			t.Errorf("package %d: advisories %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestOSVEnrichNoDuplicates(t *testing.T) {
+	doc := purlDocument("pkg:deb/ubuntu/openssl@3.0.2")
+	doc.Packages[0].ExternalRefs = append(doc.Packages[0].ExternalRefs, spdx.ExternalRef{
+		Category: "SECURITY",
+		Type:     "advisory",
+		Locator:  "https://osv.dev/vulnerability/OSV-1",
+	})
+	o, _ := osvServer(t, vulnsByPurl(map[string][]string{
+		"pkg:deb/ubuntu/openssl@3.0.2": {"OSV-1", "OSV-2", "OSV-2"},
+	}))
+
+	for run := 0; run < 2; run++ {
+		if _, err := o.Enrich(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := advisories(doc.Packages[0]), []string{"OSV-1", "OSV-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("advisories %v, want %v", got, want)
+	}
+}
+
+func TestOSVEnrichFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		timeout time.Duration
+		respond func(http.ResponseWriter, osvBatchRequest)
+		want    string
+	}{
+		{
+			"result count",
+			0,
+			func(w http.ResponseWriter, req osvBatchRequest) {
+				fmt.Fprint(w, `{"results": [{"vulns": [{"id": "OSV-1"}]}]}`)
+			},
+			"OSV returned 1 results for 2 queries",
+		},
+		{
+			"server error",
+			0,
+			func(w http.ResponseWriter, req osvBatchRequest) {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			},
+			"HTTP 503 Service Unavailable: overloaded",
+		},
+		{
+			"timeout",
+			20 * time.Millisecond,
+			func(w http.ResponseWriter, req osvBatchRequest) {
+				time.Sleep(200 * time.Millisecond)
+			},
+			"context deadline exceeded",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := purlDocument("pkg:deb/ubuntu/openssl@3.0.2", "pkg:deb/ubuntu/bash@5.1")
+			o, _ := osvServer(t, tc.respond)
+			if tc.timeout > 0 {
+				o.Timeout = tc.timeout
+			}
+
+			annotated, err := o.Enrich(context.Background(), doc)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v, want %q", err, tc.want)
+			}
+			// The document is still usable, just not annotated
+			if annotated != 0 {
+				t.Errorf("annotated %d packages", annotated)
+			}
+			for _, pkg := range doc.Packages {
+				if refs := advisories(pkg); len(refs) > 0 {
+					t.Errorf("%s: advisories %v", pkg.SPDXID, refs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package flatpak
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/version"
+)
+
+// Ref is an installed flatpak application or runtime as listed by
+// `flatpak list`
+type Ref struct {
+	Application string
+	Version     string
+	Branch      string
+	Origin      string
+	Runtime     bool
+}
+
+type Generator struct {
+	// FlatpakPath is the flatpak executable, looked up in PATH unless it
+	// contains a slash
+	FlatpakPath string
+
+	// IncludeRuntimes also lists the installed runtimes, such as
+	// org.freedesktop.Platform, besides applications
+	IncludeRuntimes bool
+
+	// Reproducible takes the creation time from SOURCE_DATE_EPOCH (or the
+	// Unix epoch) and derives the namespace from the installed set
+	Reproducible bool
+
+	created string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{FlatpakPath: "flatpak"}
+}
+
+// listColumns are the `flatpak list` columns read, in order
+const listColumns = "application,version,branch,origin"
+
+// Generate builds a document of the installed flatpaks under a
+// SPDXRef-Flatpak-System root. Without flatpak installed the document
+// holds only the root, so flatpaks can always be included in combined
+// SBOMs.
+func (g *Generator) Generate() (*spdx.Document, error) {
+	refs, err := g.installedRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := spdx.CreationTime(g.Reproducible)
+	if err != nil {
+		return nil, err
+	}
+	g.created = created.Format(time.RFC3339)
+
+	doc := &spdx.Document{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              fmt.Sprintf("Flatpak-System-SBOM-%s", created.Format("2006-01-02")),
+		DocumentNamespace: fmt.Sprintf("https://sbom.flatpak.system/%s", spdx.NewUUID()),
+		CreationInfo: spdx.CreationInfo{
+			Created:            g.created,
+			Creators:           []string{version.Tool()},
+			LicenseListVersion: "3.20",
+		},
+		Packages: []spdx.Package{{
+			SPDXID:           "SPDXRef-Flatpak-System",
+			Name:             "Flatpak-System",
+			DownloadLocation: "NOASSERTION",
+			FilesAnalyzed:    false,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+		}},
+		Relationships: []spdx.Relationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelatedSPDXElement: "SPDXRef-Flatpak-System",
+			RelationshipType:   "DESCRIBES",
+		}},
+	}
+
+	// The same application or runtime can be installed on several
+	// branches
+	taken := map[string]bool{"SPDXRef-Flatpak-System": true}
+	for _, ref := range refs {
+		pkg := g.refToSPDX(ref)
+		pkg.SPDXID = spdx.UniqueID(pkg.SPDXID, taken)
+		taken[pkg.SPDXID] = true
+
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdx.Relationship{
+			SPDXElementID:      "SPDXRef-Flatpak-System",
+			RelatedSPDXElement: pkg.SPDXID,
+			RelationshipType:   "CONTAINS",
+		})
+	}
+
+	if g.Reproducible {
+		doc.DocumentNamespace = fmt.Sprintf("https://sbom.flatpak.system/%s", spdx.ContentUUID(doc))
+	}
+
+	logging.Infof("Found %d installed flatpaks", len(refs))
+	return doc, nil
+}
+
+// installedRefs lists the installed applications, and runtimes when
+// requested, returning none with a warning when flatpak is not installed
+func (g *Generator) installedRefs() ([]Ref, error) {
+	flatpakPath, err := exec.LookPath(g.FlatpakPath)
+	if err != nil {
+		logging.Warnf("flatpak not found at %q, no flatpaks included", g.FlatpakPath)
+		return nil, nil
+	}
+
+	refs, err := listRefs(flatpakPath, "--app", false)
+	if err != nil {
+		return nil, err
+	}
+	if g.IncludeRuntimes {
+		runtimes, err := listRefs(flatpakPath, "--runtime", true)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, runtimes...)
+	}
+	return refs, nil
+}
+
+// listRefs runs `flatpak list` for one kind of ref (--app or --runtime)
+func listRefs(flatpakPath, kind string, runtime bool) ([]Ref, error) {
+	output, err := exec.Command(flatpakPath, "list", "--columns="+listColumns, kind).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("flatpak list %s failed: %w\n%s", kind, err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("flatpak list %s failed: %w", kind, err)
+	}
+	return parseList(output, runtime), nil
+}
+
+// parseList reads `flatpak list --columns=application,version,branch,origin`
+// output, which has one tab-separated line per ref and no header when not
+// printed to a terminal. Applications without a version have an empty
+// column.
+func parseList(output []byte, runtime bool) []Ref {
+	var refs []Ref
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 4 || fields[0] == "" || fields[0] == "Application ID" {
+			continue
+		}
+		refs = append(refs, Ref{
+			Application: strings.TrimSpace(fields[0]),
+			Version:     strings.TrimSpace(fields[1]),
+			Branch:      strings.TrimSpace(fields[2]),
+			Origin:      strings.TrimSpace(fields[3]),
+			Runtime:     runtime,
+		})
+	}
+	return refs
+}
+
+// refToSPDX describes an installed flatpak as a package. Applications are
+// APPLICATION and runtimes FRAMEWORK, the platform applications run on.
+func (g *Generator) refToSPDX(ref Ref) spdx.Package {
+	pkg := spdx.Package{
+		SPDXID:                "SPDXRef-Flatpak-" + sanitizeName(ref.Application),
+		Name:                  ref.Application,
+		PackageVersion:        ref.Version,
+		DownloadLocation:      "NOASSERTION",
+		FilesAnalyzed:         false,
+		LicenseConcluded:      "NOASSERTION",
+		LicenseDeclared:       "NOASSERTION",
+		CopyrightText:         "NOASSERTION",
+		PrimaryPackagePurpose: "APPLICATION",
+		ExternalRefs: []spdx.ExternalRef{{
+			Category: "PACKAGE-MANAGER",
+			Type:     "purl",
+			Locator:  buildPurl(ref),
+		}},
+	}
+
+	if ref.Runtime {
+		pkg.PrimaryPackagePurpose = "FRAMEWORK"
+		pkg.Annotations = append(pkg.Annotations, g.annotation("flatpak-kind: runtime"))
+	}
+	if ref.Origin != "" {
+		pkg.Supplier = fmt.Sprintf("Organization: %s", ref.Origin)
+	}
+
+	return pkg
+}
+
+func (g *Generator) annotation(comment string) spdx.Annotation {
+	return spdx.Annotation{
+		AnnotationDate: g.created,
+		AnnotationType: "OTHER",
+		Annotator:      version.Tool(),
+		Comment:        comment,
+	}
+}
+
+// buildPurl returns the flatpak's purl, qualified with its branch
+func buildPurl(ref Ref) string {
+	purl := "pkg:flatpak/" + ref.Application
+	if ref.Version != "" {
+		purl += "@" + url.PathEscape(ref.Version)
+	}
+	if ref.Branch != "" {
+		purl += "?branch=" + url.QueryEscape(ref.Branch)
+	}
+	return purl
+}
+
+var unsafeIDChars = regexp.MustCompile(`[^a-zA-Z0-9-.]`)
+
+func sanitizeName(name string) string {
+	// Replace non-alphanumeric characters with hyphens for SPDX IDs
+	return unsafeIDChars.ReplaceAllString(name, "-")
+}
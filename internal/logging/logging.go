@@ -0,0 +1,161 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log message
+type Level int
+
+const (
+	LevelInfo Level = iota
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// Diagnostics go to stderr so they never mix with a document or report
+// written to stdout
+var (
+	mu       sync.Mutex
+	output   io.Writer = os.Stderr
+	minLevel           = LevelInfo
+	jsonMode bool
+	// status is the transient line last drawn by Status, redrawn below
+	// every message written while it is shown
+	status string
+)
+
+// clearLine returns the cursor to the start of the line and erases it
+const clearLine = "\r\033[K"
+
+// SetOutput redirects log messages
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+}
+
+// SetQuiet suppresses everything but errors
+func SetQuiet(quiet bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if quiet {
+		minLevel = LevelError
+	} else {
+		minLevel = LevelInfo
+	}
+}
+
+// SetJSON writes each message as a JSON object on its own line, with time,
+// level and msg fields
+func SetJSON(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	jsonMode = enabled
+}
+
+// Terminal reports whether messages are written as plain text to a
+// terminal, where a status line can be redrawn in place
+func Terminal() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return terminal()
+}
+
+func terminal() bool {
+	file, ok := output.(*os.File)
+	if !ok || jsonMode || minLevel > LevelInfo {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Status replaces the status line at the bottom of the terminal with line,
+// or removes it when line is empty. It does nothing unless Terminal.
+func Status(line string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !terminal() {
+		return
+	}
+	if status != "" || line != "" {
+		fmt.Fprint(output, clearLine+line)
+	}
+	status = line
+}
+
+// Infof logs progress and results
+func Infof(format string, args ...any) {
+	logf(LevelInfo, format, args...)
+}
+
+// Warnf logs a problem the run continues past
+func Warnf(format string, args ...any) {
+	logf(LevelWarn, format, args...)
+}
+
+// Errorf logs a failure
+func Errorf(format string, args ...any) {
+	logf(LevelError, format, args...)
+}
+
+// Fatalf logs a failure and exits with status 1
+func Fatalf(format string, args ...any) {
+	logf(LevelError, format, args...)
+	Status("")
+	os.Exit(1)
+}
+
+func logf(level Level, format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if level < minLevel {
+		return
+	}
+
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+
+	if jsonMode {
+		line, _ := json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{time.Now().UTC().Format(time.RFC3339), level.String(), msg})
+		fmt.Fprintf(output, "%s\n", line)
+		return
+	}
+
+	switch level {
+	case LevelWarn:
+		msg = "Warning: " + msg
+	case LevelError:
+		msg = "Error: " + msg
+	}
+	if status != "" {
+		fmt.Fprint(output, clearLine)
+	}
+	fmt.Fprintln(output, msg)
+	if status != "" {
+		fmt.Fprint(output, status)
+	}
+}
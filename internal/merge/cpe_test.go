@@ -0,0 +1,70 @@
+package merge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+func TestFixCPEFormat(t *testing.T) {
+	m := NewMerger()
+	for _, tc := range []struct {
+		name, cpe, want string
+	}{
+		{"product:product:: collapse", "cpe:2.3:a:pg_cron:pg_cron::*:*:*:*:*:*:*", "cpe:2.3:a:pg-cron:pg-cron:*:*:*:*:*:*:*:*"},
+		{"version present", "cpe:2.3:a:hello:hello:2.12.1::", "cpe:2.3:a:hello:hello:2.12.1:*:*:*:*:*:*:*"},
+		{"vendor and product", "cpe:2.3:a:gnu:hello:2.12.1", "cpe:2.3:a:gnu:hello:2.12.1:*:*:*:*:*:*:*"},
+		{"characters replaced", "cpe:2.3:a:foo:bar baz!:1.0+dfsg", "cpe:2.3:a:foo:bar-baz:1.0-dfsg:*:*:*:*:*:*:*"},
+		{"wildcard survives sanitization", "cpe:2.3:a:foo*bar:foo*bar::", "cpe:2.3:a:foo*bar:foo*bar:*:*:*:*:*:*:*:*"},
+		{"not a CPE", "pkg:nix/hello@2.12.1", "pkg:nix/hello@2.12.1"},
+	} {
+		if got := m.fixCPEFormat(tc.cpe); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCleanExternalRefs(t *testing.T) {
+	m := NewMerger()
+	cpe := func(locator string) spdx.ExternalRef {
+		return spdx.ExternalRef{Category: "SECURITY", Type: "cpe23Type", Locator: locator}
+	}
+	purl := spdx.ExternalRef{Category: "PACKAGE-MANAGER", Type: "purl", Locator: "pkg:nix/hello@2.12.1"}
+
+	for _, tc := range []struct {
+		name string
+		refs []spdx.ExternalRef
+		want []spdx.ExternalRef
+	}{
+		{
+			"valid CPE kept as-is",
+			[]spdx.ExternalRef{cpe("cpe:2.3:a:gnu:hello:2.12.1:*:*:*:*:*:*:*"), purl},
+			[]spdx.ExternalRef{cpe("cpe:2.3:a:gnu:hello:2.12.1:*:*:*:*:*:*:*"), purl},
+		},
+		{
+			"product:product:: collapse repaired",
+			[]spdx.ExternalRef{cpe("cpe:2.3:a:pg_cron:pg_cron::*:*:*:*:*:*:*")},
+			[]spdx.ExternalRef{cpe("cpe:2.3:a:pg-cron:pg-cron:*:*:*:*:*:*:*:*")},
+		},
+		{
+			"version present repaired",
+			[]spdx.ExternalRef{cpe("cpe:2.3:a:hello:hello:2.12.1::")},
+			[]spdx.ExternalRef{cpe("cpe:2.3:a:hello:hello:2.12.1:*:*:*:*:*:*:*")},
+		},
+		{
+			"wildcard inside a component dropped",
+			[]spdx.ExternalRef{cpe("cpe:2.3:a:foo*bar:foo*bar::"), purl},
+			[]spdx.ExternalRef{purl},
+		},
+		{
+			"unknown part dropped",
+			[]spdx.ExternalRef{cpe("cpe:2.3:x:hello:hello::")},
+			[]spdx.ExternalRef{},
+		},
+	} {
+		if got := m.cleanExternalRefs("hello", tc.refs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package merge
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// Dedupe modes for packages installed both through apt and Nix
+const (
+	// DedupeLink keeps both copies and relates them as equivalent
+	DedupeLink = "link"
+	// DedupeDrop keeps only the Ubuntu copy, folding the Nix checksums
+	// into it
+	DedupeDrop = "drop"
+	// DedupeOff disables duplicate detection
+	DedupeOff = "off"
+)
+
+// repackSuffix matches Debian repack markers such as +dfsg, +ds1 or .dfsg
+var repackSuffix = regexp.MustCompile(`[+.~](dfsg|ds|repack)[0-9.]*$`)
+
+// dedupeKey identifies a component across sources by normalized name and
+// version. Only formatting differences are normalized, so distinct versions
+// of a package never share a key.
+func dedupeKey(name, version string) string {
+	if version == "" {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(name)) + "@" + strings.ToLower(version)
+}
+
+// debianUpstreamVersion strips the epoch, Debian revision and repack
+// suffix so a Debian version can be compared to an upstream one
+func debianUpstreamVersion(version string) string {
+	if _, rest, ok := strings.Cut(version, ":"); ok {
+		version = rest
+	}
+	if i := strings.LastIndex(version, "-"); i > 0 {
+		version = version[:i]
+	}
+	return repackSuffix.ReplaceAllString(version, "")
+}
+
+// equivalentRelationship records that two packages are the same component
+// installed twice. SPDX 2.3 has no EQUIVALENT type, so OTHER is used with
+// the meaning in the comment.
+func equivalentRelationship(nixID, ubuntuID string) spdx.Relationship {
+	return spdx.Relationship{
+		SPDXElementID:      nixID,
+		RelatedSPDXElement: ubuntuID,
+		RelationshipType:   "OTHER",
+		Comment:            "EQUIVALENT: same component installed through both apt and Nix",
+	}
+}
+
+// unionChecksums adds the duplicate's checksums to the survivor, one per
+// algorithm. When both carry the same algorithm with different values the
+// survivor's is kept and the mismatch is reported, since identical
+// components should hash identically.
+func unionChecksums(survivor *spdx.Package, duplicate spdx.Package) {
+	existing := make(map[string]string)
+	for _, checksum := range survivor.Checksums {
+		existing[checksum.Algorithm] = checksum.Value
+	}
+
+	for _, checksum := range duplicate.Checksums {
+		value, ok := existing[checksum.Algorithm]
+		if !ok {
+			survivor.Checksums = append(survivor.Checksums, checksum)
+			existing[checksum.Algorithm] = checksum.Value
+			continue
+		}
+		if !strings.EqualFold(value, checksum.Value) {
+			logging.Warnf("%s checksum conflict for %s %s: %s (Ubuntu) vs %s (Nix)",
+				checksum.Algorithm, survivor.Name, survivor.PackageVersion, value, checksum.Value)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package merge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// loadExtra reads an SPDX JSON document produced by another tool, such as
+// syft or trivy. Documents from other tools are not always well formed, so
+// rather than failing the merge, what cannot be mapped is skipped with a
+// warning:
+//
+//   - fields of the wrong JSON type are left empty
+//   - packages and files without an SPDXID are dropped
+//   - relationships missing an element, related element or type are dropped
+//   - external references without a type or locator are dropped, and the
+//     2.2 spelling of reference categories (PACKAGE_MANAGER) or a missing
+//     category is normalized
+func loadExtra(path string) (*spdx.Document, error) {
+	data, err := spdx.ReadDocumentBytes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Elements are decoded one by one so a bad field only affects its own
+	// element
+	var raw struct {
+		spdx.Document
+		Packages      []json.RawMessage `json:"packages"`
+		Files         []json.RawMessage `json:"files"`
+		Relationships []json.RawMessage `json:"relationships"`
+	}
+	if err := decodeLenient(data, &raw, path); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	doc := raw.Document
+
+	for i, data := range raw.Packages {
+		var pkg spdx.Package
+		if err := decodeLenient(data, &pkg, fmt.Sprintf("%s: package %d", path, i)); err != nil {
+			logging.Warnf("%s: skipping package %d: %v", path, i, err)
+			continue
+		}
+		if pkg.SPDXID == "" {
+			logging.Warnf("%s: skipping package %d (%s) without an SPDXID", path, i, pkg.Name)
+			continue
+		}
+		pkg.ExternalRefs = normalizeExtraRefs(path, pkg)
+		doc.Packages = append(doc.Packages, pkg)
+	}
+
+	for i, data := range raw.Files {
+		var file spdx.File
+		if err := decodeLenient(data, &file, fmt.Sprintf("%s: file %d", path, i)); err != nil {
+			logging.Warnf("%s: skipping file %d: %v", path, i, err)
+			continue
+		}
+		if file.SPDXID == "" {
+			logging.Warnf("%s: skipping file %d (%s) without an SPDXID", path, i, file.FileName)
+			continue
+		}
+		doc.Files = append(doc.Files, file)
+	}
+
+	for i, data := range raw.Relationships {
+		var rel spdx.Relationship
+		if err := decodeLenient(data, &rel, fmt.Sprintf("%s: relationship %d", path, i)); err != nil {
+			logging.Warnf("%s: skipping relationship %d: %v", path, i, err)
+			continue
+		}
+		if rel.SPDXElementID == "" || rel.RelatedSPDXElement == "" || rel.RelationshipType == "" {
+			logging.Warnf("%s: skipping incomplete relationship %d", path, i)
+			continue
+		}
+		doc.Relationships = append(doc.Relationships, rel)
+	}
+
+	return &doc, nil
+}
+
+// decodeLenient unmarshals data into v, which is filled in as far as
+// possible. A value of the wrong type is left out with a warning naming
+// where it was found; any other error is returned.
+func decodeLenient(data []byte, v interface{}, where string) error {
+	err := json.Unmarshal(data, v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		logging.Warnf("%s: ignoring %s, a JSON %s", where, typeErr.Field, typeErr.Value)
+		return nil
+	}
+	return err
+}
+
+// refCategories infers the category of an external reference that has
+// none from its type
+var refCategories = map[string]string{
+	"purl":      "PACKAGE-MANAGER",
+	"cpe22Type": "SECURITY",
+	"cpe23Type": "SECURITY",
+}
+
+// normalizeExtraRefs returns pkg's external references with 2.3 category
+// spellings, dropping those that cannot be used
+func normalizeExtraRefs(path string, pkg spdx.Package) []spdx.ExternalRef {
+	var refs []spdx.ExternalRef
+	for _, ref := range pkg.ExternalRefs {
+		if ref.Type == "" || ref.Locator == "" {
+			logging.Warnf("%s: dropping incomplete external reference of package %s", path, pkg.Name)
+			continue
+		}
+		ref.Category = strings.ToUpper(strings.ReplaceAll(ref.Category, "_", "-"))
+		if ref.Category == "" {
+			ref.Category = refCategories[ref.Type]
+			if ref.Category == "" {
+				ref.Category = "OTHER"
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+var labelPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*`)
+
+// extraLabel names an extra document's source after the tool that
+// created it ("Tool: syft-1.4.1" becomes Syft), then after its file name,
+// falling back to Extra
+func extraLabel(doc *spdx.Document, path string) string {
+	for _, creator := range doc.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(creator, "Tool:"); ok {
+			if label := labelPattern.FindString(strings.TrimSpace(tool)); label != "" {
+				return strings.ToUpper(label[:1]) + label[1:]
+			}
+		}
+	}
+	if label := labelPattern.FindString(filepath.Base(path)); label != "" {
+		return strings.ToUpper(label[:1]) + label[1:]
+	}
+	return "Extra"
+}
+
+// topLevelPackages returns the packages of an extra document to place
+// directly under SPDXRef-System: those the document describes, through
+// DESCRIBES relationships or documentDescribes, and those no relationship
+// mentions, which would otherwise be unreachable. When the document
+// describes none of its packages, nil is returned and all are placed
+// under SPDXRef-System.
+func topLevelPackages(doc *spdx.Document) map[string]bool {
+	packages := make(map[string]bool)
+	for _, pkg := range doc.Packages {
+		packages[pkg.SPDXID] = true
+	}
+
+	described := make(map[string]bool)
+	mentioned := make(map[string]bool)
+	for _, id := range doc.DocumentDescribes {
+		described[id] = true
+	}
+	for _, rel := range doc.Relationships {
+		if rel.SPDXElementID == doc.SPDXID && rel.RelationshipType == "DESCRIBES" {
+			described[rel.RelatedSPDXElement] = true
+			continue
+		}
+		mentioned[rel.SPDXElementID] = true
+		mentioned[rel.RelatedSPDXElement] = true
+	}
+
+	topLevel := make(map[string]bool)
+	for id := range described {
+		if packages[id] {
+			topLevel[id] = true
+		}
+	}
+	if len(topLevel) == 0 {
+		return nil
+	}
+	for id := range packages {
+		if !mentioned[id] {
+			topLevel[id] = true
+		}
+	}
+	return topLevel
+}
@@ -0,0 +1,249 @@
+package merge
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// syftDocument describes a directory package containing a and, below a, b.
+// Package c is in no relationship.
+const syftDocument = `{
+  "spdxVersion": "SPDX-2.3",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "name": "scan",
+  "creationInfo": {"created": "2024-05-01T00:00:00Z", "creators": ["Tool: syft-1.4.1"]},
+  "packages": [
+    {"SPDXID": "SPDXRef-DocumentRoot-Directory-app", "name": "/app"},
+    {"SPDXID": "SPDXRef-Package-a", "name": "a", "versionInfo": "1.0"},
+    {"SPDXID": "SPDXRef-Package-b", "name": "b", "versionInfo": "2.0"},
+    {"SPDXID": "SPDXRef-Package-c", "name": "c", "versionInfo": "3.0"}
+  ],
+  "relationships": [
+    {"spdxElementId": "SPDXRef-DOCUMENT", "relatedSpdxElement": "SPDXRef-DocumentRoot-Directory-app", "relationshipType": "DESCRIBES"},
+    {"spdxElementId": "SPDXRef-DocumentRoot-Directory-app", "relatedSpdxElement": "SPDXRef-Package-a", "relationshipType": "CONTAINS"},
+    {"spdxElementId": "SPDXRef-Package-a", "relatedSpdxElement": "SPDXRef-Package-b", "relationshipType": "DEPENDS_ON"}
+  ]
+}`
+
+// writeExtra writes an extra document to a temporary file
+func writeExtra(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "extra.spdx.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExtraLenient(t *testing.T) {
+	path := writeExtra(t, `{
+  "spdxVersion": "SPDX-2.3",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "name": ["not", "a", "string"],
+  "creationInfo": {"created": "2024-05-01T00:00:00Z", "creators": ["Tool: trivy-0.50.0"]},
+  "packages": [
+    {
+      "SPDXID": "SPDXRef-Package-a",
+      "name": "a",
+      "versionInfo": 1.2,
+      "filesAnalyzed": "yes",
+      "externalRefs": [
+        {"referenceCategory": "PACKAGE_MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/a@1.2"},
+        {"referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:a:a:1.2:*:*:*:*:*:*:*"},
+        {"referenceType": "swh", "referenceLocator": "swh:1:cnt:94a9ed024d3859793618152ea559a168bbcbb5e2"},
+        {"referenceCategory": "OTHER", "referenceType": "purl"}
+      ]
+    },
+    {"name": "no-id", "versionInfo": "1"},
+    {"SPDXID": "SPDXRef-Package-b", "name": "b", "versionInfo": "2.0"}
+  ],
+  "files": [
+    {"SPDXID": "SPDXRef-File-a", "fileName": "/a", "checksums": "none"},
+    {"fileName": "/no-id"}
+  ],
+  "relationships": [
+    {"spdxElementId": "SPDXRef-Package-a", "relationshipType": "CONTAINS"},
+    {"spdxElementId": "SPDXRef-Package-a", "relatedSpdxElement": "SPDXRef-Package-b", "relationshipType": 5},
+    {"spdxElementId": "SPDXRef-Package-a", "relatedSpdxElement": "SPDXRef-Package-b", "relationshipType": "DEPENDS_ON"}
+  ]
+}`)
+
+	doc, err := loadExtra(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Name != "" || len(doc.CreationInfo.Creators) != 1 {
+		t.Errorf("document name %q, creators %v: want the wrong-typed name left empty and the creators kept", doc.Name, doc.CreationInfo.Creators)
+	}
+
+	if len(doc.Packages) != 2 {
+		t.Fatalf("got %d packages, want a and b", len(doc.Packages))
+	}
+	a := doc.Packages[0]
+	if a.SPDXID != "SPDXRef-Package-a" || a.Name != "a" || a.PackageVersion != "" || a.FilesAnalyzed {
+		t.Errorf("package a decoded as %+v, want its wrong-typed version and filesAnalyzed left empty", a)
+	}
+	wantRefs := []spdx.ExternalRef{
+		{Category: "PACKAGE-MANAGER", Type: "purl", Locator: "pkg:npm/a@1.2"},
+		{Category: "SECURITY", Type: "cpe23Type", Locator: "cpe:2.3:a:a:a:1.2:*:*:*:*:*:*:*"},
+		{Category: "OTHER", Type: "swh", Locator: "swh:1:cnt:94a9ed024d3859793618152ea559a168bbcbb5e2"},
+	}
+	if !reflect.DeepEqual(a.ExternalRefs, wantRefs) {
+		t.Errorf("external references %+v, want %+v", a.ExternalRefs, wantRefs)
+	}
+
+	if len(doc.Files) != 1 || doc.Files[0].SPDXID != "SPDXRef-File-a" || doc.Files[0].FileName != "/a" {
+		t.Errorf("files %+v, want only /a", doc.Files)
+	}
+
+	wantRelationships := []spdx.Relationship{
+		{SPDXElementID: "SPDXRef-Package-a", RelatedSPDXElement: "SPDXRef-Package-b", RelationshipType: "DEPENDS_ON"},
+	}
+	if !reflect.DeepEqual(doc.Relationships, wantRelationships) {
+		t.Errorf("relationships %+v, want %+v", doc.Relationships, wantRelationships)
+	}
+}
+
+func TestTopLevelPackages(t *testing.T) {
+	packages := []spdx.Package{{SPDXID: "SPDXRef-root"}, {SPDXID: "SPDXRef-a"}, {SPDXID: "SPDXRef-b"}, {SPDXID: "SPDXRef-c"}}
+	contains := []spdx.Relationship{
+		{SPDXElementID: "SPDXRef-root", RelatedSPDXElement: "SPDXRef-a", RelationshipType: "CONTAINS"},
+		{SPDXElementID: "SPDXRef-a", RelatedSPDXElement: "SPDXRef-b", RelationshipType: "DEPENDS_ON"},
+	}
+	describes := spdx.Relationship{SPDXElementID: "SPDXRef-DOCUMENT", RelatedSPDXElement: "SPDXRef-root", RelationshipType: "DESCRIBES"}
+
+	for _, tc := range []struct {
+		name string
+		doc  spdx.Document
+		want []string
+	}{
+		{
+			"DESCRIBES relationship",
+			spdx.Document{SPDXID: "SPDXRef-DOCUMENT", Packages: packages, Relationships: append([]spdx.Relationship{describes}, contains...)},
+			[]string{"SPDXRef-c", "SPDXRef-root"},
+		},
+		{
+			"documentDescribes",
+			spdx.Document{SPDXID: "SPDXRef-DOCUMENT", DocumentDescribes: []string{"SPDXRef-root"}, Packages: packages, Relationships: contains},
+			[]string{"SPDXRef-c", "SPDXRef-root"},
+		},
+		{
+			"describes no package",
+			spdx.Document{SPDXID: "SPDXRef-DOCUMENT", DocumentDescribes: []string{"SPDXRef-File-1"}, Packages: packages, Relationships: contains},
+			nil,
+		},
+		{
+			"SPDX 2.2 document without relationships",
+			spdx.Document{SPDXVersion: "SPDX-2.2", SPDXID: "SPDXRef-DOCUMENT", Packages: packages},
+			nil,
+		},
+	} {
+		var got []string
+		for id := range topLevelPackages(&tc.doc) {
+			got = append(got, id)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: top-level packages %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// ubuntuDocument is a generated Ubuntu document holding bash
+func ubuntuDocument() *spdx.Document {
+	return &spdx.Document{
+		SPDXVersion:  "SPDX-2.3",
+		SPDXID:       "SPDXRef-DOCUMENT",
+		CreationInfo: spdx.CreationInfo{Creators: []string{"Tool: ubuntu-sbom-generator-1.0"}},
+		Packages: []spdx.Package{
+			{SPDXID: "SPDXRef-Ubuntu-System", Name: "Ubuntu-System"},
+			{SPDXID: "SPDXRef-Ubuntu-Package-1-bash", Name: "bash", PackageVersion: "5.1-6ubuntu1"},
+		},
+		Relationships: []spdx.Relationship{
+			{SPDXElementID: "SPDXRef-DOCUMENT", RelatedSPDXElement: "SPDXRef-Ubuntu-System", RelationshipType: "DESCRIBES"},
+			{SPDXElementID: "SPDXRef-Ubuntu-System", RelatedSPDXElement: "SPDXRef-Ubuntu-Package-1-bash", RelationshipType: "CONTAINS"},
+		},
+	}
+}
+
+func TestMergeExtraPlacement(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		extra     string
+		contained []string
+		nested    []string
+	}{
+		{
+			"described packages at the top",
+			syftDocument,
+			[]string{"SPDXRef-Syft-DocumentRoot-Directory-app", "SPDXRef-Syft-Package-c", "SPDXRef-Ubuntu-Package-1-bash"},
+			[]string{"SPDXRef-Syft-Package-a", "SPDXRef-Syft-Package-b"},
+		},
+		{
+			"SPDX 2.2 document without relationships",
+			`{
+  "spdxVersion": "SPDX-2.2",
+  "SPDXID": "SPDXRef-DOCUMENT",
+  "creationInfo": {"created": "2024-05-01T00:00:00Z", "creators": ["Tool: scanner-2"]},
+  "packages": [
+    {"SPDXID": "SPDXRef-1", "name": "a", "versionInfo": "1.0"},
+    {"SPDXID": "SPDXRef-2", "name": "b", "versionInfo": "2.0"}
+  ]
+}`,
+			[]string{"SPDXRef-Scanner-1", "SPDXRef-Scanner-2", "SPDXRef-Ubuntu-Package-1-bash"},
+			nil,
+		},
+	} {
+		m := NewMerger()
+		m.Extras = []string{writeExtra(t, tc.extra)}
+		doc, err := m.MergeDocuments(ubuntuDocument())
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		var contained []string
+		for _, rel := range doc.Relationships {
+			if rel.SPDXElementID == "SPDXRef-System" && rel.RelationshipType == "CONTAINS" {
+				contained = append(contained, rel.RelatedSPDXElement)
+			}
+		}
+		sort.Strings(contained)
+		if !reflect.DeepEqual(contained, tc.contained) {
+			t.Errorf("%s: root contains %v, want %v", tc.name, contained, tc.contained)
+		}
+		if !reflect.DeepEqual(doc.Nested, tc.nested) {
+			t.Errorf("%s: nested %v, want %v", tc.name, doc.Nested, tc.nested)
+		}
+		if err := spdx.CheckConsistency(doc); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+}
+
+func TestMergeExtraNestedNeedsRelationship(t *testing.T) {
+	m := NewMerger()
+	m.Extras = []string{writeExtra(t, syftDocument)}
+	doc, err := m.MergeDocuments(ubuntuDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a's DEPENDS_ON, b hangs below nothing
+	var kept []spdx.Relationship
+	for _, rel := range doc.Relationships {
+		if rel.RelationshipType != "DEPENDS_ON" {
+			kept = append(kept, rel)
+		}
+	}
+	doc.Relationships = kept
+
+	if err := spdx.CheckConsistency(doc); err == nil {
+		t.Error("b is not related to the root, want a consistency error")
+	}
+}
@@ -1,33 +1,165 @@
 package merge
 
 import (
-	"encoding/json"
+	"bytes"
+	"crypto/sha1"
 	"fmt"
-	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ubuntu-nix-sbom/internal/logging"
 	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/version"
 )
 
-type Merger struct{}
+type Merger struct {
+	// Dedupe controls how packages present in both sources are handled:
+	// DedupeLink (default), DedupeDrop or DedupeOff
+	Dedupe string
+
+	// Reproducible takes the creation time from SOURCE_DATE_EPOCH (or the
+	// Unix epoch) and derives the namespace from the merged package set
+	Reproducible bool
+
+	// Extras are SPDX JSON documents from other tools, such as syft,
+	// merged after the other sources. They are read leniently (see
+	// loadExtra), labelled after the tool that created them, and only
+	// the packages they describe are placed directly under
+	// SPDXRef-System.
+	Extras []string
+}
 
 func NewMerger() *Merger {
-	return &Merger{}
+	return &Merger{Dedupe: DedupeLink}
 }
 
+// Merge combines an Ubuntu and a Nix document under a single system root
 func (m *Merger) Merge(ubuntuPath, nixPath string) (*spdx.Document, error) {
-	// Load Ubuntu SBOM
-	ubuntuDoc, err := m.loadDocument(ubuntuPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load Ubuntu SBOM: %w", err)
+	return m.merge([]mergeInput{{path: ubuntuPath, fallback: "Ubuntu"}, {path: nixPath, fallback: "Nix"}})
+}
+
+// MergeAll combines any number of documents, such as a host's Ubuntu
+// document and the Nix closures of each service, under a single
+// SPDXRef-System root. The SPDXIDs of each source are prefixed with its
+// label (Ubuntu, Nix, ...), numbered when several sources share a label
+// (Nix, Nix2, ...), so packages from different files cannot collide.
+func (m *Merger) MergeAll(paths ...string) (*spdx.Document, error) {
+	inputs := make([]mergeInput, len(paths))
+	for i, path := range paths {
+		inputs[i] = mergeInput{path: path, fallback: "Source"}
+	}
+	return m.merge(inputs)
+}
+
+// MergeDocuments is MergeAll for documents already in memory. The
+// recorded source checksums are those of the documents' JSON encoding.
+func (m *Merger) MergeDocuments(docs ...*spdx.Document) (*spdx.Document, error) {
+	inputs := make([]mergeInput, len(docs))
+	for i, doc := range docs {
+		inputs[i] = mergeInput{doc: doc, fallback: "Source"}
+	}
+	return m.merge(inputs)
+}
+
+// mergeInput is a document to merge, given by path or already loaded, and
+// the label used when none can be derived from its contents
+type mergeInput struct {
+	path     string
+	doc      *spdx.Document
+	fallback string
+	extra    bool
+}
+
+// mergeSource is a loaded input document
+type mergeSource struct {
+	path   string
+	doc    *spdx.Document
+	label  string
+	prefix string
+	count  int
+
+	// topLevel holds the packages of an extra document placed under
+	// SPDXRef-System; when nil, every package is
+	topLevel map[string]bool
+}
+
+// name identifies the source in messages
+func (src *mergeSource) name() string {
+	if src.path != "" {
+		return src.path
+	}
+	return src.doc.Name
+}
+
+func (m *Merger) merge(inputs []mergeInput) (*spdx.Document, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no documents to merge")
+	}
+
+	for _, path := range m.Extras {
+		inputs = append(inputs, mergeInput{path: path, extra: true})
+	}
+
+	var sources []*mergeSource
+	var labels []string
+	labelCount := make(map[string]int)
+	for _, input := range inputs {
+		doc := input.doc
+		var err error
+		switch {
+		case doc != nil:
+		case input.extra:
+			if doc, err = loadExtra(input.path); err == nil {
+				input.fallback = extraLabel(doc, input.path)
+			}
+		default:
+			doc, err = spdx.Load(input.path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", input.path, err)
+		}
+		if err := spdx.CheckExpiry(doc, time.Now()); err != nil {
+			logging.Warnf("%v", err)
+		}
+
+		label := sourceLabel(doc, input.fallback)
+		labelCount[label]++
+		prefix := label
+		if n := labelCount[label]; n > 1 {
+			prefix += strconv.Itoa(n)
+		} else {
+			labels = append(labels, label)
+		}
+
+		src := &mergeSource{path: input.path, doc: doc, label: label, prefix: prefix}
+		if input.extra {
+			src.topLevel = topLevelPackages(doc)
+		}
+		sources = append(sources, src)
 	}
 
-	// Load Nix SBOM
-	nixDoc, err := m.loadDocument(nixPath)
+	// Ubuntu packages are indexed first so that copies of them in any
+	// other source are detected regardless of argument order
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].label == "Ubuntu" && sources[j].label != "Ubuntu"
+	})
+
+	createdAt, err := spdx.CreationTime(m.Reproducible)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load Nix SBOM: %w", err)
+		return nil, err
+	}
+	created := createdAt.Format(time.RFC3339)
+
+	// Name the result after the sources actually present rather than
+	// assuming Ubuntu+Nix
+	combinedName := strings.Join(labels, "-")
+
+	docs := make([]*spdx.Document, len(sources))
+	for i, src := range sources {
+		docs[i] = src.doc
 	}
 
 	// Create merged document
@@ -35,11 +167,11 @@ func (m *Merger) Merge(ubuntuPath, nixPath string) (*spdx.Document, error) {
 		SPDXVersion:       "SPDX-2.3",
 		DataLicense:       "CC0-1.0",
 		SPDXID:            "SPDXRef-DOCUMENT",
-		Name:              fmt.Sprintf("Ubuntu-Nix-System-SBOM-%s", time.Now().Format("2006-01-02")),
-		DocumentNamespace: fmt.Sprintf("https://sbom.ubuntu-nix.system/%s", generateUUID()),
+		Name:              fmt.Sprintf("%s-System-SBOM-%s", combinedName, createdAt.Format("2006-01-02")),
+		DocumentNamespace: fmt.Sprintf("https://sbom.%s.system/%s", strings.ToLower(combinedName), spdx.NewUUID()),
 		CreationInfo: spdx.CreationInfo{
-			Created:            time.Now().UTC().Format(time.RFC3339),
-			Creators:           m.mergeCreators(ubuntuDoc, nixDoc),
+			Created:            created,
+			Creators:           m.mergeCreators(docs...),
 			LicenseListVersion: "3.20",
 		},
 		Packages:      []spdx.Package{},
@@ -49,13 +181,13 @@ func (m *Merger) Merge(ubuntuPath, nixPath string) (*spdx.Document, error) {
 	// Create the single root System package
 	systemPkg := spdx.Package{
 		SPDXID:           "SPDXRef-System",
-		Name:             "Ubuntu-Nix-System",
+		Name:             combinedName + "-System",
 		DownloadLocation: "NOASSERTION",
 		FilesAnalyzed:    false,
 		LicenseConcluded: "NOASSERTION",
 		LicenseDeclared:  "NOASSERTION",
 		CopyrightText:    "NOASSERTION",
-		Description:      "Combined Ubuntu and Nix package system",
+		Description:      fmt.Sprintf("Combined %s package system", joinLabels(labels)),
 	}
 	mergedDoc.Packages = append(mergedDoc.Packages, systemPkg)
 
@@ -66,102 +198,293 @@ func (m *Merger) Merge(ubuntuPath, nixPath string) (*spdx.Document, error) {
 		RelationshipType:   "DESCRIBES",
 	})
 
-	// Process Ubuntu packages (skip the root package)
-	ubuntuCount := 0
-	for _, pkg := range ubuntuDoc.Packages {
-		if pkg.SPDXID == "SPDXRef-Ubuntu-System" || pkg.SPDXID == "SPDXRef-System" {
-			continue // Skip root packages
-		}
+	ubuntuIndex := make(map[string]int)
+	// owners maps each package SPDXID in the merged document to the
+	// package holding it, so that prefixed IDs that come out the same are
+	// told apart
+	owners := map[string]string{"SPDXRef-System": "the system root"}
+	taken := map[string]bool{"SPDXRef-System": true}
+	duplicates := 0
+	droppedRelationships := 0
+	for _, src := range sources {
+		// Record which tool produced each source so that per-package
+		// provenance survives the creators union
+		provenance := m.provenanceAnnotation(src.doc, created)
+		isUbuntu := src.label == "Ubuntu"
+
+		// idMap follows each source package to its merged SPDXID so the
+		// source's relationships can be carried over
+		idMap := make(map[string]string)
+
+		for _, pkg := range src.doc.Packages {
+			if isRootPackage(pkg) {
+				idMap[pkg.SPDXID] = "SPDXRef-System"
+				// The Ubuntu root identifies the OS release, which is
+				// what the merged system runs on
+				if isUbuntu {
+					mergedDoc.Packages[0].PackageVersion = pkg.PackageVersion
+					mergedDoc.Packages[0].ExternalRefs = pkg.ExternalRefs
+				}
+				continue
+			}
+			oldID := pkg.SPDXID
+
+			if !isUbuntu {
+				// Keep multi-output information in the purl before the
+				// ID changes
+				pkg.ExternalRefs = m.normalizeNixPurls(pkg, pkg.SPDXID)
+			}
+
+			// Ensure the SPDXID carries the source prefix to avoid conflicts
+			if !strings.HasPrefix(pkg.SPDXID, "SPDXRef-"+src.prefix+"-") {
+				pkg.SPDXID = m.renumberSPDXID(pkg.SPDXID, src.prefix)
+			}
+			if owner, ok := owners[pkg.SPDXID]; ok {
+				unique := spdx.UniqueID(pkg.SPDXID, taken)
+				logging.Warnf("%s package %s would be %s, already used by %s; using %s", src.label, packageLabel(pkg), pkg.SPDXID, owner, unique)
+				pkg.SPDXID = unique
+			}
+			idMap[oldID] = pkg.SPDXID
+
+			if !isUbuntu {
+				// Clean up invalid CPE references from sbomnix
+				pkg.ExternalRefs = m.cleanExternalRefs(pkg.Name, pkg.ExternalRefs)
+			}
 
-		// Ensure SPDXID has Ubuntu prefix
-		if !strings.HasPrefix(pkg.SPDXID, "SPDXRef-Ubuntu-") {
-			pkg.SPDXID = m.renumberSPDXID(pkg.SPDXID, "Ubuntu")
+			if provenance != nil {
+				pkg.Annotations = append(pkg.Annotations, *provenance)
+			}
+
+			if isUbuntu {
+				if key := dedupeKey(pkg.Name, debianUpstreamVersion(pkg.PackageVersion)); key != "" {
+					ubuntuIndex[key] = len(mergedDoc.Packages)
+				}
+			} else if i, ok := ubuntuIndex[dedupeKey(pkg.Name, pkg.PackageVersion)]; ok && m.Dedupe != DedupeOff {
+				duplicates++
+				if m.Dedupe == DedupeDrop {
+					unionChecksums(&mergedDoc.Packages[i], pkg)
+					idMap[oldID] = mergedDoc.Packages[i].SPDXID
+					continue
+				}
+				mergedDoc.Relationships = append(mergedDoc.Relationships, equivalentRelationship(pkg.SPDXID, mergedDoc.Packages[i].SPDXID))
+			}
+
+			mergedDoc.Packages = append(mergedDoc.Packages, pkg)
+			owners[pkg.SPDXID] = src.label + " package " + packageLabel(pkg)
+			taken[pkg.SPDXID] = true
+			src.count++
+
+			// Packages of an extra document below the ones it describes
+			// are reached through its own relationships
+			if src.topLevel != nil && !src.topLevel[oldID] {
+				mergedDoc.Nested = append(mergedDoc.Nested, pkg.SPDXID)
+				continue
+			}
+
+			// Add relationship to system root
+			mergedDoc.Relationships = append(mergedDoc.Relationships, spdx.Relationship{
+				SPDXElementID:      "SPDXRef-System",
+				RelatedSPDXElement: pkg.SPDXID,
+				RelationshipType:   "CONTAINS",
+			})
 		}
 
-		mergedDoc.Packages = append(mergedDoc.Packages, pkg)
+		droppedRelationships += m.carryRelationships(mergedDoc, src, idMap)
+	}
 
-		// Add relationship to system root
-		mergedDoc.Relationships = append(mergedDoc.Relationships, spdx.Relationship{
-			SPDXElementID:      "SPDXRef-System",
-			RelatedSPDXElement: pkg.SPDXID,
-			RelationshipType:   "CONTAINS",
-		})
-		ubuntuCount++
+	spdx.DefineLicenseRefs(mergedDoc, docs...)
+
+	// Source roots map onto SPDXRef-System, so their CONTAINS edges repeat
+	// the ones added above
+	mergedDoc.Relationships = dedupeRelationships(mergedDoc.Relationships)
+	if droppedRelationships > 0 {
+		logging.Warnf("dropped %d source relationships referring to elements not in the merged document", droppedRelationships)
 	}
 
-	// Process Nix packages (skip any root packages)
-	nixCount := 0
-	for _, pkg := range nixDoc.Packages {
-		// Skip root/system packages
-		if strings.Contains(strings.ToLower(pkg.Name), "system") &&
-			(pkg.SPDXID == "SPDXRef-DOCUMENT" || strings.HasSuffix(pkg.SPDXID, "-System")) {
-			continue
-		}
+	// Record the inputs as external documents so the merge can be audited
+	counts := make([]string, len(sources))
+	for i, src := range sources {
+		m.addSourceDocument(mergedDoc, "DocumentRef-"+src.prefix, src)
+		counts[i] = fmt.Sprintf("%d %s packages", src.count, src.prefix)
+	}
 
-		// Ensure SPDXID has Nix prefix to avoid conflicts
-		if !strings.HasPrefix(pkg.SPDXID, "SPDXRef-Nix-") {
-			pkg.SPDXID = m.renumberSPDXID(pkg.SPDXID, "Nix")
+	logging.Infof("Merged %s", joinLabels(counts))
+	if m.Dedupe != DedupeOff {
+		action := "linked"
+		if m.Dedupe == DedupeDrop {
+			action = "dropped non-Ubuntu copies"
 		}
+		logging.Infof("Detected %d packages present in Ubuntu and another source (%s)", duplicates, action)
+	}
+
+	if m.Reproducible {
+		mergedDoc.DocumentNamespace = fmt.Sprintf("https://sbom.%s.system/%s", strings.ToLower(combinedName), spdx.ContentUUID(mergedDoc))
+	}
 
-		// Clean up invalid CPE references from sbomnix
-		pkg.ExternalRefs = m.cleanExternalRefs(pkg.ExternalRefs)
+	return mergedDoc, nil
+}
 
-		mergedDoc.Packages = append(mergedDoc.Packages, pkg)
+// isRootPackage reports whether pkg is a source document's root that
+// stands for the system as a whole rather than installed software
+func isRootPackage(pkg spdx.Package) bool {
+	if pkg.SPDXID == "SPDXRef-System" || pkg.SPDXID == "SPDXRef-Ubuntu-System" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(pkg.Name), "system") &&
+		(pkg.SPDXID == "SPDXRef-DOCUMENT" || strings.HasSuffix(pkg.SPDXID, "-System"))
+}
 
-		// Add relationship to system root
-		mergedDoc.Relationships = append(mergedDoc.Relationships, spdx.Relationship{
-			SPDXElementID:      "SPDXRef-System",
-			RelatedSPDXElement: pkg.SPDXID,
-			RelationshipType:   "CONTAINS",
-		})
-		nixCount++
+// joinLabels joins items as "a", "a and b" or "a, b and c"
+func joinLabels(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
 	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
 
-	fmt.Printf("Merged %d Ubuntu packages and %d Nix packages\n", ubuntuCount, nixCount)
+func (m *Merger) mergeCreators(docs ...*spdx.Document) []string {
+	creatorMap := make(map[string]bool)
+	var creators []string
 
-	return mergedDoc, nil
+	// Add creators from every document
+	for _, doc := range docs {
+		for _, creator := range doc.CreationInfo.Creators {
+			if !creatorMap[creator] {
+				creators = append(creators, creator)
+				creatorMap[creator] = true
+			}
+		}
+	}
+
+	// Add this tool, unless the same build generated an input
+	if tool := version.Tool(); !creatorMap[tool] {
+		creators = append(creators, tool)
+	}
+
+	return creators
 }
 
-func (m *Merger) loadDocument(path string) (*spdx.Document, error) {
-	data, err := os.ReadFile(path)
+// addSourceDocument records an input document as an external document
+// reference, with the SHA1 of its JSON content, and relates the merged
+// document to it with GENERATED_FROM
+func (m *Merger) addSourceDocument(mergedDoc *spdx.Document, refID string, src *mergeSource) {
+	sourceDoc := src.doc
+	if sourceDoc.DocumentNamespace == "" {
+		logging.Warnf("%s has no documentNamespace, not recording it as a source document", src.name())
+		return
+	}
+
+	data, err := sourceBytes(src)
 	if err != nil {
-		return nil, err
+		logging.Warnf("failed to checksum source document %s: %v", src.name(), err)
+		return
+	}
+
+	mergedDoc.ExternalDocumentRefs = append(mergedDoc.ExternalDocumentRefs, spdx.ExternalDocumentRef{
+		ExternalDocumentID: refID,
+		SPDXDocument:       sourceDoc.DocumentNamespace,
+		Checksum: spdx.Checksum{
+			Algorithm: "SHA1",
+			Value:     fmt.Sprintf("%x", sha1.Sum(data)),
+		},
+	})
+
+	sourceID := sourceDoc.SPDXID
+	if sourceID == "" {
+		sourceID = "SPDXRef-DOCUMENT"
 	}
 
-	var doc spdx.Document
-	if err := json.Unmarshal(data, &doc); err != nil {
+	mergedDoc.Relationships = append(mergedDoc.Relationships, spdx.Relationship{
+		SPDXElementID:      mergedDoc.SPDXID,
+		RelatedSPDXElement: refID + ":" + sourceID,
+		RelationshipType:   "GENERATED_FROM",
+	})
+}
+
+// sourceBytes returns the JSON content of a source: the file as read, or
+// the encoding of an in-memory document
+func sourceBytes(src *mergeSource) ([]byte, error) {
+	if src.path != "" {
+		return spdx.ReadDocumentBytes(src.path)
+	}
+	var buf bytes.Buffer
+	if err := spdx.EncodeDocument(&buf, src.doc); err != nil {
 		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	return &doc, nil
+// creatorLabels maps substrings of known tool creators to source labels.
+// Current documents carry their label in the root ID instead; these cover
+// documents from other tools and earlier versions.
+var creatorLabels = []struct {
+	tool  string
+	label string
+}{
+	{"ubuntu-sbom-generator", "Ubuntu"},
+	{"sbomnix", "Nix"},
+	{"flatpak", "Flatpak"},
+	{"snap", "Snap"},
 }
 
-func (m *Merger) mergeCreators(ubuntuDoc, nixDoc *spdx.Document) []string {
-	creatorMap := make(map[string]bool)
-	var creators []string
+// sourceLabel derives a short name for the source a document describes:
+// from its root package's SPDXRef-<Label>-System ID, then from the tool in
+// its creators, falling back to the given label
+func sourceLabel(doc *spdx.Document, fallback string) string {
+	for _, rel := range doc.Relationships {
+		if rel.SPDXElementID != doc.SPDXID || rel.RelationshipType != "DESCRIBES" {
+			continue
+		}
+		label, ok := strings.CutPrefix(rel.RelatedSPDXElement, "SPDXRef-")
+		if !ok {
+			continue
+		}
+		if label, ok = strings.CutSuffix(label, "-System"); ok && label != "" && !strings.Contains(label, "-") {
+			return label
+		}
+	}
 
-	// Add creators from both documents
-	for _, creator := range ubuntuDoc.CreationInfo.Creators {
-		if !creatorMap[creator] {
-			creators = append(creators, creator)
-			creatorMap[creator] = true
+	for _, creator := range doc.CreationInfo.Creators {
+		creator = strings.ToLower(creator)
+		for _, known := range creatorLabels {
+			if strings.Contains(creator, known.tool) {
+				return known.label
+			}
 		}
 	}
 
-	for _, creator := range nixDoc.CreationInfo.Creators {
-		if !creatorMap[creator] {
-			creators = append(creators, creator)
-			creatorMap[creator] = true
+	return fallback
+}
+
+// provenanceAnnotation builds an annotation naming the tools that generated
+// a source document, or nil if the document lists no tool creators
+func (m *Merger) provenanceAnnotation(doc *spdx.Document, created string) *spdx.Annotation {
+	var tools []string
+	for _, creator := range doc.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(creator, "Tool:"); ok {
+			tools = append(tools, strings.TrimSpace(tool))
 		}
 	}
 
-	// Add merger tool
-	mergerTool := "Tool: ubuntu-nix-sbom-merger-1.0"
-	if !creatorMap[mergerTool] {
-		creators = append(creators, mergerTool)
+	if len(tools) == 0 {
+		return nil
 	}
 
-	return creators
+	return &spdx.Annotation{
+		AnnotationDate: created,
+		AnnotationType: "OTHER",
+		Annotator:      version.Tool(),
+		Comment:        fmt.Sprintf("generated-by: %s", strings.Join(tools, ", ")),
+	}
+}
+
+// packageLabel names a package in warnings, with its version when it has
+// one
+func packageLabel(pkg spdx.Package) string {
+	if pkg.PackageVersion == "" {
+		return pkg.Name
+	}
+	return pkg.Name + " " + pkg.PackageVersion
 }
 
 func (m *Merger) renumberSPDXID(originalID, prefix string) string {
@@ -178,24 +501,11 @@ func (m *Merger) renumberSPDXID(originalID, prefix string) string {
 	return fmt.Sprintf("SPDXRef-%s-%s", prefix, strings.TrimPrefix(originalID, "SPDXRef-"))
 }
 
-func (m *Merger) Save(doc *spdx.Document, outputPath string) error {
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-
-	return encoder.Encode(doc)
-}
-
-func (m *Merger) cleanExternalRefs(refs []spdx.ExternalRef) []spdx.ExternalRef {
-	// CPE 2.3 regex pattern - validates proper CPE format
-	// Format: cpe:2.3:part:vendor:product:version:update:edition:language:sw_edition:target_sw:target_hw:other
-	cpePattern := regexp.MustCompile(`^cpe:2\.3:[aho\*\-](:(((\?*|\*?)([a-zA-Z0-9\-\._]|(\\[\\\*\?!"#$%&'\(\)\+,\/:;<=>@\[\]\^` + "`" + `\{\|}~]))+(\?*|\*?))|[\*\-])){5}(:(([a-zA-Z]{2,3}(-([a-zA-Z]{2}|[0-9]{3}))?)|[\*\-]))(:(((\?*|\*?)([a-zA-Z0-9\-\._]|(\\[\\\*\?!"#$%&'\(\)\+,\/:;<=>@\[\]\^` + "`" + `\{\|}~]))+(\?*|\*?))|[\*\-])){4}$`)
+// cpePattern is the CPE 2.3 formatted string pattern from the SPDX spec
+// Format: cpe:2.3:part:vendor:product:version:update:edition:language:sw_edition:target_sw:target_hw:other
+var cpePattern = regexp.MustCompile(`^cpe:2\.3:[aho\*\-](:(((\?*|\*?)([a-zA-Z0-9\-\._]|(\\[\\\*\?!"#$%&'\(\)\+,\/:;<=>@\[\]\^` + "`" + `\{\|}~]))+(\?*|\*?))|[\*\-])){5}(:(([a-zA-Z]{2,3}(-([a-zA-Z]{2}|[0-9]{3}))?)|[\*\-]))(:(((\?*|\*?)([a-zA-Z0-9\-\._]|(\\[\\\*\?!"#$%&'\(\)\+,\/:;<=>@\[\]\^` + "`" + `\{\|}~]))+(\?*|\*?))|[\*\-])){4}$`)
 
+func (m *Merger) cleanExternalRefs(pkgName string, refs []spdx.ExternalRef) []spdx.ExternalRef {
 	cleaned := []spdx.ExternalRef{}
 	for _, ref := range refs {
 		// If it's a CPE reference, validate and fix it if needed
@@ -203,12 +513,20 @@ func (m *Merger) cleanExternalRefs(refs []spdx.ExternalRef) []spdx.ExternalRef {
 			if cpePattern.MatchString(ref.Locator) {
 				// Valid CPE, keep it as-is
 				cleaned = append(cleaned, ref)
-			} else {
-				// Invalid CPE, try to fix it
-				fixedCPE := m.fixCPEFormat(ref.Locator)
-				ref.Locator = fixedCPE
-				cleaned = append(cleaned, ref)
+				continue
 			}
+
+			// Invalid CPE, try to fix it. Some can't be repaired (an
+			// unknown part, a wildcard inside a component), and an
+			// invalid CPE fails the whole document in strict validators,
+			// so those are dropped.
+			fixedCPE := m.fixCPEFormat(ref.Locator)
+			if !cpePattern.MatchString(fixedCPE) {
+				logging.Warnf("dropping invalid CPE %q of package %s", ref.Locator, pkgName)
+				continue
+			}
+			ref.Locator = fixedCPE
+			cleaned = append(cleaned, ref)
 		} else {
 			// Not a CPE reference, keep it
 			cleaned = append(cleaned, ref)
@@ -307,14 +625,3 @@ func sanitizeCPEComponent(component string) string {
 
 	return component
 }
-
-func generateUUID() string {
-	// Simple UUID v4 generation
-	b := make([]byte, 16)
-	for i := range b {
-		b[i] = byte(time.Now().UnixNano() & 0xff)
-	}
-
-	return fmt.Sprintf("%x-%x-%x-%x-%x",
-		b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
-}
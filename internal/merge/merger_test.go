@@ -0,0 +1,103 @@
+package merge
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+func TestMergeCollidingIDs(t *testing.T) {
+	pkg := func(id, name string) spdx.Package {
+		return spdx.Package{SPDXID: id, Name: name, PackageVersion: "1.0", DownloadLocation: "NOASSERTION"}
+	}
+	rel := func(element, related, kind string) spdx.Relationship {
+		return spdx.Relationship{SPDXElementID: element, RelatedSPDXElement: related, RelationshipType: kind}
+	}
+
+	// Both sources hold a package whose ID already carries the prefix the
+	// other one is given
+	ubuntu := &spdx.Document{
+		SPDXID:       "SPDXRef-DOCUMENT",
+		CreationInfo: spdx.CreationInfo{Creators: []string{"Tool: ubuntu-sbom-generator"}},
+		Packages: []spdx.Package{
+			pkg("SPDXRef-Ubuntu-System", "Ubuntu-System"),
+			pkg("SPDXRef-bash", "bash"),
+			pkg("SPDXRef-Ubuntu-bash", "bash-completion"),
+		},
+		Relationships: []spdx.Relationship{
+			rel("SPDXRef-DOCUMENT", "SPDXRef-Ubuntu-System", "DESCRIBES"),
+			rel("SPDXRef-Ubuntu-System", "SPDXRef-bash", "CONTAINS"),
+			rel("SPDXRef-Ubuntu-System", "SPDXRef-Ubuntu-bash", "CONTAINS"),
+			rel("SPDXRef-Ubuntu-bash", "SPDXRef-bash", "DEPENDS_ON"),
+		},
+	}
+	nix := &spdx.Document{
+		SPDXID:       "SPDXRef-DOCUMENT",
+		CreationInfo: spdx.CreationInfo{Creators: []string{"Tool: sbomnix-1.4.5"}},
+		Packages: []spdx.Package{
+			pkg("SPDXRef-hello", "hello"),
+			pkg("SPDXRef-openssl", "openssl"),
+			pkg("SPDXRef-Nix-openssl", "openssl-dev"),
+		},
+		Relationships: []spdx.Relationship{
+			rel("SPDXRef-DOCUMENT", "SPDXRef-hello", "DESCRIBES"),
+			rel("SPDXRef-hello", "SPDXRef-openssl", "DEPENDS_ON"),
+			rel("SPDXRef-Nix-openssl", "SPDXRef-openssl", "DEPENDS_ON"),
+			rel("SPDXRef-openssl", "SPDXRef-Nix-openssl", "BUILD_DEPENDENCY_OF"),
+		},
+	}
+
+	m := NewMerger()
+	m.Reproducible = true
+	merged, err := m.MergeDocuments(ubuntu, nix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]string)
+	for _, p := range merged.Packages {
+		if _, ok := names[p.SPDXID]; ok {
+			t.Errorf("duplicate SPDXID %s", p.SPDXID)
+		}
+		names[p.SPDXID] = p.Name
+	}
+	wantIDs := map[string]string{
+		"SPDXRef-System":        "Ubuntu-Nix-System",
+		"SPDXRef-Ubuntu-bash":   "bash",
+		"SPDXRef-Ubuntu-bash-2": "bash-completion",
+		"SPDXRef-Nix-hello":     "hello",
+		"SPDXRef-Nix-openssl":   "openssl",
+		"SPDXRef-Nix-openssl-2": "openssl-dev",
+	}
+	if !reflect.DeepEqual(names, wantIDs) {
+		t.Errorf("packages %v, want %v", names, wantIDs)
+	}
+
+	// Relationships between the source packages, by package name
+	var got []string
+	for _, r := range merged.Relationships {
+		if r.SPDXElementID == "SPDXRef-DOCUMENT" || r.SPDXElementID == "SPDXRef-System" {
+			continue
+		}
+		got = append(got, names[r.SPDXElementID]+" "+r.RelationshipType+" "+names[r.RelatedSPDXElement])
+	}
+	sort.Strings(got)
+	want := []string{
+		"bash-completion DEPENDS_ON bash",
+		"hello DEPENDS_ON openssl",
+		"openssl BUILD_DEPENDENCY_OF openssl-dev",
+		"openssl-dev DEPENDS_ON openssl",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("relationships %q, want %q", got, want)
+	}
+
+	if err := spdx.CheckConsistency(merged); err != nil {
+		t.Error(err)
+	}
+	if _, err := spdx.CheckDuplicates(merged); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,94 @@
+package merge
+
+import (
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// nixOutputs are the conventional names of Nix multi-output derivation
+// outputs other than the default "out"
+var nixOutputs = map[string]bool{
+	"bin":     true,
+	"dev":     true,
+	"lib":     true,
+	"man":     true,
+	"doc":     true,
+	"info":    true,
+	"devdoc":  true,
+	"static":  true,
+	"debug":   true,
+	"modules": true,
+}
+
+// normalizeNixPurls keeps the derivation output of multi-output Nix
+// packages visible in their purls, so that foo:dev and foo:out stay
+// distinguishable. An output already carried as a subpath is preserved;
+// an "output" qualifier is moved into the subpath; otherwise the output is
+// taken from a store-path style "<name>-<version>-<output>" package ID.
+func (m *Merger) normalizeNixPurls(pkg spdx.Package, originalID string) []spdx.ExternalRef {
+	output := nixOutputFromID(originalID, pkg.PackageVersion)
+
+	refs := make([]spdx.ExternalRef, len(pkg.ExternalRefs))
+	copy(refs, pkg.ExternalRefs)
+
+	for i, ref := range refs {
+		if ref.Type != "purl" || !strings.HasPrefix(ref.Locator, "pkg:nix/") {
+			continue
+		}
+		refs[i].Locator = withNixOutput(ref.Locator, output)
+	}
+
+	return refs
+}
+
+// withNixOutput returns purl with output as its subpath unless it already
+// has one
+func withNixOutput(purl, output string) string {
+	base, subpath, hasSubpath := strings.Cut(purl, "#")
+	if hasSubpath && subpath != "" {
+		return purl
+	}
+
+	path, query, hasQuery := strings.Cut(base, "?")
+	if hasQuery {
+		var kept []string
+		for _, pair := range strings.Split(query, "&") {
+			if key, value, ok := strings.Cut(pair, "="); ok && key == "output" {
+				if output == "" {
+					output = value
+				}
+				continue
+			}
+			kept = append(kept, pair)
+		}
+		base = path
+		if len(kept) > 0 {
+			base += "?" + strings.Join(kept, "&")
+		}
+	}
+
+	if output == "" || output == "out" {
+		return base
+	}
+	return base + "#" + output
+}
+
+// nixOutputFromID extracts the output name from an SPDXID derived from a
+// store path name such as SPDXRef-openssl-3.0.13-dev
+func nixOutputFromID(spdxID, version string) string {
+	if version == "" {
+		return ""
+	}
+
+	idx := strings.LastIndex(spdxID, "-"+version+"-")
+	if idx == -1 {
+		return ""
+	}
+
+	output := spdxID[idx+len(version)+2:]
+	if nixOutputs[output] {
+		return output
+	}
+	return ""
+}
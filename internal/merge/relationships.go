@@ -0,0 +1,76 @@
+package merge
+
+import (
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// carryRelationships copies a source's files and relationships into the
+// merged document, re-pointing their SPDXIDs through idMap (the source's
+// old package IDs to their merged ones). Relationship types are kept as
+// they are, so sbomnix's distinction between runtime (DEPENDS_ON) and
+// build-time (BUILD_DEPENDENCY_OF, BUILD_TOOL_OF) dependencies survives.
+// Relationships of the source document itself, or with an element that
+// is not in the merged document, are dropped; their number is returned.
+func (m *Merger) carryRelationships(mergedDoc *spdx.Document, src *mergeSource, idMap map[string]string) int {
+	for _, file := range src.doc.Files {
+		oldID := file.SPDXID
+		if !strings.HasPrefix(file.SPDXID, "SPDXRef-"+src.prefix+"-") {
+			file.SPDXID = m.renumberSPDXID(file.SPDXID, src.prefix)
+		}
+		idMap[oldID] = file.SPDXID
+		mergedDoc.Files = append(mergedDoc.Files, file)
+	}
+
+	dropped := 0
+	for _, rel := range src.doc.Relationships {
+		if rel.SPDXElementID == "SPDXRef-DOCUMENT" {
+			continue
+		}
+
+		element, ok := idMap[rel.SPDXElementID]
+		related, relatedOK := mappedElement(rel.RelatedSPDXElement, idMap)
+		if !ok || !relatedOK {
+			dropped++
+			continue
+		}
+
+		rel.SPDXElementID = element
+		rel.RelatedSPDXElement = related
+		mergedDoc.Relationships = append(mergedDoc.Relationships, rel)
+	}
+	return dropped
+}
+
+// mappedElement returns the merged ID of a related element. NONE and
+// NOASSERTION are not elements and pass through.
+func mappedElement(id string, idMap map[string]string) (string, bool) {
+	if id == "NONE" || id == "NOASSERTION" {
+		return id, true
+	}
+	mapped, ok := idMap[id]
+	return mapped, ok
+}
+
+// dedupeRelationships removes repeated relationships, keyed on element,
+// related element and type, keeping the first. A comment carried by a
+// later copy is kept when the first has none.
+func dedupeRelationships(relationships []spdx.Relationship) []spdx.Relationship {
+	type key struct{ element, related, kind string }
+
+	index := make(map[key]int)
+	deduped := relationships[:0]
+	for _, rel := range relationships {
+		k := key{rel.SPDXElementID, rel.RelatedSPDXElement, rel.RelationshipType}
+		if i, ok := index[k]; ok {
+			if deduped[i].Comment == "" {
+				deduped[i].Comment = rel.Comment
+			}
+			continue
+		}
+		index[k] = len(deduped)
+		deduped = append(deduped, rel)
+	}
+	return deduped
+}
@@ -0,0 +1,81 @@
+package merge
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/version"
+)
+
+// Supplement adds the packages of a hand-maintained SPDX document to doc
+// under doc's root package. These cover software no package manager knows
+// about (vendored binaries, manual installs), so each is annotated as
+// manually declared and given a Manual-prefixed SPDXID that doesn't
+// collide with the existing packages.
+func (m *Merger) Supplement(doc *spdx.Document, supplementPath string) error {
+	supplement, err := spdx.Load(supplementPath)
+	if err != nil {
+		return fmt.Errorf("failed to load supplementary SBOM: %w", err)
+	}
+
+	rootID := describedRoot(doc)
+	if rootID == "" {
+		return fmt.Errorf("document has no root package to attach supplementary packages to")
+	}
+
+	existing := make(map[string]bool)
+	for _, pkg := range doc.Packages {
+		existing[pkg.SPDXID] = true
+	}
+
+	supplementRoots := make(map[string]bool)
+	for _, rel := range supplement.Relationships {
+		if rel.SPDXElementID == supplement.SPDXID && rel.RelationshipType == "DESCRIBES" {
+			supplementRoots[rel.RelatedSPDXElement] = true
+		}
+	}
+
+	annotation := spdx.Annotation{
+		AnnotationDate: doc.CreationInfo.Created,
+		AnnotationType: "OTHER",
+		Annotator:      version.Tool(),
+		Comment:        fmt.Sprintf("source: manually-declared (%s)", filepath.Base(supplementPath)),
+	}
+
+	count := 0
+	for _, pkg := range supplement.Packages {
+		// A root that merely groups the manual packages is not itself
+		// installed software; its children are added instead
+		if supplementRoots[pkg.SPDXID] && len(supplement.Packages) > 1 {
+			continue
+		}
+
+		pkg.SPDXID = spdx.UniqueID(m.renumberSPDXID(pkg.SPDXID, "Manual"), existing)
+		existing[pkg.SPDXID] = true
+		pkg.Annotations = append(pkg.Annotations, annotation)
+
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdx.Relationship{
+			SPDXElementID:      rootID,
+			RelatedSPDXElement: pkg.SPDXID,
+			RelationshipType:   "CONTAINS",
+		})
+		count++
+	}
+	spdx.DefineLicenseRefs(doc, supplement)
+
+	logging.Infof("Added %d manually-declared packages from %s", count, supplementPath)
+	return nil
+}
+
+// describedRoot returns the package the document DESCRIBES
+func describedRoot(doc *spdx.Document) string {
+	for _, rel := range doc.Relationships {
+		if rel.SPDXElementID == doc.SPDXID && rel.RelationshipType == "DESCRIBES" {
+			return rel.RelatedSPDXElement
+		}
+	}
+	return ""
+}
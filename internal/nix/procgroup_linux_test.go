@@ -0,0 +1,72 @@
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// alive reports whether pid is a running process, counting zombies as
+// gone since nothing may be left to reap them
+func alive(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	_, rest, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(rest, "Z")
+}
+
+func TestRunInterruptKillsProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	sbomnix := filepath.Join(dir, "sbomnix")
+	script := "#!/bin/sh\nsleep 60 &\necho $! > " + pidFile + ".tmp\nmv " + pidFile + ".tmp " + pidFile + "\nwait\n"
+	if err := os.WriteFile(sbomnix, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Wrapper{}
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.run(sbomnix, "/nix/store/x.drv", filepath.Join(dir, "out.json"))
+		done <- err
+	}()
+
+	var pid int
+	for deadline := time.Now().Add(10 * time.Second); pid == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("sbomnix did not start its child")
+		}
+		data, err := os.ReadFile(pidFile)
+		if err == nil {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The signal is caught by run while sbomnix is running
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "interrupted") {
+			t.Errorf("got %v, want an interruption", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("sbomnix still running after SIGINT")
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); alive(pid); {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("sbomnix's child survived the interrupt")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
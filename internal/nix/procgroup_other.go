@@ -0,0 +1,9 @@
+//go:build !unix
+
+package nix
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups are unavailable;
+// cancelling kills sbomnix only
+func killProcessGroup(cmd *exec.Cmd) {}
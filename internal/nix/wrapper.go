@@ -1,35 +1,247 @@
 package nix
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
 )
 
 type Wrapper struct {
 	SbomnixPath string
+
+	// Retries is how many more times sbomnix is run after a failure that
+	// looks transient, such as the Nix daemon being unreachable
+	Retries int
+
+	// RetryDelay is the wait before the first retry, doubled for each
+	// further one
+	RetryDelay time.Duration
+
+	// Timeout kills an sbomnix run that takes longer; zero means no limit
+	Timeout time.Duration
 }
 
 func NewWrapper(sbomnixPath string) *Wrapper {
 	return &Wrapper{
 		SbomnixPath: sbomnixPath,
+		RetryDelay:  2 * time.Second,
 	}
 }
 
+// transientErrors are sbomnix (and nix) messages of failures that may go
+// away on their own
+var transientErrors = []string{
+	"unable to connect to the Nix daemon",
+	"cannot connect to daemon",
+	"Connection reset by peer",
+	"Resource temporarily unavailable",
+}
+
+// stderrTailSize bounds how much sbomnix stderr is kept to classify a
+// failure
+const stderrTailSize = 64 * 1024
+
+// Generate runs sbomnix for derivationPath and stores its document at
+// outputPath ("-" for stdout)
 func (w *Wrapper) Generate(derivationPath, outputPath string) error {
+	_, err := w.generate(derivationPath, outputPath)
+	return err
+}
+
+func (w *Wrapper) generate(derivationPath, outputPath string) (*spdx.Document, error) {
 	// Validate derivation path exists
 	if _, err := os.Stat(derivationPath); err != nil {
-		return fmt.Errorf("derivation path does not exist: %s", derivationPath)
+		return nil, fmt.Errorf("derivation path does not exist: %s", derivationPath)
+	}
+
+	// sbomnix can only write to a file, so stream a temporary one to stdout
+	if outputPath == spdx.StdoutPath {
+		tmpDir, err := os.MkdirTemp("", "sbom-nix-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		tmpPath := filepath.Join(tmpDir, "nix-sbom.spdx.json")
+		doc, err := w.generate(derivationPath, tmpPath)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := os.Open(tmpPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return doc, spdx.WriteFileAtomic(spdx.StdoutPath, func(out io.Writer) error {
+			_, err := io.Copy(out, file)
+			return err
+		})
+	}
+
+	sbomnix, err := exec.LookPath(w.SbomnixPath)
+	if err != nil {
+		return nil, fmt.Errorf("sbomnix not found at %q: install sbomnix (https://github.com/tiiuae/sbomnix) or pass its location with --sbomnix-path", w.SbomnixPath)
+	}
+
+	// sbomnix writes its document in place as it goes, so it writes next
+	// to outputPath and the result is renamed over it once verified; an
+	// interrupted or failed run never replaces a previous good document
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	delay := w.RetryDelay
+	for attempt := 0; ; attempt++ {
+		transient, err := w.run(sbomnix, derivationPath, tmpPath)
+		if err == nil {
+			doc, err := verifyOutput(tmpPath)
+			if err != nil {
+				return nil, err
+			}
+			if err := os.Chmod(tmpPath, 0o644); err != nil {
+				return nil, err
+			}
+			return doc, os.Rename(tmpPath, outputPath)
+		}
+		if !transient || attempt >= w.Retries {
+			return nil, err
+		}
+
+		logging.Warnf("%v, retrying in %s (%d/%d)", err, delay, attempt+1, w.Retries)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// run executes sbomnix once, streaming its output to stderr. It reports
+// whether a failure looks transient.
+func (w *Wrapper) run(sbomnix, derivationPath, outputPath string) (bool, error) {
+	// sbomnix runs in its own process group, out of reach of the terminal's
+	// Ctrl-C, so an interrupt is passed on by cancelling it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, sbomnix, derivationPath, fmt.Sprintf("--spdx=%s", outputPath))
+	// sbomnix progress is diagnostics, keep it off stdout
+	stderr := &tailBuffer{limit: stderrTailSize}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	killProcessGroup(cmd)
+	// Don't wait forever on output pipes held open by processes that
+	// outlive a killed sbomnix
+	cmd.WaitDelay = 5 * time.Second
+
+	err := cmd.Run()
+	if err == nil {
+		return false, nil
 	}
 
-	// Call sbomnix
-	cmd := exec.Command(w.SbomnixPath, derivationPath, fmt.Sprintf("--spdx=%s", outputPath))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return false, fmt.Errorf("sbomnix timed out after %s", w.Timeout)
+	case context.Canceled:
+		return false, errors.New("sbomnix interrupted")
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("sbomnix failed: %w", err)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		failure := &ExitError{Status: exitErr.ExitCode()}
+		output := stderr.String()
+		for _, message := range transientErrors {
+			if strings.Contains(output, message) {
+				failure.Transient = message
+				break
+			}
+		}
+		return failure.Transient != "", failure
 	}
 
-	return nil
+	return false, fmt.Errorf("sbomnix failed: %w", err)
+}
+
+// ExitError is an sbomnix run that exited unsuccessfully
+type ExitError struct {
+	// Status is sbomnix's exit status
+	Status int
+	// Transient is the recognized message of a failure worth retrying,
+	// empty otherwise
+	Transient string
+}
+
+func (e *ExitError) Error() string {
+	if e.Transient != "" {
+		return fmt.Sprintf("sbomnix exited with status %d (%s)", e.Status, e.Transient)
+	}
+	return fmt.Sprintf("sbomnix exited with status %d", e.Status)
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
+
+// GenerateDocument runs sbomnix for derivationPath and returns the
+// resulting document
+func (w *Wrapper) GenerateDocument(derivationPath string) (*spdx.Document, error) {
+	tmpDir, err := os.MkdirTemp("", "sbom-nix-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	return w.generate(derivationPath, filepath.Join(tmpDir, "nix-sbom.spdx.json"))
+}
+
+// verifyOutput checks that a successful sbomnix run really wrote an SPDX
+// document to path, so a bad run fails here rather than confusingly
+// during a later merge
+func verifyOutput(path string) (*spdx.Document, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("sbomnix succeeded but wrote no document to %s: %w", path, err)
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("sbomnix succeeded but %s is empty", path)
+	}
+
+	doc, err := spdx.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("sbomnix wrote malformed SPDX: %w", err)
+	}
+	return doc, nil
 }
@@ -0,0 +1,105 @@
+package progress
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+)
+
+// Interval is how many items pass between progress lines when no bar is
+// drawn
+const Interval = 100
+
+// redrawInterval limits how often a bar is redrawn, so that loops over
+// thousands of cheap items are not slowed down by the terminal
+const redrawInterval = 100 * time.Millisecond
+
+const barWidth = 30
+
+// Bar reports how far a loop over a known number of items has got. When
+// bars are enabled and log messages go to a terminal, it draws a bar with
+// the percentage done, the rate and the estimated time left on the last
+// line of stderr. Otherwise it logs a line every Interval items. Add may
+// be called from several goroutines.
+type Bar struct {
+	label string
+	unit  string
+	total int
+	bar   bool
+	start time.Time
+
+	mu    sync.Mutex
+	done  int
+	drawn time.Time
+}
+
+// New starts reporting progress over total items, counted in unit
+// ("packages"), under label ("Hashing files"). With bar false, progress is
+// only ever logged as lines.
+func New(label, unit string, total int, bar bool) *Bar {
+	return &Bar{
+		label: label,
+		unit:  unit,
+		total: total,
+		bar:   bar && logging.Terminal(),
+		start: time.Now(),
+	}
+}
+
+// Add records n more items as done
+func (b *Bar) Add(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous := b.done
+	b.done += n
+
+	if !b.bar {
+		if previous/Interval != b.done/Interval {
+			logging.Infof("%s: %d/%d %s...", b.label, b.done, b.total, b.unit)
+		}
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(b.drawn) < redrawInterval && b.done < b.total {
+		return
+	}
+	b.drawn = now
+	logging.Status(b.render(now))
+}
+
+// Done removes the bar once the loop has finished
+func (b *Bar) Done() {
+	if b.bar {
+		logging.Status("")
+	}
+}
+
+// render formats the bar, e.g.
+// "Hashing files [=======>      ]  27% 340/1250 packages, 85.2/s, ETA 11s"
+func (b *Bar) render(now time.Time) string {
+	fraction := 1.0
+	if b.total > 0 {
+		fraction = float64(b.done) / float64(b.total)
+	}
+	filled := int(fraction * barWidth)
+
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+
+	line := fmt.Sprintf("%s [%s] %3.0f%% %d/%d %s", b.label, bar, fraction*100, b.done, b.total, b.unit)
+
+	elapsed := now.Sub(b.start).Seconds()
+	if elapsed <= 0 || b.done == 0 {
+		return line
+	}
+	rate := float64(b.done) / elapsed
+	left := time.Duration(float64(b.total-b.done) / rate * float64(time.Second))
+	return fmt.Sprintf("%s, %.1f/s, ETA %s", line, rate, left.Round(time.Second))
+}
@@ -0,0 +1,382 @@
+package python
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/version"
+)
+
+// Distribution is an installed Python distribution, read from its
+// .dist-info or .egg-info metadata
+type Distribution struct {
+	Name     string
+	Version  string
+	Summary  string
+	HomePage string
+	// License is the SPDX expression of the declared license
+	License string
+	// Path is the metadata directory or file the distribution was read from
+	Path string
+}
+
+type Generator struct {
+	// Paths are the site-packages directories scanned. When empty they
+	// are taken from `python3 -m site`.
+	Paths []string
+
+	// PythonPath is the interpreter asked for the default Paths, looked up
+	// in PATH unless it contains a slash
+	PythonPath string
+
+	// Reproducible takes the creation time from SOURCE_DATE_EPOCH (or the
+	// Unix epoch) and derives the namespace from the distribution set
+	Reproducible bool
+}
+
+func NewGenerator() *Generator {
+	return &Generator{PythonPath: "python3"}
+}
+
+// Generate builds a document of the Python distributions installed in the
+// site-packages directories under a SPDXRef-Python-System root
+func (g *Generator) Generate() (*spdx.Document, error) {
+	paths := g.Paths
+	if len(paths) == 0 {
+		paths = g.defaultPaths()
+	}
+
+	var dists []Distribution
+	for _, path := range paths {
+		found, err := scanSitePackages(path)
+		if err != nil {
+			return nil, err
+		}
+		dists = append(dists, found...)
+	}
+
+	created, err := spdx.CreationTime(g.Reproducible)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &spdx.Document{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              fmt.Sprintf("Python-System-SBOM-%s", created.Format("2006-01-02")),
+		DocumentNamespace: fmt.Sprintf("https://sbom.python.system/%s", spdx.NewUUID()),
+		CreationInfo: spdx.CreationInfo{
+			Created:            created.Format(time.RFC3339),
+			Creators:           []string{version.Tool()},
+			LicenseListVersion: "3.20",
+		},
+		Packages: []spdx.Package{{
+			SPDXID:           "SPDXRef-Python-System",
+			Name:             "Python-System",
+			DownloadLocation: "NOASSERTION",
+			FilesAnalyzed:    false,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+		}},
+		Relationships: []spdx.Relationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelatedSPDXElement: "SPDXRef-Python-System",
+			RelationshipType:   "DESCRIBES",
+		}},
+	}
+
+	// The same distribution can be installed in several directories
+	taken := map[string]bool{"SPDXRef-Python-System": true}
+	for _, dist := range dists {
+		pkg := distributionToSPDX(dist)
+		pkg.SPDXID = spdx.UniqueID(pkg.SPDXID, taken)
+		taken[pkg.SPDXID] = true
+
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdx.Relationship{
+			SPDXElementID:      "SPDXRef-Python-System",
+			RelatedSPDXElement: pkg.SPDXID,
+			RelationshipType:   "CONTAINS",
+		})
+	}
+
+	spdx.DefineLicenseRefs(doc)
+
+	if g.Reproducible {
+		doc.DocumentNamespace = fmt.Sprintf("https://sbom.python.system/%s", spdx.ContentUUID(doc))
+	}
+
+	logging.Infof("Found %d Python distributions in %d directories", len(dists), len(paths))
+	return doc, nil
+}
+
+// dpkgSitePackages is where Debian and Ubuntu packages install Python
+// modules; they are already described by the dpkg database
+const dpkgSitePackages = "/usr/lib/python3/dist-packages"
+
+// defaultPaths returns the site-packages directories on the sys.path
+// printed by `python3 -m site`, except the one owned by dpkg. Without a
+// Python interpreter there are none.
+func (g *Generator) defaultPaths() []string {
+	python, err := exec.LookPath(g.PythonPath)
+	if err != nil {
+		logging.Warnf("%s not found, no Python distributions included", g.PythonPath)
+		return nil
+	}
+
+	output, err := exec.Command(python, "-m", "site").Output()
+	if err != nil {
+		logging.Warnf("%s -m site failed, no Python distributions included: %v", g.PythonPath, err)
+		return nil
+	}
+	return sitePackagesPaths(output)
+}
+
+// sitePackagesPaths reads the quoted sys.path entries of `python -m site`
+// output:
+//
+//	sys.path = [
+//	    '/usr/lib/python3.10',
+//	    '/usr/local/lib/python3.10/dist-packages',
+//	]
+func sitePackagesPaths(output []byte) []string {
+	var paths []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		path, ok := strings.CutPrefix(line, "'")
+		if !ok {
+			continue
+		}
+		path, ok = strings.CutSuffix(path, "',")
+		if !ok {
+			continue
+		}
+
+		base := filepath.Base(path)
+		if (base != "site-packages" && base != "dist-packages") || path == dpkgSitePackages {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// scanSitePackages reads every distribution installed in dir: wheels and
+// modern installs leave a <name>-<version>.dist-info directory with a
+// METADATA file, setuptools a .egg-info directory with PKG-INFO or a
+// plain .egg-info file. A missing directory has no distributions.
+func scanSitePackages(dir string) ([]Distribution, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		logging.Warnf("Python path %s does not exist", dir)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Python path %s: %w", dir, err)
+	}
+
+	var dists []Distribution
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		var metadataPath string
+		switch {
+		case strings.HasSuffix(entry.Name(), ".dist-info") && entry.IsDir():
+			metadataPath = filepath.Join(path, "METADATA")
+		case strings.HasSuffix(entry.Name(), ".egg-info") && entry.IsDir():
+			metadataPath = filepath.Join(path, "PKG-INFO")
+		case strings.HasSuffix(entry.Name(), ".egg-info"):
+			metadataPath = path
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(metadataPath)
+		if err != nil {
+			logging.Warnf("skipping %s: %v", path, err)
+			continue
+		}
+
+		dist := parseMetadata(data)
+		if dist.Name == "" {
+			logging.Warnf("skipping %s: no Name in metadata", path)
+			continue
+		}
+		dist.Path = path
+		dists = append(dists, dist)
+	}
+
+	sort.SliceStable(dists, func(i, j int) bool {
+		return normalizeName(dists[i].Name) < normalizeName(dists[j].Name)
+	})
+	return dists, nil
+}
+
+// parseMetadata reads the RFC 822 style header of a core metadata file;
+// the description body after the first blank line is ignored
+func parseMetadata(data []byte) Distribution {
+	fields := make(map[string][]string)
+	var last string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		// Continuation lines of a multi-line value, such as a License
+		// holding the whole license text
+		if (line[0] == ' ' || line[0] == '\t') && last != "" {
+			values := fields[last]
+			values[len(values)-1] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		last = key
+		fields[key] = append(fields[key], strings.TrimSpace(value))
+	}
+
+	first := func(key string) string {
+		if values := fields[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	dist := Distribution{
+		Name:     first("Name"),
+		Version:  first("Version"),
+		Summary:  first("Summary"),
+		HomePage: first("Home-page"),
+	}
+	if dist.HomePage == "UNKNOWN" {
+		dist.HomePage = ""
+	}
+	dist.License = declaredLicense(first("License-Expression"), first("License"), fields["Classifier"])
+	return dist
+}
+
+// declaredLicense picks the best license statement of a distribution: a
+// License-Expression (already SPDX), a License field that maps to SPDX,
+// then the trove license classifiers, alternatives of each other
+func declaredLicense(expression, license string, classifiers []string) string {
+	if expression != "" {
+		if normalized := spdx.NormalizeLicense(expression); normalized != "NOASSERTION" {
+			return normalized
+		}
+	}
+
+	if license != "" && license != "UNKNOWN" {
+		if normalized := spdx.NormalizeLicense(license); normalized != "NOASSERTION" {
+			return normalized
+		}
+	}
+
+	var names []string
+	for _, classifier := range classifiers {
+		name, ok := strings.CutPrefix(classifier, "License :: ")
+		if !ok {
+			continue
+		}
+		// "OSI Approved :: MIT License" names the license last
+		name = name[strings.LastIndex(name, "::")+1:]
+		name = strings.TrimSpace(strings.TrimPrefix(name, ":"))
+		if short, ok := classifierLicenses[name]; ok {
+			names = append(names, short)
+		}
+	}
+	if len(names) == 0 {
+		return "NOASSERTION"
+	}
+	return spdx.NormalizeLicense(strings.Join(names, " or "))
+}
+
+// classifierLicenses maps the names of common trove license classifiers
+// to short names NormalizeLicense understands. "OSI Approved" on its own
+// names no license and is left out.
+var classifierLicenses = map[string]string{
+	"MIT License":                                             "MIT",
+	"MIT No Attribution License (MIT-0)":                      "MIT-0",
+	"Apache Software License":                                 "Apache-2.0",
+	"BSD License":                                             "BSD",
+	"ISC License (ISCL)":                                      "ISC",
+	"Python Software Foundation License":                      "PSF",
+	"Mozilla Public License 2.0 (MPL 2.0)":                    "MPL-2.0",
+	"The Unlicense (Unlicense)":                               "Unlicense",
+	"zlib/libpng License":                                     "Zlib",
+	"Eclipse Public License 2.0 (EPL-2.0)":                    "EPL-2.0",
+	"GNU General Public License v2 (GPLv2)":                   "GPL-2",
+	"GNU General Public License v2 or later (GPLv2+)":         "GPL-2+",
+	"GNU General Public License v3 (GPLv3)":                   "GPL-3",
+	"GNU General Public License v3 or later (GPLv3+)":         "GPL-3+",
+	"GNU Lesser General Public License v2 (LGPLv2)":           "LGPL-2",
+	"GNU Lesser General Public License v2 or later (LGPLv2+)": "LGPL-2+",
+	"GNU Lesser General Public License v3 (LGPLv3)":           "LGPL-3",
+	"GNU Lesser General Public License v3 or later (LGPLv3+)": "LGPL-3+",
+	"GNU Affero General Public License v3":                    "AGPL-3",
+	"GNU Affero General Public License v3 or later (AGPLv3+)": "AGPL-3+",
+}
+
+// distributionToSPDX describes an installed distribution as a package
+func distributionToSPDX(dist Distribution) spdx.Package {
+	pkg := spdx.Package{
+		SPDXID:                "SPDXRef-Python-" + sanitizeName(normalizeName(dist.Name)),
+		Name:                  dist.Name,
+		PackageVersion:        dist.Version,
+		DownloadLocation:      "NOASSERTION",
+		FilesAnalyzed:         false,
+		LicenseConcluded:      "NOASSERTION",
+		LicenseDeclared:       dist.License,
+		CopyrightText:         "NOASSERTION",
+		Description:           dist.Summary,
+		HomePage:              dist.HomePage,
+		PrimaryPackagePurpose: "LIBRARY",
+		ExternalRefs: []spdx.ExternalRef{{
+			Category: "PACKAGE-MANAGER",
+			Type:     "purl",
+			Locator:  buildPurl(dist),
+		}},
+	}
+	return pkg
+}
+
+// buildPurl returns the distribution's pypi purl, named per PEP 503
+func buildPurl(dist Distribution) string {
+	purl := "pkg:pypi/" + normalizeName(dist.Name)
+	if dist.Version != "" {
+		purl += "@" + url.PathEscape(dist.Version)
+	}
+	return purl
+}
+
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizeName is the PEP 503 normalized form of a distribution name, the
+// form pypi purls use: lowercase with runs of -, _ and . as a single -
+func normalizeName(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(name, "-"))
+}
+
+var unsafeIDChars = regexp.MustCompile(`[^a-zA-Z0-9-.]`)
+
+func sanitizeName(name string) string {
+	// Replace non-alphanumeric characters with hyphens for SPDX IDs
+	return unsafeIDChars.ReplaceAllString(name, "-")
+}
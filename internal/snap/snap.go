@@ -0,0 +1,231 @@
+package snap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/version"
+)
+
+// Snap is an installed snap as listed by `snap list`
+type Snap struct {
+	Name      string
+	Version   string
+	Revision  string
+	Tracking  string
+	Publisher string
+	// Notes holds the snap's notes column, e.g. base, classic or disabled
+	Notes []string
+}
+
+type Generator struct {
+	// SnapPath is the snap executable, looked up in PATH unless it
+	// contains a slash
+	SnapPath string
+
+	// Reproducible takes the creation time from SOURCE_DATE_EPOCH (or the
+	// Unix epoch) and derives the namespace from the snap set
+	Reproducible bool
+
+	created string
+}
+
+func NewGenerator() *Generator {
+	return &Generator{SnapPath: "snap"}
+}
+
+// Generate builds a document of the installed snaps under a
+// SPDXRef-Snap-System root. Without snap installed the document holds
+// only the root, so snaps can always be included in combined SBOMs.
+func (g *Generator) Generate() (*spdx.Document, error) {
+	snaps, err := g.installedSnaps()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := spdx.CreationTime(g.Reproducible)
+	if err != nil {
+		return nil, err
+	}
+	g.created = created.Format(time.RFC3339)
+
+	doc := &spdx.Document{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              fmt.Sprintf("Snap-System-SBOM-%s", created.Format("2006-01-02")),
+		DocumentNamespace: fmt.Sprintf("https://sbom.snap.system/%s", spdx.NewUUID()),
+		CreationInfo: spdx.CreationInfo{
+			Created:            g.created,
+			Creators:           []string{version.Tool()},
+			LicenseListVersion: "3.20",
+		},
+		Packages: []spdx.Package{{
+			SPDXID:           "SPDXRef-Snap-System",
+			Name:             "Snap-System",
+			DownloadLocation: "NOASSERTION",
+			FilesAnalyzed:    false,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+		}},
+		Relationships: []spdx.Relationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelatedSPDXElement: "SPDXRef-Snap-System",
+			RelationshipType:   "DESCRIBES",
+		}},
+	}
+
+	for _, snap := range snaps {
+		pkg := g.snapToSPDX(snap)
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdx.Relationship{
+			SPDXElementID:      "SPDXRef-Snap-System",
+			RelatedSPDXElement: pkg.SPDXID,
+			RelationshipType:   "CONTAINS",
+		})
+	}
+
+	if g.Reproducible {
+		doc.DocumentNamespace = fmt.Sprintf("https://sbom.snap.system/%s", spdx.ContentUUID(doc))
+	}
+
+	logging.Infof("Found %d installed snaps", len(snaps))
+	return doc, nil
+}
+
+// installedSnaps runs `snap list`, returning no snaps with a warning when
+// snap is not installed
+func (g *Generator) installedSnaps() ([]Snap, error) {
+	snapPath, err := exec.LookPath(g.SnapPath)
+	if err != nil {
+		logging.Warnf("snap not found at %q, no snaps included", g.SnapPath)
+		return nil, nil
+	}
+
+	output, err := exec.Command(snapPath, "list", "--unicode=never", "--color=never").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("snap list failed: %w\n%s", err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("snap list failed: %w", err)
+	}
+	return parseSnapList(output), nil
+}
+
+// parseSnapList reads the table printed by `snap list`:
+//
+//	Name  Version  Rev  Tracking  Publisher  Notes
+//
+// Publishers carry a trailing * (verified) or ** (starred) marker, and "-"
+// stands for an empty column.
+func parseSnapList(output []byte) []Snap {
+	var snaps []Snap
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[0] == "Name" {
+			continue
+		}
+
+		snap := Snap{
+			Name:      fields[0],
+			Version:   fields[1],
+			Revision:  fields[2],
+			Tracking:  column(fields[3]),
+			Publisher: column(strings.TrimRight(fields[4], "*")),
+		}
+		if notes := column(fields[5]); notes != "" {
+			snap.Notes = strings.Split(notes, ",")
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps
+}
+
+// column returns a table value, with "-" meaning empty
+func column(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// snapToSPDX describes an installed snap as a package
+func (g *Generator) snapToSPDX(snap Snap) spdx.Package {
+	pkg := spdx.Package{
+		SPDXID:                "SPDXRef-Snap-" + sanitizeName(snap.Name),
+		Name:                  snap.Name,
+		PackageVersion:        snap.Version,
+		DownloadLocation:      "NOASSERTION",
+		FilesAnalyzed:         false,
+		LicenseConcluded:      "NOASSERTION",
+		LicenseDeclared:       "NOASSERTION",
+		CopyrightText:         "NOASSERTION",
+		HomePage:              "https://snapcraft.io/" + snap.Name,
+		PrimaryPackagePurpose: "APPLICATION",
+		ExternalRefs: []spdx.ExternalRef{{
+			Category: "PACKAGE-MANAGER",
+			Type:     "purl",
+			Locator:  buildPurl(snap),
+		}},
+	}
+
+	if snap.Publisher != "" {
+		pkg.Supplier = fmt.Sprintf("Organization: %s", snap.Publisher)
+	}
+
+	if snap.Tracking != "" {
+		pkg.Annotations = append(pkg.Annotations, g.annotation("snap-tracking: "+snap.Tracking))
+	}
+	if len(snap.Notes) > 0 {
+		pkg.Annotations = append(pkg.Annotations, g.annotation("snap-notes: "+strings.Join(snap.Notes, ",")))
+	}
+
+	for _, note := range snap.Notes {
+		switch note {
+		case "base", "core", "snapd":
+			pkg.PrimaryPackagePurpose = "OPERATING-SYSTEM"
+		case "gadget", "kernel":
+			pkg.PrimaryPackagePurpose = "FIRMWARE"
+		}
+	}
+
+	return pkg
+}
+
+func (g *Generator) annotation(comment string) spdx.Annotation {
+	return spdx.Annotation{
+		AnnotationDate: g.created,
+		AnnotationType: "OTHER",
+		Annotator:      version.Tool(),
+		Comment:        comment,
+	}
+}
+
+// buildPurl returns the snap's purl, qualified with its revision
+func buildPurl(snap Snap) string {
+	purl := "pkg:snap/" + snap.Name
+	if snap.Version != "" {
+		purl += "@" + url.PathEscape(snap.Version)
+	}
+	if snap.Revision != "" {
+		purl += "?revision=" + url.QueryEscape(snap.Revision)
+	}
+	return purl
+}
+
+var unsafeIDChars = regexp.MustCompile(`[^a-zA-Z0-9-.]`)
+
+func sanitizeName(name string) string {
+	// Replace non-alphanumeric characters with hyphens for SPDX IDs
+	return unsafeIDChars.ReplaceAllString(name, "-")
+}
@@ -0,0 +1,221 @@
+package spdx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckConsistency verifies the relationship invariants every generated
+// document must satisfy: exactly one DESCRIBES from the document to a root
+// package, exactly one CONTAINS from that root to every other package, and
+// no relationship to an element that is not in the document. The packages
+// listed in doc.Nested, which the merger keeps below the packages of
+// another tool's document, are instead only required to be related to the
+// root through that document's relationships.
+func CheckConsistency(doc *Document) error {
+	var problems []string
+
+	var describes []Relationship
+	for _, rel := range doc.Relationships {
+		if rel.SPDXElementID == doc.SPDXID && rel.RelationshipType == "DESCRIBES" {
+			describes = append(describes, rel)
+		}
+	}
+
+	if len(describes) != 1 {
+		problems = append(problems, fmt.Sprintf("expected exactly 1 DESCRIBES relationship, found %d", len(describes)))
+	}
+
+	if len(describes) > 0 {
+		rootID := describes[0].RelatedSPDXElement
+
+		contains := make(map[string]int)
+		for _, rel := range doc.Relationships {
+			if rel.SPDXElementID == rootID && rel.RelationshipType == "CONTAINS" {
+				contains[rel.RelatedSPDXElement]++
+			}
+		}
+
+		nested := make(map[string]bool)
+		for _, id := range doc.Nested {
+			nested[id] = true
+		}
+		var connected map[string]bool
+		if len(nested) > 0 {
+			connected = connectedTo(doc, rootID)
+		}
+
+		var uncontained, repeated, unrelated []string
+		for _, pkg := range doc.Packages {
+			switch {
+			case pkg.SPDXID == rootID:
+			case nested[pkg.SPDXID] && contains[pkg.SPDXID] == 0:
+				if !connected[pkg.SPDXID] {
+					unrelated = append(unrelated, pkg.SPDXID)
+				}
+			case contains[pkg.SPDXID] == 0:
+				uncontained = append(uncontained, pkg.SPDXID)
+			case contains[pkg.SPDXID] > 1:
+				repeated = append(repeated, pkg.SPDXID)
+			}
+		}
+
+		if len(uncontained) > 0 {
+			problems = append(problems, fmt.Sprintf("%d packages not contained by root %s: %s", len(uncontained), rootID, listIDs(uncontained)))
+		}
+		if len(repeated) > 0 {
+			problems = append(problems, fmt.Sprintf("%d packages contained by root %s more than once: %s", len(repeated), rootID, listIDs(repeated)))
+		}
+		if len(unrelated) > 0 {
+			problems = append(problems, fmt.Sprintf("%d nested packages not related to root %s: %s", len(unrelated), rootID, listIDs(unrelated)))
+		}
+	}
+
+	if dangling := danglingRelationships(doc); len(dangling) > 0 {
+		problems = append(problems, fmt.Sprintf("%d relationships refer to elements not in the document: %s", len(dangling), listIDs(dangling)))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("consistency check failed: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// listIDs joins the first few ids for an error message
+func listIDs(ids []string) string {
+	if len(ids) > 5 {
+		ids = append(ids[:5:5], "...")
+	}
+	return strings.Join(ids, ", ")
+}
+
+// danglingRelationships describes the relationships with an end that is
+// not the document, one of its packages or files, or an element of an
+// external document (DocumentRef-...). NONE and NOASSERTION are allowed
+// as the related element.
+func danglingRelationships(doc *Document) []string {
+	elements := map[string]bool{doc.SPDXID: true}
+	for _, pkg := range doc.Packages {
+		elements[pkg.SPDXID] = true
+	}
+	for _, file := range doc.Files {
+		elements[file.SPDXID] = true
+	}
+	known := func(id string) bool {
+		return elements[id] || strings.HasPrefix(id, "DocumentRef-")
+	}
+
+	var dangling []string
+	for _, rel := range doc.Relationships {
+		related := rel.RelatedSPDXElement
+		if !known(rel.SPDXElementID) || !(known(related) || related == "NONE" || related == "NOASSERTION") {
+			dangling = append(dangling, fmt.Sprintf("%s %s %s", rel.SPDXElementID, rel.RelationshipType, related))
+		}
+	}
+	return dangling
+}
+
+// connectedTo returns the elements related, directly or through other
+// elements, to id. Relationships are followed in both directions, as types
+// such as DEPENDENCY_OF point towards the root. The document itself is
+// not followed.
+func connectedTo(doc *Document, id string) map[string]bool {
+	neighbours := make(map[string][]string)
+	for _, rel := range doc.Relationships {
+		if rel.SPDXElementID == doc.SPDXID {
+			continue
+		}
+		neighbours[rel.SPDXElementID] = append(neighbours[rel.SPDXElementID], rel.RelatedSPDXElement)
+		neighbours[rel.RelatedSPDXElement] = append(neighbours[rel.RelatedSPDXElement], rel.SPDXElementID)
+	}
+
+	connected := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, neighbour := range neighbours[next] {
+			if !connected[neighbour] {
+				connected[neighbour] = true
+				queue = append(queue, neighbour)
+			}
+		}
+	}
+	return connected
+}
+
+// CheckDuplicates looks for packages listed more than once. Exact duplicate
+// SPDXIDs make the document invalid and are returned as an error. Packages
+// sharing a name but differing in architecture are legitimate on multiarch
+// systems and are returned as informational notes.
+func CheckDuplicates(doc *Document) (notes []string, err error) {
+	idCounts := make(map[string]int)
+	var duplicateIDs []string
+
+	archesByName := make(map[string][]string)
+	var names []string
+
+	for _, pkg := range doc.Packages {
+		idCounts[pkg.SPDXID]++
+		if idCounts[pkg.SPDXID] == 2 {
+			duplicateIDs = append(duplicateIDs, pkg.SPDXID)
+		}
+
+		arch := purlQualifier(pkg, "arch")
+		if arch == "" {
+			continue
+		}
+		arches, ok := archesByName[pkg.Name]
+		if !ok {
+			names = append(names, pkg.Name)
+		}
+		if !containsString(arches, arch) {
+			archesByName[pkg.Name] = append(arches, arch)
+		}
+	}
+
+	for _, name := range names {
+		if arches := archesByName[name]; len(arches) > 1 {
+			notes = append(notes, fmt.Sprintf("package %s is present for multiple architectures (%s)", name, strings.Join(arches, ", ")))
+		}
+	}
+
+	if len(duplicateIDs) > 0 {
+		err = fmt.Errorf("duplicate SPDXIDs: %s", strings.Join(duplicateIDs, ", "))
+	}
+
+	return notes, err
+}
+
+// purlQualifier returns the value of a qualifier from the package's purl
+// external reference, or "" if absent
+func purlQualifier(pkg Package, key string) string {
+	for _, ref := range pkg.ExternalRefs {
+		if ref.Type != "purl" {
+			continue
+		}
+
+		_, query, ok := strings.Cut(ref.Locator, "?")
+		if !ok {
+			continue
+		}
+		query, _, _ = strings.Cut(query, "#")
+
+		for _, pair := range strings.Split(query, "&") {
+			if k, v, ok := strings.Cut(pair, "="); ok && k == key {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
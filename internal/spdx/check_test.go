@@ -0,0 +1,164 @@
+package spdx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// systemDocument returns a document whose root contains packages a and b
+func systemDocument() *Document {
+	return &Document{
+		SPDXID: "SPDXRef-DOCUMENT",
+		Packages: []Package{
+			{SPDXID: "SPDXRef-System"},
+			{SPDXID: "SPDXRef-a"},
+			{SPDXID: "SPDXRef-b"},
+		},
+		Relationships: []Relationship{
+			{SPDXElementID: "SPDXRef-DOCUMENT", RelatedSPDXElement: "SPDXRef-System", RelationshipType: "DESCRIBES"},
+			{SPDXElementID: "SPDXRef-System", RelatedSPDXElement: "SPDXRef-a", RelationshipType: "CONTAINS"},
+			{SPDXElementID: "SPDXRef-System", RelatedSPDXElement: "SPDXRef-b", RelationshipType: "CONTAINS"},
+		},
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(doc *Document)
+		want   string
+	}{
+		{"consistent", func(doc *Document) {}, ""},
+		{
+			"dependencies between contained packages",
+			func(doc *Document) {
+				doc.Relationships = append(doc.Relationships, Relationship{SPDXElementID: "SPDXRef-a", RelatedSPDXElement: "SPDXRef-b", RelationshipType: "DEPENDS_ON"})
+			},
+			"",
+		},
+		{
+			"no DESCRIBES",
+			func(doc *Document) { doc.Relationships = doc.Relationships[1:] },
+			"expected exactly 1 DESCRIBES relationship, found 0",
+		},
+		{
+			"two DESCRIBES",
+			func(doc *Document) {
+				doc.Relationships = append(doc.Relationships, Relationship{SPDXElementID: "SPDXRef-DOCUMENT", RelatedSPDXElement: "SPDXRef-a", RelationshipType: "DESCRIBES"})
+			},
+			"expected exactly 1 DESCRIBES relationship, found 2",
+		},
+		{
+			"package without relationship",
+			func(doc *Document) { doc.Packages = append(doc.Packages, Package{SPDXID: "SPDXRef-c"}) },
+			"1 packages not contained by root SPDXRef-System: SPDXRef-c",
+		},
+		{
+			"package contained twice",
+			func(doc *Document) {
+				doc.Relationships = append(doc.Relationships, Relationship{SPDXElementID: "SPDXRef-System", RelatedSPDXElement: "SPDXRef-a", RelationshipType: "CONTAINS"})
+			},
+			"1 packages contained by root SPDXRef-System more than once: SPDXRef-a",
+		},
+		{
+			"relationship without its package",
+			func(doc *Document) { doc.Packages = doc.Packages[:2] },
+			"1 relationships refer to elements not in the document: SPDXRef-System CONTAINS SPDXRef-b",
+		},
+		{
+			"relationship from an unknown element",
+			func(doc *Document) {
+				doc.Relationships = append(doc.Relationships, Relationship{SPDXElementID: "SPDXRef-gone", RelatedSPDXElement: "SPDXRef-a", RelationshipType: "DEPENDS_ON"})
+			},
+			"1 relationships refer to elements not in the document: SPDXRef-gone DEPENDS_ON SPDXRef-a",
+		},
+		{
+			"relationships to files, external documents and NOASSERTION",
+			func(doc *Document) {
+				doc.Files = []File{{SPDXID: "SPDXRef-File-1"}}
+				doc.Relationships = append(doc.Relationships,
+					Relationship{SPDXElementID: "SPDXRef-a", RelatedSPDXElement: "SPDXRef-File-1", RelationshipType: "CONTAINS"},
+					Relationship{SPDXElementID: "SPDXRef-a", RelatedSPDXElement: "DocumentRef-Nix:SPDXRef-b", RelationshipType: "DESCENDANT_OF"},
+					Relationship{SPDXElementID: "SPDXRef-b", RelatedSPDXElement: "NOASSERTION", RelationshipType: "DEPENDS_ON"},
+				)
+			},
+			"",
+		},
+		{
+			"package only below another package",
+			func(doc *Document) {
+				doc.Packages = append(doc.Packages, Package{SPDXID: "SPDXRef-c"})
+				doc.Relationships = append(doc.Relationships, Relationship{SPDXElementID: "SPDXRef-a", RelatedSPDXElement: "SPDXRef-c", RelationshipType: "DEPENDS_ON"})
+			},
+			"1 packages not contained by root SPDXRef-System: SPDXRef-c",
+		},
+		{
+			"nested package below another package",
+			func(doc *Document) {
+				doc.Packages = append(doc.Packages, Package{SPDXID: "SPDXRef-c"}, Package{SPDXID: "SPDXRef-d"})
+				doc.Relationships = append(doc.Relationships,
+					Relationship{SPDXElementID: "SPDXRef-a", RelatedSPDXElement: "SPDXRef-c", RelationshipType: "DEPENDS_ON"},
+					Relationship{SPDXElementID: "SPDXRef-d", RelatedSPDXElement: "SPDXRef-c", RelationshipType: "DEPENDENCY_OF"},
+				)
+				doc.Nested = []string{"SPDXRef-c", "SPDXRef-d"}
+			},
+			"",
+		},
+		{
+			"nested package not related to the root",
+			func(doc *Document) {
+				doc.Packages = append(doc.Packages, Package{SPDXID: "SPDXRef-c"}, Package{SPDXID: "SPDXRef-d"})
+				doc.Relationships = append(doc.Relationships, Relationship{SPDXElementID: "SPDXRef-c", RelatedSPDXElement: "SPDXRef-d", RelationshipType: "DEPENDS_ON"})
+				doc.Nested = []string{"SPDXRef-c", "SPDXRef-d"}
+			},
+			"2 nested packages not related to root SPDXRef-System: SPDXRef-c, SPDXRef-d",
+		},
+		{
+			"nested package only related through the document",
+			func(doc *Document) {
+				doc.Packages = append(doc.Packages, Package{SPDXID: "SPDXRef-c"})
+				doc.Relationships = append(doc.Relationships, Relationship{SPDXElementID: "SPDXRef-DOCUMENT", RelatedSPDXElement: "SPDXRef-c", RelationshipType: "DESCRIBES"})
+				doc.Nested = []string{"SPDXRef-c"}
+			},
+			"1 nested packages not related to root SPDXRef-System: SPDXRef-c",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := systemDocument()
+			tc.modify(doc)
+			err := CheckConsistency(doc)
+			switch {
+			case tc.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Errorf("got %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckDuplicates(t *testing.T) {
+	purl := func(locator string) []ExternalRef {
+		return []ExternalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: locator}}
+	}
+	doc := &Document{Packages: []Package{
+		{SPDXID: "SPDXRef-libc6-amd64", Name: "libc6", ExternalRefs: purl("pkg:deb/ubuntu/libc6@2.35?arch=amd64&distro=ubuntu-22.04")},
+		{SPDXID: "SPDXRef-libc6-i386", Name: "libc6", ExternalRefs: purl("pkg:deb/ubuntu/libc6@2.35?distro=ubuntu-22.04&arch=i386")},
+		{SPDXID: "SPDXRef-bash", Name: "bash", ExternalRefs: purl("pkg:deb/ubuntu/bash@5.1?arch=amd64")},
+		{SPDXID: "SPDXRef-hello", Name: "hello", ExternalRefs: purl("pkg:nix/hello@2.12.1")},
+	}}
+
+	notes, err := CheckDuplicates(doc)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if want := []string{"package libc6 is present for multiple architectures (amd64, i386)"}; !reflect.DeepEqual(notes, want) {
+		t.Errorf("notes %q, want %q", notes, want)
+	}
+
+	doc.Packages = append(doc.Packages, Package{SPDXID: "SPDXRef-bash", Name: "bash"}, Package{SPDXID: "SPDXRef-bash", Name: "bash"})
+	if _, err := CheckDuplicates(doc); err == nil || err.Error() != "duplicate SPDXIDs: SPDXRef-bash" {
+		t.Errorf("got %v, want the duplicate SPDXRef-bash", err)
+	}
+}
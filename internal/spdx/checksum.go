@@ -0,0 +1,50 @@
+package spdx
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// checksumHashes are the checksum algorithms files can be hashed with, by
+// SPDX name
+var checksumHashes = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// NewHash returns a hash for an SPDX checksum algorithm name, or nil if
+// the algorithm is not supported
+func NewHash(algorithm string) hash.Hash {
+	if newHash, ok := checksumHashes[algorithm]; ok {
+		return newHash()
+	}
+	return nil
+}
+
+// ParseChecksumAlgorithms reads a comma-separated list of checksum
+// algorithms (sha1, sha256, sha512; case-insensitive) into their SPDX
+// names, in the order given and without repeats
+func ParseChecksumAlgorithms(value string) ([]string, error) {
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		algorithm := strings.ToUpper(strings.TrimSpace(name))
+		if algorithm == "" || seen[algorithm] {
+			continue
+		}
+		if _, ok := checksumHashes[algorithm]; !ok {
+			return nil, fmt.Errorf("unsupported checksum algorithm %q: expected sha1, sha256 or sha512", name)
+		}
+		seen[algorithm] = true
+		algorithms = append(algorithms, algorithm)
+	}
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("no checksum algorithm given")
+	}
+	return algorithms, nil
+}
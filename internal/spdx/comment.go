@@ -0,0 +1,84 @@
+package spdx
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// sensitiveFlagWords mark flags whose values must never be recorded
+var sensitiveFlagWords = []string{"key", "token", "password", "secret", "credential"}
+
+// CommandLineComment renders the command line that produced a document
+// for CreationInfo.Comment, masking the values of sensitive flags and the
+// credentials in URLs
+func CommandLineComment(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	redacted := []string{filepath.Base(args[0])}
+	maskNext := false
+
+	for _, arg := range args[1:] {
+		if maskNext {
+			redacted = append(redacted, "***")
+			maskNext = false
+			continue
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			prefix := arg[:len(arg)-len(value)]
+			switch {
+			case isSensitiveFlag(name) && hasValue:
+				arg = prefix + "***"
+			case isSensitiveFlag(name):
+				maskNext = true
+			case hasValue:
+				arg = prefix + RedactURL(value)
+			}
+		} else {
+			arg = RedactURL(arg)
+		}
+
+		redacted = append(redacted, arg)
+	}
+
+	return "Generated with: " + strings.Join(redacted, " ")
+}
+
+// RedactURL strips the userinfo from an absolute URL and masks its query
+// values, which may carry passwords or tokens, so it can be logged.
+// Anything else is returned as it is.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return raw
+	}
+	if u.User == nil && u.RawQuery == "" {
+		return raw
+	}
+
+	u.User = nil
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			if key, _, ok := strings.Cut(param, "="); ok {
+				params[i] = key + "=***"
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.String()
+}
+
+func isSensitiveFlag(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveFlagWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package spdx
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const validUntilPrefix = "valid-until: "
+
+// SetValidFor records an expiry timestamp, validFor after the document's
+// creation time, as a document-level annotation
+func SetValidFor(doc *Document, validFor time.Duration, annotator string) error {
+	created, err := time.Parse(time.RFC3339, doc.CreationInfo.Created)
+	if err != nil {
+		return fmt.Errorf("invalid creation time %q: %w", doc.CreationInfo.Created, err)
+	}
+
+	doc.Annotations = append(doc.Annotations, Annotation{
+		AnnotationDate: doc.CreationInfo.Created,
+		AnnotationType: "OTHER",
+		Annotator:      annotator,
+		Comment:        validUntilPrefix + created.Add(validFor).UTC().Format(time.RFC3339),
+	})
+
+	return nil
+}
+
+// ValidUntil returns the recorded expiry timestamp, if any
+func ValidUntil(doc *Document) (time.Time, bool) {
+	for _, annotation := range doc.Annotations {
+		value, ok := strings.CutPrefix(annotation.Comment, validUntilPrefix)
+		if !ok {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// CheckExpiry returns an error if the document is past its recorded expiry
+func CheckExpiry(doc *Document, now time.Time) error {
+	if until, ok := ValidUntil(doc); ok && now.After(until) {
+		return fmt.Errorf("document %q expired at %s", doc.Name, until.Format(time.RFC3339))
+	}
+	return nil
+}
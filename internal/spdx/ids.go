@@ -0,0 +1,17 @@
+package spdx
+
+import "strconv"
+
+// UniqueID returns id, or id with the first free numeric suffix (-2, -3,
+// ...) if it is already taken. Callers record the result in taken.
+func UniqueID(id string, taken map[string]bool) string {
+	if !taken[id] {
+		return id
+	}
+	for n := 2; ; n++ {
+		candidate := id + "-" + strconv.Itoa(n)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package spdx
+
+import (
+	"bufio"
+	_ "embed"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed licenses.txt
+var licenseTable string
+
+// licenseMapping is one row of the embedded license table
+type licenseMapping struct {
+	name string
+	id   string
+}
+
+var (
+	// licenseMappings holds the table rows longest name first, so a
+	// prefix match finds the most specific name: lgpl-2.1+ before lgpl-2
+	licenseMappings []licenseMapping
+	licenseIDs      = make(map[string]string)
+
+	// knownLicenseIDs holds the SPDX identifiers the table maps to, by
+	// lowercased identifier, so a value already in SPDX form is accepted
+	// in any case
+	knownLicenseIDs = make(map[string]string)
+)
+
+func init() {
+	scanner := bufio.NewScanner(strings.NewReader(licenseTable))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			panic("malformed license table line: " + line)
+		}
+		name := strings.ToLower(fields[0])
+		licenseMappings = append(licenseMappings, licenseMapping{name: name, id: fields[1]})
+		licenseIDs[name] = fields[1]
+		if fields[1] != "NOASSERTION" {
+			knownLicenseIDs[strings.ToLower(fields[1])] = fields[1]
+		}
+	}
+	sort.SliceStable(licenseMappings, func(i, j int) bool {
+		return len(licenseMappings[i].name) > len(licenseMappings[j].name)
+	})
+}
+
+var (
+	// licenseOperator splits Debian "a or b" / "a and b" expressions, and
+	// the "a | b" some copyright files use for or
+	licenseOperator = regexp.MustCompile(`(?i)\s+(or|and)\s+|\s*(\|)\s*`)
+
+	// licenseWith splits a license with an exception clause, Debian's
+	// "GPL-2+ with OpenSSL exception" or SPDX's "... WITH GCC-exception-3.1"
+	licenseWith = regexp.MustCompile(`(?i)^(.+?)\s+with\s+(.+)$`)
+)
+
+// licenseExceptions maps the exception names Debian copyright files use,
+// lowercased and without the word "exception" ("gcc 3.1" for
+// GCC-exception-3.1), to SPDX exception identifiers. Where an exception
+// exists for both GPL versions, the first identifier is for GPL-2 and the
+// second for GPL-3, chosen by the license it modifies.
+//
+// Exceptions without an SPDX identifier, such as the OpenSSL linking
+// exception most packages use, are not listed. The license and exception
+// together become a LicenseRef (see licenseRefWith), so the exception is
+// not lost.
+var licenseExceptions = map[string][]string{
+	"autoconf":            {"Autoconf-exception-2.0", "Autoconf-exception-3.0"},
+	"autoconf 2.0":        {"Autoconf-exception-2.0"},
+	"autoconf 3.0":        {"Autoconf-exception-3.0"},
+	"bison":               {"Bison-exception-2.2"},
+	"bison 2.2":           {"Bison-exception-2.2"},
+	"classpath":           {"Classpath-exception-2.0"},
+	"classpath 2.0":       {"Classpath-exception-2.0"},
+	"font":                {"Font-exception-2.0"},
+	"font 2.0":            {"Font-exception-2.0"},
+	"gcc":                 {"GCC-exception-2.0", "GCC-exception-3.1"},
+	"gcc 2.0":             {"GCC-exception-2.0"},
+	"gcc 3.1":             {"GCC-exception-3.1"},
+	"gcc runtime library": {"GCC-exception-3.1"},
+	"libtool":             {"Libtool-exception"},
+	"linux syscall note":  {"Linux-syscall-note"},
+	"llvm":                {"LLVM-exception"},
+}
+
+// NormalizeLicense converts a Debian copyright License: value into an SPDX
+// license expression, or NOASSERTION when it cannot be mapped confidently.
+// Only the first line (the short name) is considered. Compound Debian
+// expressions are converted term by term: "GPL-2+ or Artistic" (or
+// "GPL-2+ | Artistic") becomes "GPL-2.0-or-later OR Artistic-1.0". Commas separate AND-ed groups with
+// lower precedence than and/or, so those groups are parenthesized where
+// needed. If any term cannot be mapped the whole expression is
+// NOASSERTION.
+func NormalizeLicense(license string) string {
+	license, _, _ = strings.Cut(license, "\n")
+	license = strings.TrimSpace(license)
+	if license == "" {
+		return "NOASSERTION"
+	}
+
+	var groups []string
+	for _, group := range strings.Split(license, ",") {
+		group = strings.TrimSpace(group)
+		group = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(group, "and "), "AND "))
+		if group == "" {
+			continue
+		}
+
+		expression, ok := normalizeLicenseGroup(group)
+		if !ok {
+			return "NOASSERTION"
+		}
+		groups = append(groups, expression)
+	}
+
+	return ConjoinLicenses(groups)
+}
+
+// ConjoinLicenses combines license expressions that all apply into one AND
+// expression, dropping duplicates and parenthesizing OR expressions. The
+// result is NOASSERTION if the list is empty or any expression is
+// NOASSERTION, since a partial conjunction would understate the terms.
+func ConjoinLicenses(expressions []string) string {
+	var parts []string
+	seen := make(map[string]bool)
+	for _, expression := range expressions {
+		if expression == "" || expression == "NOASSERTION" {
+			return "NOASSERTION"
+		}
+
+		// Flatten plain conjunctions so repeated terms are dropped too
+		terms := []string{expression}
+		if !strings.Contains(expression, " OR ") && !strings.Contains(expression, "(") {
+			terms = strings.Split(expression, " AND ")
+		}
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				parts = append(parts, term)
+			}
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return "NOASSERTION"
+	case 1:
+		return parts[0]
+	}
+
+	for i, part := range parts {
+		if strings.Contains(part, " OR ") {
+			parts[i] = "(" + part + ")"
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// normalizeLicenseGroup maps a comma-free "a or b and c" expression. SPDX
+// gives AND precedence over OR like Debian does, so the operators carry
+// over unchanged.
+func normalizeLicenseGroup(group string) (string, bool) {
+	terms := licenseOperator.Split(group, -1)
+	operators := licenseOperator.FindAllStringSubmatch(group, -1)
+
+	var b strings.Builder
+	for i, term := range terms {
+		id := normalizeLicenseTerm(term)
+		if id == "NOASSERTION" {
+			return "", false
+		}
+		if i > 0 {
+			operator := strings.ToUpper(operators[i-1][1])
+			if operator == "" {
+				operator = "OR"
+			}
+			b.WriteString(" " + operator + " ")
+		}
+		b.WriteString(id)
+	}
+	return b.String(), true
+}
+
+// normalizeLicenseTerm maps a single license short name, with an
+// exception clause when it has one
+func normalizeLicenseTerm(term string) string {
+	term = strings.TrimSpace(term)
+	if match := licenseWith.FindStringSubmatch(term); match != nil {
+		return normalizeLicenseWith(match[1], match[2])
+	}
+	lower := strings.ToLower(term)
+
+	if id, ok := licenseIDs[lower]; ok {
+		return id
+	}
+	if id, ok := knownLicenseIDs[lower]; ok {
+		return id
+	}
+
+	// A name only matches as a whole token, so MIT-0 is not MIT, and
+	// GPL-2+-or-X11 is not GPL-2
+	for _, mapping := range licenseMappings {
+		rest, ok := strings.CutPrefix(lower, mapping.name)
+		if ok && (rest == "" || strings.ContainsRune(" ,", rune(rest[0]))) {
+			return mapping.id
+		}
+	}
+
+	// Anything else, even when shaped like an identifier ("GPL",
+	// "custom"), is not known to be one
+	return "NOASSERTION"
+}
+
+// normalizeLicenseWith maps a license modified by an exception to an SPDX
+// WITH expression, or to a LicenseRef naming both when the exception has
+// no SPDX identifier
+func normalizeLicenseWith(license, exception string) string {
+	id := normalizeLicenseTerm(license)
+	if id == "NOASSERTION" || strings.Contains(id, " ") {
+		return "NOASSERTION"
+	}
+
+	var words []string
+	for _, word := range strings.Fields(strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(exception))) {
+		if word != "exception" {
+			words = append(words, word)
+		}
+	}
+	exceptionIDs := licenseExceptions[strings.Join(words, " ")]
+	switch {
+	case len(exceptionIDs) == 0:
+		return licenseRefWith(id, exception)
+	case len(exceptionIDs) > 1 && strings.Contains(id, "GPL-3"):
+		return id + " WITH " + exceptionIDs[1]
+	default:
+		return id + " WITH " + exceptionIDs[0]
+	}
+}
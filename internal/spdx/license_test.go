@@ -0,0 +1,207 @@
+package spdx
+
+import "testing"
+
+// licenseCorpus holds License: values copied from the
+// /usr/share/doc/*/copyright files of a Debian-based system, with the
+// expressions they map to
+var licenseCorpus = []struct {
+	license string
+	want    string
+}{
+	{"GPL-1+ or Artistic", "GPL-1.0-or-later OR Artistic-1.0"},
+	{"Artistic or GPL-1+", "Artistic-1.0 OR GPL-1.0-or-later"},
+	{"GPL-2", "GPL-2.0-only"},
+	{"GPL-2+", "GPL-2.0-or-later"},
+	{"GPL-2.0+", "GPL-2.0-or-later"},
+	{"GPL-3", "GPL-3.0-only"},
+	{"GPL-3+", "GPL-3.0-or-later"},
+	{"LGPL-2+", "LGPL-2.0-or-later"},
+	{"LGPL-2.1", "LGPL-2.1-only"},
+	{"LGPL-2.1+", "LGPL-2.1-or-later"},
+	{"LGPL-2.1-or-later", "LGPL-2.1-or-later"},
+	{"LGPL-3+ or GPL-2+", "LGPL-3.0-or-later OR GPL-2.0-or-later"},
+	{"GPL-2+ and LGPL-2.1+", "GPL-2.0-or-later AND LGPL-2.1-or-later"},
+	{"MPL-1.1 or GPL-2+ or LGPL-2.1+", "MPL-1.1 OR GPL-2.0-or-later OR LGPL-2.1-or-later"},
+	{"BSD-2-clause", "BSD-2-Clause"},
+	{"BSD-3-clause", "BSD-3-Clause"},
+	{"BSD-3-Clause", "BSD-3-Clause"},
+	{"BSD-4-clause", "BSD-4-Clause"},
+	{"BSD-3-clause or GPL-2", "BSD-3-Clause OR GPL-2.0-only"},
+	{"Expat", "MIT"},
+	{"expat", "MIT"},
+	{"MIT/X11", "MIT"},
+	{"Expat or GPL-1+ or Artistic", "MIT OR GPL-1.0-or-later OR Artistic-1.0"},
+	{"X11", "X11"},
+	{"ISC", "ISC"},
+	{"Zlib", "Zlib"},
+	{"zlib", "Zlib"},
+	{"ZLIB", "Zlib"},
+	{"libpng", "Libpng"},
+	{"libpng OR Apache-2.0 OR BSD-3-clause", "Libpng OR Apache-2.0 OR BSD-3-Clause"},
+	{"Apache-2", "Apache-2.0"},
+	{"Apache-2.0", "Apache-2.0"},
+	{"curl", "curl"},
+	{"FTL", "FTL"},
+	{"CC0", "CC0-1.0"},
+	{"CC0-1.0", "CC0-1.0"},
+	{"OpenLDAP-2.8", "OLDAP-2.8"},
+	{"OpenLDAP-2.8 and Expat", "OLDAP-2.8 AND MIT"},
+	{"Unicode-DFS-2016", "Unicode-DFS-2016"},
+	{"public-domain", "NOASSERTION"},
+	{"permissive", "NOASSERTION"},
+	{"Purdue", "NOASSERTION"},
+	{"BSD-2-clause or ISC", "BSD-2-Clause OR ISC"},
+	{"AFL-2.1", "AFL-2.1"},
+
+	// Names that only look like identifiers are not passed through
+	{"GPL", "NOASSERTION"},
+	{"LGPL", "NOASSERTION"},
+	{"custom", "NOASSERTION"},
+	{"GPLv3+", "NOASSERTION"},
+	{"LGPLv2.1+", "NOASSERTION"},
+	{"LGPLv3+_or_GPLv2+", "NOASSERTION"},
+	{"Public-Domain", "NOASSERTION"},
+	{"MIT-like", "NOASSERTION"},
+	{"Expat-ISC", "NOASSERTION"},
+	{"BSD-BY-LC-NE", "NOASSERTION"},
+	{"BSD-2-Clause-alike", "NOASSERTION"},
+	{"BSD-3-clause-generic", "NOASSERTION"},
+	{"GPL-2+-or-X11", "NOASSERTION"},
+	{"APACHE-2-LLVM-EXCEPTIONS", "NOASSERTION"},
+
+	// SPDX identifiers that start with a mapped name are not that name
+	{"MIT-CMU", "MIT-CMU"},
+
+	// Commas separate groups that all apply
+	{"GPL-1+ or Artistic, and Expat", "(GPL-1.0-or-later OR Artistic-1.0) AND MIT"},
+	{"GPL-2+ or AFL-2.1, and Expat", "(GPL-2.0-or-later OR AFL-2.1) AND MIT"},
+
+	// Exception clauses
+	{"Apache-2.0 with LLVM exception", "Apache-2.0 WITH LLVM-exception"},
+	{"GPL-2 with Linux-syscall-note exception", "GPL-2.0-only WITH Linux-syscall-note"},
+	{"GPL-2+ with Autoconf exception", "GPL-2.0-or-later WITH Autoconf-exception-2.0"},
+	{"GPL-3+ with Autoconf exception", "GPL-3.0-or-later WITH Autoconf-exception-3.0"},
+	{"GPL-3+ with Bison exception", "GPL-3.0-or-later WITH Bison-exception-2.2"},
+	{"GPL-3+ with Libtool exception", "GPL-3.0-or-later WITH Libtool-exception"},
+	{"GPL-2+ with OpenSSL exception", "LicenseRef-GPL-2.0-or-later-with-OpenSSL-exception"},
+	{"LGPL-2.1+ with OpenSSL exception", "LicenseRef-LGPL-2.1-or-later-with-OpenSSL-exception"},
+	{"GPL-2+ with Texinfo exception", "LicenseRef-GPL-2.0-or-later-with-Texinfo-exception"},
+	{"FSF-unlimited and GPL-2+ with Libtool exception", "NOASSERTION"},
+
+	// Only the short name on the first line counts
+	{"GPL-2+\n This program is free software; you can redistribute it", "GPL-2.0-or-later"},
+}
+
+func TestNormalizeLicense(t *testing.T) {
+	for _, tc := range licenseCorpus {
+		if got := NormalizeLicense(tc.license); got != tc.want {
+			t.Errorf("NormalizeLicense(%q) = %q, want %q", tc.license, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeLicenseWith(t *testing.T) {
+	for _, tc := range []struct {
+		license string
+		want    string
+	}{
+		{"GPL-2+ with Autoconf exception", "GPL-2.0-or-later WITH Autoconf-exception-2.0"},
+		{"GPL-3+ with Autoconf exception", "GPL-3.0-or-later WITH Autoconf-exception-3.0"},
+		{"GPL-3+ with GCC-exception-3.1", "GPL-3.0-or-later WITH GCC-exception-3.1"},
+		{"GPL-3+ with GCC runtime library exception", "GPL-3.0-or-later WITH GCC-exception-3.1"},
+		{"GPL-2+ with GCC exception", "GPL-2.0-or-later WITH GCC-exception-2.0"},
+		{"GPL-3+ WITH GCC-exception-3.1", "GPL-3.0-or-later WITH GCC-exception-3.1"},
+		{"GPL-2+ with Bison exception", "GPL-2.0-or-later WITH Bison-exception-2.2"},
+		{"GPL-2 with Linux-syscall-note exception", "GPL-2.0-only WITH Linux-syscall-note"},
+		{"GPL-2.0+ WITH Linux-syscall-note", "GPL-2.0-or-later WITH Linux-syscall-note"},
+		{"Apache-2.0 with LLVM exception", "Apache-2.0 WITH LLVM-exception"},
+		{"GPL-2+ with Linux-syscall-note exception or BSD-3-clause", "GPL-2.0-or-later WITH Linux-syscall-note OR BSD-3-Clause"},
+
+		// Exceptions without an SPDX identifier are kept in a LicenseRef
+		{"GPL-2+ with OpenSSL exception", "LicenseRef-GPL-2.0-or-later-with-OpenSSL-exception"},
+		{"GPL-3+ with OpenSSL exception", "LicenseRef-GPL-3.0-or-later-with-OpenSSL-exception"},
+		{"GPL-2 with Qt exception 1.0", "LicenseRef-GPL-2.0-only-with-Qt-exception-1.0"},
+		{"LGPL-2.1+ with static-linking exception, MIT", "LicenseRef-LGPL-2.1-or-later-with-static-linking-exception AND MIT"},
+
+		// An unknown license cannot be named, whatever the exception
+		{"custom with OpenSSL exception", "NOASSERTION"},
+	} {
+		if got := NormalizeLicense(tc.license); got != tc.want {
+			t.Errorf("NormalizeLicense(%q) = %q, want %q", tc.license, got, tc.want)
+		}
+	}
+}
+
+// TestNormalizeLicenseLongestPrefix pins names that are prefixes of other
+// names to their own identifiers, and strings only matched as prefixes to
+// the most specific name. Repeated to catch any dependence on ordering.
+func TestNormalizeLicenseLongestPrefix(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		for _, tc := range []struct {
+			license string
+			want    string
+		}{
+			{"lgpl-2.1+", "LGPL-2.1-or-later"},
+			{"lgpl-2+", "LGPL-2.0-or-later"},
+			{"gpl-3+", "GPL-3.0-or-later"},
+			{"lgpl-2.1+ (with clarifications)", "LGPL-2.1-or-later"},
+			{"lgpl-2+ (see below)", "LGPL-2.0-or-later"},
+			{"gpl-3+ (see COPYING)", "GPL-3.0-or-later"},
+			{"bsd-2-clause (NetBSD)", "BSD-2-Clause"},
+		} {
+			if got := NormalizeLicense(tc.license); got != tc.want {
+				t.Fatalf("NormalizeLicense(%q) = %q, want %q", tc.license, got, tc.want)
+			}
+		}
+	}
+}
+
+// TestNormalizeLicenseSyntax covers spellings the corpus above does not
+// happen to contain
+func TestNormalizeLicenseSyntax(t *testing.T) {
+	for _, tc := range []struct {
+		license string
+		want    string
+	}{
+		{"", "NOASSERTION"},
+		{"Expat, Expat", "MIT"},
+
+		// | is or
+		{"GPL-2 | BSD", "GPL-2.0-only OR BSD-3-Clause"},
+		{"GPL-2+|LGPL-2.1+", "GPL-2.0-or-later OR LGPL-2.1-or-later"},
+
+		// Names match as whole tokens only
+		{"MIT-0", "MIT-0"},
+		{"BSD-2-clause-patent", "BSD-2-Clause-Patent"},
+		{"GPL-2 (see below)", "GPL-2.0-only"},
+		{"GPL-2+ or MIT-0", "GPL-2.0-or-later OR MIT-0"},
+		{"Zlibish", "NOASSERTION"},
+		{"MIT or custom", "NOASSERTION"},
+
+		// SPDX identifiers are accepted in any case
+		{"unicode-dfs-2016", "Unicode-DFS-2016"},
+		{"ubuntu-font-1.0", "Ubuntu-Font-1.0"},
+	} {
+		if got := NormalizeLicense(tc.license); got != tc.want {
+			t.Errorf("NormalizeLicense(%q) = %q, want %q", tc.license, got, tc.want)
+		}
+	}
+}
+
+func TestConjoinLicenses(t *testing.T) {
+	for _, tc := range []struct {
+		expressions []string
+		want        string
+	}{
+		{nil, "NOASSERTION"},
+		{[]string{"MIT"}, "MIT"},
+		{[]string{"MIT", "Zlib AND MIT"}, "MIT AND Zlib"},
+		{[]string{"GPL-2.0-only OR MIT", "Zlib"}, "(GPL-2.0-only OR MIT) AND Zlib"},
+		{[]string{"MIT", "NOASSERTION"}, "NOASSERTION"},
+	} {
+		if got := ConjoinLicenses(tc.expressions); got != tc.want {
+			t.Errorf("ConjoinLicenses(%q) = %q, want %q", tc.expressions, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package spdx
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// licenseRefPattern finds LicenseRef identifiers in license
+	// expressions
+	licenseRefPattern = regexp.MustCompile(`LicenseRef-[A-Za-z0-9.\-]+`)
+
+	// licenseRefUnsafe matches what an identifier may not contain
+	licenseRefUnsafe = regexp.MustCompile(`[^A-Za-z0-9.]+`)
+)
+
+// licenseRefWith names a license modified by an exception the SPDX
+// exception list has no identifier for, which SPDX 2 cannot express with
+// WITH: "GPL-2.0-or-later" and "OpenSSL exception" become
+// LicenseRef-GPL-2.0-or-later-with-OpenSSL-exception
+func licenseRefWith(id, exception string) string {
+	exception = strings.Trim(licenseRefUnsafe.ReplaceAllString(exception, "-"), "-")
+	return "LicenseRef-" + id + "-with-" + exception
+}
+
+// DefineLicenseRefs adds a hasExtractedLicensingInfos entry for every
+// LicenseRef identifier the document's packages and files use without
+// defining it, as SPDX requires. Definitions are copied from the given
+// source documents when one has them; otherwise the identifier's name
+// serves as the text, which for those from NormalizeLicense is everything
+// the copyright file said.
+func DefineLicenseRefs(doc *Document, sources ...*Document) {
+	defined := make(map[string]bool)
+	for _, info := range doc.HasExtractedLicensingInfos {
+		defined[info.LicenseID] = true
+	}
+	known := make(map[string]ExtractedLicensingInfo)
+	for _, source := range sources {
+		for _, info := range source.HasExtractedLicensingInfos {
+			if _, ok := known[info.LicenseID]; !ok {
+				known[info.LicenseID] = info
+			}
+		}
+	}
+
+	var expressions []string
+	for _, pkg := range doc.Packages {
+		expressions = append(expressions, pkg.LicenseConcluded, pkg.LicenseDeclared)
+	}
+	for _, file := range doc.Files {
+		expressions = append(expressions, file.LicenseConcluded)
+		expressions = append(expressions, file.LicenseInfoInFiles...)
+	}
+
+	var missing []string
+	for _, expression := range expressions {
+		for _, id := range licenseRefPattern.FindAllString(expression, -1) {
+			if !defined[id] {
+				defined[id] = true
+				missing = append(missing, id)
+			}
+		}
+	}
+	sort.Strings(missing)
+
+	for _, id := range missing {
+		info, ok := known[id]
+		if !ok {
+			name := strings.Replace(strings.TrimPrefix(id, "LicenseRef-"), "-with-", " with ", 1)
+			info = ExtractedLicensingInfo{LicenseID: id, ExtractedText: name, Name: name}
+		}
+		doc.HasExtractedLicensingInfos = append(doc.HasExtractedLicensingInfos, info)
+	}
+}
@@ -0,0 +1,44 @@
+package spdx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefineLicenseRefs(t *testing.T) {
+	doc := &Document{
+		Packages: []Package{
+			{SPDXID: "SPDXRef-a", LicenseDeclared: NormalizeLicense("GPL-2+ with OpenSSL exception"), LicenseConcluded: "NOASSERTION"},
+			{SPDXID: "SPDXRef-b", LicenseDeclared: "MIT", LicenseConcluded: "(LicenseRef-GPL-2.0-or-later-with-OpenSSL-exception OR MIT) AND LicenseRef-scancode-foo"},
+			{SPDXID: "SPDXRef-c", LicenseDeclared: "LicenseRef-defined", LicenseConcluded: "NOASSERTION"},
+		},
+		HasExtractedLicensingInfos: []ExtractedLicensingInfo{
+			{LicenseID: "LicenseRef-defined", ExtractedText: "already here"},
+		},
+	}
+	source := &Document{HasExtractedLicensingInfos: []ExtractedLicensingInfo{
+		{LicenseID: "LicenseRef-scancode-foo", ExtractedText: "Foo license text", Name: "Foo"},
+		{LicenseID: "LicenseRef-unused", ExtractedText: "not referenced"},
+	}}
+
+	DefineLicenseRefs(doc, source)
+
+	want := []ExtractedLicensingInfo{
+		{LicenseID: "LicenseRef-defined", ExtractedText: "already here"},
+		{
+			LicenseID:     "LicenseRef-GPL-2.0-or-later-with-OpenSSL-exception",
+			ExtractedText: "GPL-2.0-or-later with OpenSSL-exception",
+			Name:          "GPL-2.0-or-later with OpenSSL-exception",
+		},
+		{LicenseID: "LicenseRef-scancode-foo", ExtractedText: "Foo license text", Name: "Foo"},
+	}
+	if !reflect.DeepEqual(doc.HasExtractedLicensingInfos, want) {
+		t.Errorf("got %+v\nwant %+v", doc.HasExtractedLicensingInfos, want)
+	}
+
+	// Defining again adds nothing
+	DefineLicenseRefs(doc, source)
+	if len(doc.HasExtractedLicensingInfos) != len(want) {
+		t.Errorf("second call added definitions: %+v", doc.HasExtractedLicensingInfos)
+	}
+}
@@ -0,0 +1,203 @@
+package spdx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Load reads an SPDX JSON document from path, transparently
+// decompressing gzip and zstd inputs detected by extension or magic bytes.
+// Like Decode, it fails with a *DecodeError when the content is not an
+// SPDX document of a supported version.
+func Load(path string) (*Document, error) {
+	data, err := ReadDocumentBytes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// Decode reads an SPDX JSON document from r, decompressing gzip and zstd
+// content detected by its magic bytes
+func Decode(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		data, err = gunzip(data)
+	case bytes.HasPrefix(data, zstdMagic):
+		data, err = unzstd(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+
+	return decodeDocument(data)
+}
+
+// DecodeError reports why content could not be read as an SPDX document
+type DecodeError struct {
+	// Offset is the byte offset in the (decompressed) JSON at which the
+	// problem was found, or -1 when it concerns the document as a whole,
+	// such as an unsupported spdxVersion. Line and Column locate the same
+	// byte, counting from 1.
+	Offset int64
+	Line   int
+	Column int
+
+	Message string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Offset < 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s at byte %d (line %d, column %d)", e.Message, e.Offset, e.Line, e.Column)
+}
+
+// plainDocument decodes like Document but without Package.UnmarshalJSON,
+// whose errors carry offsets relative to the package rather than to the
+// document
+type plainDocument struct {
+	Document
+	Packages []plainPackage `json:"packages"`
+}
+
+type plainPackage Package
+
+// decodeDocument parses data as an SPDX document, checking that its
+// spdxVersion is one this tool reads
+func decodeDocument(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			var plain plainDocument
+			if plainErr := json.Unmarshal(data, &plain); plainErr != nil {
+				err = plainErr
+			}
+		}
+		return nil, jsonError(data, err)
+	}
+
+	switch doc.SPDXVersion {
+	case Version23, Version22:
+	case "":
+		return nil, &DecodeError{Offset: -1, Message: "no spdxVersion, not an SPDX document"}
+	default:
+		return nil, &DecodeError{Offset: -1, Message: fmt.Sprintf("unsupported spdxVersion %q: expected %s or %s", doc.SPDXVersion, Version23, Version22)}
+	}
+
+	return &doc, nil
+}
+
+// jsonError turns an encoding/json error into a DecodeError locating the
+// problem in data
+func jsonError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return newDecodeError(data, syntaxErr.Offset, "malformed JSON: "+syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "document"
+		}
+		return newDecodeError(data, typeErr.Offset, fmt.Sprintf("%s is a JSON %s, expected %s", field, typeErr.Value, typeErr.Type))
+	}
+	return &DecodeError{Offset: -1, Message: err.Error()}
+}
+
+// newDecodeError locates a problem by an encoding/json offset, the number
+// of bytes read when it was found: Line and Column point at the last of
+// them, the offending character or the end of the offending value
+func newDecodeError(data []byte, offset int64, message string) *DecodeError {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	last := int(offset) - 1
+	if last < 0 {
+		last = 0
+	}
+	before := data[:last]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := last - bytes.LastIndexByte(before, '\n')
+	return &DecodeError{Offset: offset, Line: line, Column: column, Message: message}
+}
+
+// ReadDocumentBytes returns the decompressed JSON content of a document
+func ReadDocumentBytes(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".gz") || bytes.HasPrefix(data, gzipMagic):
+		data, err = gunzip(data)
+	case strings.HasSuffix(path, ".zst") || bytes.HasPrefix(data, zstdMagic):
+		data, err = unzstd(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+
+	return data, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid gzip stream: %w", err)
+	}
+	defer reader.Close()
+
+	// Surface truncation and checksum errors distinctly rather than as a
+	// JSON parse failure further on
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("truncated or corrupt gzip stream: %w", err)
+	}
+	return output, nil
+}
+
+// unzstd shells out to the zstd CLI since the standard library has no
+// zstd decoder
+func unzstd(data []byte) ([]byte, error) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		return nil, fmt.Errorf("zstd input requires the zstd command: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("zstd", "-d", "-c", "-q")
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("zstd failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
+}
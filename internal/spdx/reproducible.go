@@ -0,0 +1,55 @@
+package spdx
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sourceDateEpochEnv is the reproducible-builds.org variable fixing the
+// timestamps recorded in build outputs
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// SourceDateEpochSet reports whether SOURCE_DATE_EPOCH is set, which
+// requests reproducible output just like --reproducible
+func SourceDateEpochSet() bool {
+	return os.Getenv(sourceDateEpochEnv) != ""
+}
+
+// CreationTime returns the time to record as a document's creation time:
+// SOURCE_DATE_EPOCH when set, the Unix epoch for reproducible output
+// without it, and the current time otherwise
+func CreationTime(reproducible bool) (time.Time, error) {
+	if value := os.Getenv(sourceDateEpochEnv); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s %q: %w", sourceDateEpochEnv, value, err)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	if reproducible {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	return time.Now().UTC(), nil
+}
+
+// ContentUUID derives a UUID from the document's package set (names,
+// versions and purls), for namespaces that are stable across runs over the
+// same packages yet differ when anything is installed or upgraded
+func ContentUUID(doc *Document) string {
+	lines := make([]string, 0, len(doc.Packages))
+	for _, pkg := range doc.Packages {
+		line := pkg.Name + "\t" + pkg.PackageVersion
+		for _, ref := range pkg.ExternalRefs {
+			if ref.Type == "purl" {
+				line += "\t" + ref.Locator
+			}
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return NameUUID(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,71 @@
+package spdx
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SaveDocument writes doc as indented JSON to outputPath atomically
+func SaveDocument(doc *Document, outputPath string) error {
+	return WriteFileAtomic(outputPath, func(w io.Writer) error {
+		return EncodeDocument(w, doc)
+	})
+}
+
+// EncodeDocument writes doc as indented JSON, exactly as SaveDocument
+// stores it. Packages, files and relationships are encoded one at a time
+// rather than the whole document at once, which would hold a second,
+// encoded copy of it in memory. SPDX 2.2 documents are downgraded as they
+// are written.
+func EncodeDocument(w io.Writer, doc *Document) error {
+	return encodeStream(w, doc, sliceNext(doc.Packages), sliceNext(doc.Relationships))
+}
+
+// StdoutPath is the output path that selects standard output
+const StdoutPath = "-"
+
+// Stdout receives output written to StdoutPath. It is captured at start-up
+// so that it still refers to the real standard output if os.Stdout is
+// reassigned later.
+var Stdout io.Writer = os.Stdout
+
+// WriteFileAtomic writes to a temporary file in the same directory as path
+// and renames it into place once write succeeds, so readers only ever see
+// the previous complete file or the new complete file. A path of "-"
+// writes to standard output instead.
+func WriteFileAtomic(path string, write func(w io.Writer) error) error {
+	if path == StdoutPath {
+		return write(Stdout)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Clean up the temp file on any failure; after a successful rename
+	// this is a harmless no-op
+	defer os.Remove(tmpPath)
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmpPath, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
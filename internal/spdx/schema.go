@@ -0,0 +1,82 @@
+package spdx
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaURL is the $id of the SPDX 2.3 JSON schema
+const schemaURL = "http://spdx.org/rdf/terms/2.3"
+
+//go:embed spdx-schema-2.3.json
+var schema23 string
+
+// ValidateSchema checks a JSON document against the SPDX 2.3 JSON schema
+// and returns every field-level violation, prefixed with the JSON pointer
+// of the offending value (e.g. "/creationInfo/creators: minimum 1 items
+// required, but found 0 items"). It returns an error only if data is not
+// JSON.
+func ValidateSchema(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var instance interface{}
+	if err := decoder.Decode(&instance); err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+	if err := compiler.AddResource(schemaURL, strings.NewReader(schema23)); err != nil {
+		return nil, fmt.Errorf("failed to load SPDX schema: %w", err)
+	}
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile SPDX schema: %w", err)
+	}
+
+	err = compiled.Validate(instance)
+	if err == nil {
+		return nil, nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	var violations []string
+	seen := make(map[string]bool)
+	for _, leaf := range schemaLeaves(validationErr) {
+		pointer := leaf.InstanceLocation
+		if pointer == "" {
+			pointer = "/"
+		}
+		violation := fmt.Sprintf("%s: %s", pointer, leaf.Message)
+		if !seen[violation] {
+			seen[violation] = true
+			violations = append(violations, violation)
+		}
+	}
+	sort.Strings(violations)
+	return violations, nil
+}
+
+// schemaLeaves returns the innermost causes of a validation error, which
+// name the individual fields; the outer errors only say that an enclosing
+// object or array did not validate
+func schemaLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, schemaLeaves(cause)...)
+	}
+	return leaves
+}
@@ -0,0 +1,198 @@
+package spdx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// SaveStream writes a document as indented JSON, in the same layout as
+// EncodeDocument, while its packages and relationships arrive on
+// channels. Elements are encoded and written one at a time, so neither
+// the element slices nor the encoded document are held in memory. meta
+// supplies every other field; its Packages and Relationships are ignored.
+//
+// pkgs is read until it is closed, then rels. Producers must close both
+// and either send every package before the first relationship or feed the
+// channels from separate goroutines. If writing fails, the rest of both
+// channels is drained so producers are not left blocked.
+func SaveStream(w io.Writer, pkgs <-chan Package, rels <-chan Relationship, meta Document) error {
+	err := encodeStream(w, &meta, chanNext(pkgs), chanNext(rels))
+	if err != nil {
+		for range pkgs {
+		}
+		for range rels {
+		}
+	}
+	return err
+}
+
+// encodeStream writes doc field by field in struct order, taking packages
+// and relationships from the given iterators. SPDX 2.2 documents are
+// downgraded element by element as they are written.
+func encodeStream(w io.Writer, doc *Document, packages func() (Package, bool), relationships func() (Relationship, bool)) error {
+	downgrade := doc.SPDXVersion == Version22
+	out := bufio.NewWriter(w)
+
+	if _, err := out.WriteString("{"); err != nil {
+		return err
+	}
+
+	docType := reflect.TypeOf(*doc)
+	docValue := reflect.ValueOf(*doc)
+	first := true
+	for i := 0; i < docType.NumField(); i++ {
+		name, options, _ := strings.Cut(docType.Field(i).Tag.Get("json"), ",")
+		value := docValue.Field(i)
+
+		var err error
+		switch name {
+		case "-":
+			continue
+		case "packages":
+			err = writeArray(out, name, &first, func() (interface{}, bool) {
+				pkg, ok := packages()
+				if ok && downgrade {
+					pkg = package22(pkg)
+				}
+				return pkg, ok
+			})
+		case "relationships":
+			err = writeArray(out, name, &first, func() (interface{}, bool) {
+				rel, ok := relationships()
+				if ok && downgrade {
+					rel = relationship22(rel)
+				}
+				return rel, ok
+			})
+		case "files":
+			if len(doc.Files) == 0 {
+				continue
+			}
+			next := sliceNext(doc.Files)
+			err = writeArray(out, name, &first, func() (interface{}, bool) {
+				file, ok := next()
+				if ok && downgrade {
+					file = file22(file)
+				}
+				return file, ok
+			})
+		default:
+			if options == "omitempty" && isEmptyValue(value) {
+				continue
+			}
+			if err = writeKey(out, name, &first); err == nil {
+				err = writeValue(out, value.Interface(), "  ")
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := out.WriteString("\n}\n"); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// writeKey starts a top-level field
+func writeKey(out *bufio.Writer, name string, first *bool) error {
+	separator := ",\n  "
+	if *first {
+		separator = "\n  "
+		*first = false
+	}
+	_, err := out.WriteString(separator + `"` + name + `": `)
+	return err
+}
+
+// writeArray writes a top-level array field, encoding one element at a
+// time as next returns them
+func writeArray(out *bufio.Writer, name string, first *bool, next func() (interface{}, bool)) error {
+	if err := writeKey(out, name, first); err != nil {
+		return err
+	}
+	if _, err := out.WriteString("["); err != nil {
+		return err
+	}
+
+	empty := true
+	for element, ok := next(); ok; element, ok = next() {
+		separator := ",\n    "
+		if empty {
+			separator = "\n    "
+			empty = false
+		}
+		if _, err := out.WriteString(separator); err != nil {
+			return err
+		}
+		if err := writeValue(out, element, "    "); err != nil {
+			return err
+		}
+	}
+
+	closing := "\n  ]"
+	if empty {
+		closing = "]"
+	}
+	_, err := out.WriteString(closing)
+	return err
+}
+
+// writeValue encodes a value indented to sit at prefix
+func writeValue(out *bufio.Writer, value interface{}, prefix string) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent(prefix, "  ")
+	// Purls join qualifiers with &, keep them readable
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	_, err := out.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
+
+// isEmptyValue reports whether encoding/json leaves out a value tagged
+// omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+func sliceNext[T any](items []T) func() (T, bool) {
+	i := 0
+	return func() (T, bool) {
+		var item T
+		if i >= len(items) {
+			return item, false
+		}
+		item = items[i]
+		i++
+		return item, true
+	}
+}
+
+func chanNext[T any](ch <-chan T) func() (T, bool) {
+	return func() (T, bool) {
+		item, ok := <-ch
+		return item, ok
+	}
+}
@@ -0,0 +1,185 @@
+package spdx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SaveTagValue writes doc in SPDX tag-value syntax to outputPath atomically
+func SaveTagValue(doc *Document, outputPath string) error {
+	return WriteFileAtomic(outputPath, func(w io.Writer) error {
+		return WriteTagValue(w, doc)
+	})
+}
+
+// WriteTagValue serializes doc in the SPDX tag-value syntax of its
+// spdxVersion (2.3 or 2.2): document and creation information first, then
+// one block per package, then the relationships
+func WriteTagValue(w io.Writer, doc *Document) error {
+	doc = forSerialization(doc)
+	tw := &tagWriter{w: bufio.NewWriter(w)}
+
+	tw.tag("SPDXVersion", doc.SPDXVersion)
+	tw.tag("DataLicense", doc.DataLicense)
+	tw.tag("SPDXID", doc.SPDXID)
+	tw.tag("DocumentName", doc.Name)
+	tw.tag("DocumentNamespace", doc.DocumentNamespace)
+	for _, ref := range doc.ExternalDocumentRefs {
+		tw.tag("ExternalDocumentRef", fmt.Sprintf("%s %s %s: %s",
+			ref.ExternalDocumentID, ref.SPDXDocument, ref.Checksum.Algorithm, ref.Checksum.Value))
+	}
+
+	tw.section("Creation Information")
+	tw.tag("LicenseListVersion", doc.CreationInfo.LicenseListVersion)
+	for _, creator := range doc.CreationInfo.Creators {
+		tw.tag("Creator", creator)
+	}
+	tw.tag("Created", doc.CreationInfo.Created)
+	tw.text("CreatorComment", doc.CreationInfo.Comment)
+
+	for _, annotation := range doc.Annotations {
+		tw.annotation(doc.SPDXID, annotation)
+	}
+
+	// Files are written after the package that CONTAINS them, as tag-value
+	// readers associate a file with the preceding package
+	filesByID := make(map[string]File)
+	for _, file := range doc.Files {
+		filesByID[file.SPDXID] = file
+	}
+	written := make(map[string]bool)
+	containedFiles := make(map[string][]File)
+	for _, rel := range doc.Relationships {
+		if file, ok := filesByID[rel.RelatedSPDXElement]; ok && rel.RelationshipType == "CONTAINS" && !written[file.SPDXID] {
+			containedFiles[rel.SPDXElementID] = append(containedFiles[rel.SPDXElementID], file)
+			written[file.SPDXID] = true
+		}
+	}
+
+	for _, pkg := range doc.Packages {
+		tw.section("Package")
+		tw.tag("PackageName", pkg.Name)
+		tw.tag("SPDXID", pkg.SPDXID)
+		tw.tag("PackageVersion", pkg.PackageVersion)
+		tw.tag("PackageSupplier", pkg.Supplier)
+		tw.tag("PackageDownloadLocation", pkg.DownloadLocation)
+		tw.tag("FilesAnalyzed", fmt.Sprintf("%t", pkg.FilesAnalyzed))
+		if pkg.VerificationCode != nil {
+			tw.tag("PackageVerificationCode", pkg.VerificationCode.Value)
+		}
+		for _, checksum := range pkg.Checksums {
+			tw.tag("PackageChecksum", fmt.Sprintf("%s: %s", checksum.Algorithm, checksum.Value))
+		}
+		tw.tag("PackageHomePage", pkg.HomePage)
+		tw.tag("PrimaryPackagePurpose", pkg.PrimaryPackagePurpose)
+		tw.tag("PackageLicenseConcluded", pkg.LicenseConcluded)
+		tw.tag("PackageLicenseDeclared", pkg.LicenseDeclared)
+		tw.text("PackageCopyrightText", pkg.CopyrightText)
+		tw.text("PackageDescription", pkg.Description)
+		for _, ref := range pkg.ExternalRefs {
+			tw.tag("ExternalRef", fmt.Sprintf("%s %s %s", ref.Category, ref.Type, ref.Locator))
+		}
+		for _, annotation := range pkg.Annotations {
+			tw.annotation(pkg.SPDXID, annotation)
+		}
+		for _, file := range containedFiles[pkg.SPDXID] {
+			tw.file(file)
+		}
+	}
+
+	for _, file := range doc.Files {
+		if !written[file.SPDXID] {
+			tw.file(file)
+		}
+	}
+
+	for _, info := range doc.HasExtractedLicensingInfos {
+		tw.section("Other Licensing Information")
+		tw.tag("LicenseID", info.LicenseID)
+		tw.text("ExtractedText", info.ExtractedText)
+		tw.tag("LicenseName", info.Name)
+	}
+
+	if len(doc.Relationships) > 0 {
+		tw.section("Relationships")
+		for _, rel := range doc.Relationships {
+			tw.tag("Relationship", fmt.Sprintf("%s %s %s", rel.SPDXElementID, rel.RelationshipType, rel.RelatedSPDXElement))
+			tw.text("RelationshipComment", rel.Comment)
+		}
+	}
+
+	if tw.err != nil {
+		return tw.err
+	}
+	return tw.w.Flush()
+}
+
+// tagWriter writes tag-value lines, keeping the first error
+type tagWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (tw *tagWriter) line(format string, args ...interface{}) {
+	if tw.err != nil {
+		return
+	}
+	_, tw.err = fmt.Fprintf(tw.w, format+"\n", args...)
+}
+
+func (tw *tagWriter) section(name string) {
+	tw.line("")
+	tw.line("## %s", name)
+	tw.line("")
+}
+
+// tag writes a single-line value, omitting empty ones
+func (tw *tagWriter) tag(name, value string) {
+	if value == "" {
+		return
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		tw.text(name, value)
+		return
+	}
+	tw.line("%s: %s", name, value)
+}
+
+// text writes free-form values wrapped in <text> so they may span lines.
+// The format has no escape for a literal </text>, so one is rewritten to
+// keep the block well-formed.
+func (tw *tagWriter) text(name, value string) {
+	if value == "" {
+		return
+	}
+	if value == "NONE" || value == "NOASSERTION" {
+		tw.line("%s: %s", name, value)
+		return
+	}
+	value = strings.ReplaceAll(value, "</text>", "&lt;/text&gt;")
+	tw.line("%s: <text>%s</text>", name, value)
+}
+
+func (tw *tagWriter) file(file File) {
+	tw.section("File")
+	tw.tag("FileName", file.FileName)
+	tw.tag("SPDXID", file.SPDXID)
+	for _, checksum := range file.Checksums {
+		tw.tag("FileChecksum", fmt.Sprintf("%s: %s", checksum.Algorithm, checksum.Value))
+	}
+	tw.tag("LicenseConcluded", file.LicenseConcluded)
+	for _, license := range file.LicenseInfoInFiles {
+		tw.tag("LicenseInfoInFile", license)
+	}
+	tw.text("FileCopyrightText", file.CopyrightText)
+}
+
+func (tw *tagWriter) annotation(id string, annotation Annotation) {
+	tw.line("Annotator: %s", annotation.Annotator)
+	tw.line("AnnotationDate: %s", annotation.AnnotationDate)
+	tw.line("AnnotationType: %s", annotation.AnnotationType)
+	tw.line("SPDXREF: %s", id)
+	tw.text("AnnotationComment", annotation.Comment)
+}
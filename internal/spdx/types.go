@@ -1,21 +1,51 @@
 package spdx
 
+import "encoding/json"
+
 // SPDX Document structure
 type Document struct {
-	SPDXVersion       string         `json:"spdxVersion"`
-	DataLicense       string         `json:"dataLicense"`
-	SPDXID            string         `json:"SPDXID"`
-	Name              string         `json:"name"`
-	DocumentNamespace string         `json:"documentNamespace"`
-	CreationInfo      CreationInfo   `json:"creationInfo"`
-	Packages          []Package      `json:"packages"`
-	Relationships     []Relationship `json:"relationships"`
+	SPDXVersion          string                `json:"spdxVersion"`
+	DataLicense          string                `json:"dataLicense"`
+	SPDXID               string                `json:"SPDXID"`
+	Name                 string                `json:"name"`
+	DocumentNamespace    string                `json:"documentNamespace"`
+	DocumentDescribes    []string              `json:"documentDescribes,omitempty"`
+	ExternalDocumentRefs []ExternalDocumentRef `json:"externalDocumentRefs,omitempty"`
+	CreationInfo         CreationInfo          `json:"creationInfo"`
+	Packages             []Package             `json:"packages"`
+	Files                []File                `json:"files,omitempty"`
+	Relationships        []Relationship        `json:"relationships"`
+	Annotations          []Annotation          `json:"annotations,omitempty"`
+
+	// HasExtractedLicensingInfos defines the LicenseRef- identifiers the
+	// document's license expressions use
+	HasExtractedLicensingInfos []ExtractedLicensingInfo `json:"hasExtractedLicensingInfos,omitempty"`
+
+	// Nested lists packages merged from another tool's document that
+	// are related to the root through that document's relationships
+	// rather than contained by it directly (see CheckConsistency). It is
+	// not part of the SPDX output.
+	Nested []string `json:"-"`
+}
+
+// ExtractedLicensingInfo defines a license that is not on the SPDX list
+type ExtractedLicensingInfo struct {
+	LicenseID     string `json:"licenseId"`
+	ExtractedText string `json:"extractedText"`
+	Name          string `json:"name,omitempty"`
+}
+
+type ExternalDocumentRef struct {
+	ExternalDocumentID string   `json:"externalDocumentId"`
+	SPDXDocument       string   `json:"spdxDocument"`
+	Checksum           Checksum `json:"checksum"`
 }
 
 type CreationInfo struct {
 	Created            string   `json:"created"`
 	Creators           []string `json:"creators"`
 	LicenseListVersion string   `json:"licenseListVersion"`
+	Comment            string   `json:"comment,omitempty"`
 }
 
 type Package struct {
@@ -23,16 +53,51 @@ type Package struct {
 	Name             string        `json:"name"`
 	DownloadLocation string        `json:"downloadLocation"`
 	FilesAnalyzed    bool          `json:"filesAnalyzed"`
-	VerificationCode *Verification `json:"verificationCode,omitempty"`
+	VerificationCode *Verification `json:"packageVerificationCode,omitempty"`
 	Checksums        []Checksum    `json:"checksums,omitempty"`
-	HomePage         string        `json:"homePage,omitempty"`
+	HomePage         string        `json:"homepage,omitempty"`
 	LicenseConcluded string        `json:"licenseConcluded"`
 	LicenseDeclared  string        `json:"licenseDeclared"`
 	CopyrightText    string        `json:"copyrightText"`
 	Description      string        `json:"description,omitempty"`
 	PackageVersion   string        `json:"versionInfo,omitempty"`
 	Supplier         string        `json:"supplier,omitempty"`
-	ExternalRefs     []ExternalRef `json:"externalRefs,omitempty"`
+	Originator       string        `json:"originator,omitempty"`
+	// PrimaryPackagePurpose is APPLICATION, LIBRARY, OPERATING-SYSTEM,
+	// FIRMWARE, ... or empty when unknown
+	PrimaryPackagePurpose string        `json:"primaryPackagePurpose,omitempty"`
+	ExternalRefs          []ExternalRef `json:"externalRefs,omitempty"`
+	Annotations           []Annotation  `json:"annotations,omitempty"`
+}
+
+// UnmarshalJSON also reads the verificationCode key documents written by
+// earlier versions used instead of packageVerificationCode. The homePage
+// spelling needs nothing as keys match case-insensitively.
+func (p *Package) UnmarshalJSON(data []byte) error {
+	type plain Package
+	var pkg struct {
+		plain
+		LegacyVerificationCode *Verification `json:"verificationCode"`
+	}
+	// Like encoding/json, keep what was decoded even when a value had
+	// the wrong type
+	err := json.Unmarshal(data, &pkg)
+	*p = Package(pkg.plain)
+	if p.VerificationCode == nil {
+		p.VerificationCode = pkg.LegacyVerificationCode
+	}
+	return err
+}
+
+type File struct {
+	SPDXID    string     `json:"SPDXID"`
+	FileName  string     `json:"fileName"`
+	Checksums []Checksum `json:"checksums"`
+	// The license and copyright fields are optional since SPDX 2.3 and
+	// only filled in for SPDX 2.2 output
+	LicenseConcluded   string   `json:"licenseConcluded,omitempty"`
+	LicenseInfoInFiles []string `json:"licenseInfoInFiles,omitempty"`
+	CopyrightText      string   `json:"copyrightText,omitempty"`
 }
 
 type Verification struct {
@@ -48,6 +113,7 @@ type Relationship struct {
 	SPDXElementID      string `json:"spdxElementId"`
 	RelatedSPDXElement string `json:"relatedSpdxElement"`
 	RelationshipType   string `json:"relationshipType"`
+	Comment            string `json:"comment,omitempty"`
 }
 
 type ExternalRef struct {
@@ -55,3 +121,10 @@ type ExternalRef struct {
 	Type     string `json:"referenceType"`
 	Locator  string `json:"referenceLocator"`
 }
+
+type Annotation struct {
+	AnnotationDate string `json:"annotationDate"`
+	AnnotationType string `json:"annotationType"`
+	Annotator      string `json:"annotator"`
+	Comment        string `json:"comment"`
+}
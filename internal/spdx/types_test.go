@@ -0,0 +1,29 @@
+package spdx
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPackageUnmarshalWrongType(t *testing.T) {
+	var pkg Package
+	err := json.Unmarshal([]byte(`{
+  "SPDXID": "SPDXRef-a",
+  "name": "a",
+  "versionInfo": 1.2,
+  "verificationCode": {"packageVerificationCodeValue": "d6a770ba38583ed4bb4525bd96e50461655d2758"},
+  "supplier": "Organization: Example"
+}`), &pkg)
+
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("got error %v, want a type error", err)
+	}
+	if pkg.SPDXID != "SPDXRef-a" || pkg.Name != "a" || pkg.Supplier != "Organization: Example" || pkg.PackageVersion != "" {
+		t.Errorf("decoded %+v, want every field but versionInfo", pkg)
+	}
+	if pkg.VerificationCode == nil || pkg.VerificationCode.Value != "d6a770ba38583ed4bb4525bd96e50461655d2758" {
+		t.Errorf("verification code %+v, want the legacy key read", pkg.VerificationCode)
+	}
+}
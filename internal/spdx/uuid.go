@@ -0,0 +1,40 @@
+package spdx
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+)
+
+// NewUUID returns a random RFC 4122 version 4 UUID, used for document
+// namespaces and serial numbers
+func NewUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// urlNamespace is the RFC 4122 URL namespace used for name-based UUIDs
+var urlNamespace = []byte{
+	0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}
+
+// NameUUID returns the RFC 4122 version 5 UUID of name in the URL
+// namespace, so the same name always yields the same UUID
+func NameUUID(name string) string {
+	h := sha1.New()
+	h.Write(urlNamespace)
+	h.Write([]byte(name))
+	b := h.Sum(nil)
+
+	b[6] = (b[6] & 0x0f) | 0x50 // version 5
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
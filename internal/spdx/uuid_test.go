@@ -0,0 +1,47 @@
+package spdx
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// uuidPattern captures the version and variant nibbles of a UUID
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([0-9a-f])[0-9a-f]{3}-([0-9a-f])[0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func checkUUID(t *testing.T, id, version string) {
+	t.Helper()
+	m := uuidPattern.FindStringSubmatch(id)
+	if m == nil {
+		t.Fatalf("%q is not a UUID", id)
+	}
+	if m[1] != version {
+		t.Errorf("%q has version %s, want %s", id, m[1], version)
+	}
+	if !strings.Contains("89ab", m[2]) {
+		t.Errorf("%q does not have the RFC 4122 variant", id)
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := NewUUID()
+		checkUUID(t, id, "4")
+		if seen[id] {
+			t.Fatalf("duplicate UUID %q after %d", id, i)
+		}
+		seen[id] = true
+	}
+}
+
+func TestNameUUID(t *testing.T) {
+	id := NameUUID("https://example.com/sbom")
+	checkUUID(t, id, "5")
+	if want := "a56f7ad0-ffa3-5fcf-9128-8a7b7348a116"; id != want {
+		t.Errorf("got %s, want %s", id, want)
+	}
+	if again := NameUUID("https://example.com/sbom"); again != id {
+		t.Errorf("NameUUID is not stable: %s, then %s", id, again)
+	}
+}
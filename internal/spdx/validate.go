@@ -0,0 +1,99 @@
+package spdx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// spdxIDPattern is the SPDX identifier syntax: SPDXRef- followed by
+// letters, digits, dots and hyphens
+var spdxIDPattern = regexp.MustCompile(`^SPDXRef-[A-Za-z0-9.-]+$`)
+
+// Validate checks that a document is well-formed and returns every
+// violation found: missing required fields, malformed or duplicate
+// SPDXIDs, relationships pointing at packages or files that do not exist,
+// and a DESCRIBES count other than one.
+func Validate(doc *Document) []string {
+	var violations []string
+
+	required := []struct{ field, value string }{
+		{"spdxVersion", doc.SPDXVersion},
+		{"dataLicense", doc.DataLicense},
+		{"name", doc.Name},
+		{"SPDXID", doc.SPDXID},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			violations = append(violations, fmt.Sprintf("required field %s is empty", r.field))
+		}
+	}
+
+	elements := map[string]bool{doc.SPDXID: true}
+	if doc.SPDXID != "" && !spdxIDPattern.MatchString(doc.SPDXID) {
+		violations = append(violations, fmt.Sprintf("document SPDXID %q does not match %s", doc.SPDXID, spdxIDPattern))
+	}
+
+	for i, pkg := range doc.Packages {
+		switch {
+		case pkg.SPDXID == "":
+			violations = append(violations, fmt.Sprintf("package %d (%s) has no SPDXID", i, pkg.Name))
+			continue
+		case !spdxIDPattern.MatchString(pkg.SPDXID):
+			violations = append(violations, fmt.Sprintf("package SPDXID %q does not match %s", pkg.SPDXID, spdxIDPattern))
+		}
+		if elements[pkg.SPDXID] {
+			violations = append(violations, fmt.Sprintf("SPDXID %s is not unique", pkg.SPDXID))
+		}
+		elements[pkg.SPDXID] = true
+	}
+
+	for _, file := range doc.Files {
+		if !spdxIDPattern.MatchString(file.SPDXID) {
+			violations = append(violations, fmt.Sprintf("file SPDXID %q does not match %s", file.SPDXID, spdxIDPattern))
+		}
+		if elements[file.SPDXID] {
+			violations = append(violations, fmt.Sprintf("SPDXID %s is not unique", file.SPDXID))
+		}
+		elements[file.SPDXID] = true
+	}
+
+	externalDocs := make(map[string]bool)
+	for _, ref := range doc.ExternalDocumentRefs {
+		externalDocs[ref.ExternalDocumentID] = true
+	}
+
+	// References into external documents are valid if the document is
+	// declared; NONE and NOASSERTION are allowed as the related element
+	known := func(id string, related bool) bool {
+		if elements[id] {
+			return true
+		}
+		if related && (id == "NONE" || id == "NOASSERTION") {
+			return true
+		}
+		if docRef, _, ok := strings.Cut(id, ":"); ok && strings.HasPrefix(docRef, "DocumentRef-") {
+			return externalDocs[docRef]
+		}
+		return false
+	}
+
+	describes := 0
+	for _, rel := range doc.Relationships {
+		if !known(rel.SPDXElementID, false) {
+			violations = append(violations, fmt.Sprintf("%s relationship from unknown element %s", rel.RelationshipType, rel.SPDXElementID))
+		}
+		if !known(rel.RelatedSPDXElement, true) {
+			violations = append(violations, fmt.Sprintf("%s relationship from %s to unknown element %s", rel.RelationshipType, rel.SPDXElementID, rel.RelatedSPDXElement))
+		}
+		if rel.SPDXElementID == doc.SPDXID && rel.RelationshipType == "DESCRIBES" {
+			describes++
+		}
+	}
+
+	if describes != 1 {
+		violations = append(violations, fmt.Sprintf("expected exactly 1 DESCRIBES relationship from %s, found %d", doc.SPDXID, describes))
+	}
+
+	return violations
+}
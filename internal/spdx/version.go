@@ -0,0 +1,148 @@
+package spdx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SPDX specification versions a document can be written as
+const (
+	Version23 = "SPDX-2.3"
+	Version22 = "SPDX-2.2"
+)
+
+// ParseVersion maps a version given as "2.3" or "SPDX-2.3" to its
+// spdxVersion value
+func ParseVersion(version string) (string, error) {
+	switch "SPDX-" + strings.TrimPrefix(version, "SPDX-") {
+	case Version23:
+		return Version23, nil
+	case Version22:
+		return Version22, nil
+	}
+	return "", fmt.Errorf("unsupported SPDX version %q: expected 2.3 or 2.2", version)
+}
+
+// security22Types are the SECURITY external reference types SPDX 2.2
+// defines; the others (advisory, fix, url, swid) came with 2.3
+var security22Types = map[string]bool{
+	"cpe22Type": true,
+	"cpe23Type": true,
+}
+
+// relationship23Types are the relationship types added in SPDX 2.3
+var relationship23Types = map[string]bool{
+	"REQUIREMENT_DESCRIPTION_FOR": true,
+	"SPECIFICATION_FOR":           true,
+}
+
+// checksum22Algorithms are the checksum algorithms SPDX 2.2 defines
+var checksum22Algorithms = map[string]bool{
+	"SHA1": true, "SHA224": true, "SHA256": true, "SHA384": true, "SHA512": true,
+	"MD2": true, "MD4": true, "MD5": true, "MD6": true,
+}
+
+// forSerialization returns the document to serialize for doc's
+// spdxVersion. SPDX 2.3 documents are written as they are. For SPDX 2.2 a
+// copy is returned without what 2.2 consumers reject:
+//
+//   - primaryPackagePurpose is dropped
+//   - the PACKAGE-MANAGER reference category is spelled PACKAGE_MANAGER
+//   - SECURITY references other than CPEs (advisory, swid) become OTHER
+//   - checksums with algorithms added in 2.3 (SHA3, BLAKE, ADLER32) are
+//     dropped
+//   - relationships of types added in 2.3 become OTHER, with the original
+//     type in their comment
+//   - package license, copyright and download fields that 2.3 made
+//     optional are NOASSERTION when empty, and files get the
+//     licenseConcluded, licenseInfoInFiles and copyrightText 2.2 requires
+func forSerialization(doc *Document) *Document {
+	if doc.SPDXVersion != Version22 {
+		return doc
+	}
+
+	out := *doc
+
+	out.Packages = make([]Package, len(doc.Packages))
+	for i, pkg := range doc.Packages {
+		out.Packages[i] = package22(pkg)
+	}
+
+	if doc.Files != nil {
+		out.Files = make([]File, len(doc.Files))
+		for i, file := range doc.Files {
+			out.Files[i] = file22(file)
+		}
+	}
+
+	out.Relationships = make([]Relationship, len(doc.Relationships))
+	for i, rel := range doc.Relationships {
+		out.Relationships[i] = relationship22(rel)
+	}
+
+	return &out
+}
+
+// package22 returns pkg as SPDX 2.2 expects it
+func package22(pkg Package) Package {
+	pkg.PrimaryPackagePurpose = ""
+	pkg.LicenseConcluded = orNoAssertion(pkg.LicenseConcluded)
+	pkg.LicenseDeclared = orNoAssertion(pkg.LicenseDeclared)
+	pkg.CopyrightText = orNoAssertion(pkg.CopyrightText)
+	pkg.DownloadLocation = orNoAssertion(pkg.DownloadLocation)
+	pkg.Checksums = checksums22(pkg.Checksums)
+
+	refs := make([]ExternalRef, len(pkg.ExternalRefs))
+	for j, ref := range pkg.ExternalRefs {
+		switch {
+		case ref.Category == "PACKAGE-MANAGER":
+			ref.Category = "PACKAGE_MANAGER"
+		case ref.Category == "SECURITY" && !security22Types[ref.Type]:
+			ref.Category = "OTHER"
+		}
+		refs[j] = ref
+	}
+	pkg.ExternalRefs = refs
+	return pkg
+}
+
+// file22 returns file as SPDX 2.2 expects it
+func file22(file File) File {
+	file.Checksums = checksums22(file.Checksums)
+	file.LicenseConcluded = orNoAssertion(file.LicenseConcluded)
+	if len(file.LicenseInfoInFiles) == 0 {
+		file.LicenseInfoInFiles = []string{"NOASSERTION"}
+	}
+	file.CopyrightText = orNoAssertion(file.CopyrightText)
+	return file
+}
+
+// relationship22 returns rel as SPDX 2.2 expects it
+func relationship22(rel Relationship) Relationship {
+	if relationship23Types[rel.RelationshipType] {
+		comment := rel.RelationshipType
+		if rel.Comment != "" {
+			comment += ": " + rel.Comment
+		}
+		rel.RelationshipType = "OTHER"
+		rel.Comment = comment
+	}
+	return rel
+}
+
+func checksums22(checksums []Checksum) []Checksum {
+	var kept []Checksum
+	for _, checksum := range checksums {
+		if checksum22Algorithms[checksum.Algorithm] {
+			kept = append(kept, checksum)
+		}
+	}
+	return kept
+}
+
+func orNoAssertion(value string) string {
+	if value == "" {
+		return "NOASSERTION"
+	}
+	return value
+}
@@ -0,0 +1,202 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// Aggregator accumulates package statistics across many SBOMs. Documents
+// are added one at a time and only the aggregated counts are retained, so
+// memory use depends on the number of distinct packages, not documents.
+type Aggregator struct {
+	documents   int
+	packages    map[packageKey]int
+	licenses    map[string]int
+	noAssertion map[string]int
+}
+
+type packageKey struct {
+	name    string
+	version string
+}
+
+// PackageCount is the number of hosts a package (version) appears on
+type PackageCount struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Hosts   int    `json:"hosts"`
+}
+
+// LicenseCount is the number of package instances with a license
+type LicenseCount struct {
+	License  string `json:"license"`
+	Packages int    `json:"packages"`
+}
+
+// Report is the aggregated fleet summary
+type Report struct {
+	Documents   int            `json:"documents"`
+	Packages    []PackageCount `json:"packages"`
+	Licenses    []LicenseCount `json:"licenses"`
+	NoAssertion []PackageCount `json:"noAssertion"`
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		packages:    make(map[packageKey]int),
+		licenses:    make(map[string]int),
+		noAssertion: make(map[string]int),
+	}
+}
+
+// Add folds one host's document into the aggregate
+func (a *Aggregator) Add(doc *spdx.Document) {
+	a.documents++
+
+	// Root packages describe the system itself, not installed software
+	roots := rootIDs(doc)
+
+	seen := make(map[packageKey]bool)
+	seenNoAssertion := make(map[string]bool)
+
+	for _, pkg := range doc.Packages {
+		if roots[pkg.SPDXID] {
+			continue
+		}
+
+		key := packageKey{name: pkg.Name, version: pkg.PackageVersion}
+		if !seen[key] {
+			a.packages[key]++
+			seen[key] = true
+		}
+
+		license := pkg.LicenseConcluded
+		if license == "" {
+			license = "NOASSERTION"
+		}
+		a.licenses[license]++
+
+		if license == "NOASSERTION" && !seenNoAssertion[pkg.Name] {
+			a.noAssertion[pkg.Name]++
+			seenNoAssertion[pkg.Name] = true
+		}
+	}
+}
+
+// Report returns the aggregated statistics, limiting each list to the top
+// entries (0 means no limit)
+func (a *Aggregator) Report(top int) *Report {
+	report := &Report{
+		Documents:   a.documents,
+		Packages:    []PackageCount{},
+		Licenses:    []LicenseCount{},
+		NoAssertion: []PackageCount{},
+	}
+
+	for key, hosts := range a.packages {
+		report.Packages = append(report.Packages, PackageCount{Name: key.name, Version: key.version, Hosts: hosts})
+	}
+	sortPackageCounts(report.Packages)
+
+	for license, count := range a.licenses {
+		report.Licenses = append(report.Licenses, LicenseCount{License: license, Packages: count})
+	}
+	sort.Slice(report.Licenses, func(i, j int) bool {
+		if report.Licenses[i].Packages != report.Licenses[j].Packages {
+			return report.Licenses[i].Packages > report.Licenses[j].Packages
+		}
+		return report.Licenses[i].License < report.Licenses[j].License
+	})
+
+	for name, hosts := range a.noAssertion {
+		report.NoAssertion = append(report.NoAssertion, PackageCount{Name: name, Hosts: hosts})
+	}
+	sortPackageCounts(report.NoAssertion)
+
+	if top > 0 {
+		report.Packages = truncate(report.Packages, top)
+		report.NoAssertion = truncate(report.NoAssertion, top)
+		if len(report.Licenses) > top {
+			report.Licenses = report.Licenses[:top]
+		}
+	}
+
+	return report
+}
+
+func sortPackageCounts(counts []PackageCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Hosts != counts[j].Hosts {
+			return counts[i].Hosts > counts[j].Hosts
+		}
+		if counts[i].Name != counts[j].Name {
+			return counts[i].Name < counts[j].Name
+		}
+		return counts[i].Version < counts[j].Version
+	})
+}
+
+func truncate(counts []PackageCount, n int) []PackageCount {
+	if len(counts) > n {
+		return counts[:n]
+	}
+	return counts
+}
+
+// WriteText writes a human-readable summary
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Documents analyzed: %d\n", r.Documents)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Most common packages:")
+	for _, p := range r.Packages {
+		fmt.Fprintf(w, "  %-40s %-30s %d hosts\n", p.Name, p.Version, p.Hosts)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "License distribution:")
+	for _, l := range r.Licenses {
+		fmt.Fprintf(w, "  %-40s %d packages\n", l.License, l.Packages)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Most common packages without a resolved license:")
+	for _, p := range r.NoAssertion {
+		fmt.Fprintf(w, "  %-40s %d hosts\n", p.Name, p.Hosts)
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes the report as a single CSV table with a section column
+func (r *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"section", "name", "version", "count"})
+	writer.Write([]string{"documents", "", "", strconv.Itoa(r.Documents)})
+	for _, p := range r.Packages {
+		writer.Write([]string{"package", p.Name, p.Version, strconv.Itoa(p.Hosts)})
+	}
+	for _, l := range r.Licenses {
+		writer.Write([]string{"license", l.License, "", strconv.Itoa(l.Packages)})
+	}
+	for _, p := range r.NoAssertion {
+		writer.Write([]string{"noassertion", p.Name, "", strconv.Itoa(p.Hosts)})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
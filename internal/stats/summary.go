@@ -0,0 +1,132 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// summaryTopLicenses is the number of licenses listed in a summary
+const summaryTopLicenses = 10
+
+// Summary is a breakdown of a single document
+type Summary struct {
+	Packages         int            `json:"packages"`
+	Relationships    int            `json:"relationships"`
+	WithLicense      int            `json:"withLicense"`
+	WithoutLicense   int            `json:"withoutLicense"`
+	WithHomepage     int            `json:"withHomepage"`
+	DistinctLicenses int            `json:"distinctLicenses"`
+	TopLicenses      []LicenseCount `json:"topLicenses"`
+}
+
+// Summarize counts the packages of doc by concluded license, whether the
+// license is resolved and whether a homepage is known. Root packages
+// describing the system itself are not counted.
+func Summarize(doc *spdx.Document) *Summary {
+	roots := rootIDs(doc)
+
+	summary := &Summary{
+		Relationships: len(doc.Relationships),
+		TopLicenses:   []LicenseCount{},
+	}
+	licenses := make(map[string]int)
+
+	for _, pkg := range doc.Packages {
+		if roots[pkg.SPDXID] {
+			continue
+		}
+		summary.Packages++
+
+		license := pkg.LicenseConcluded
+		if license == "" {
+			license = "NOASSERTION"
+		}
+		licenses[license]++
+		if license == "NOASSERTION" {
+			summary.WithoutLicense++
+		} else {
+			summary.WithLicense++
+		}
+
+		if pkg.HomePage != "" {
+			summary.WithHomepage++
+		}
+	}
+
+	for license, count := range licenses {
+		summary.TopLicenses = append(summary.TopLicenses, LicenseCount{License: license, Packages: count})
+	}
+	sort.Slice(summary.TopLicenses, func(i, j int) bool {
+		if summary.TopLicenses[i].Packages != summary.TopLicenses[j].Packages {
+			return summary.TopLicenses[i].Packages > summary.TopLicenses[j].Packages
+		}
+		return summary.TopLicenses[i].License < summary.TopLicenses[j].License
+	})
+	summary.DistinctLicenses = len(summary.TopLicenses)
+	if len(summary.TopLicenses) > summaryTopLicenses {
+		summary.TopLicenses = summary.TopLicenses[:summaryTopLicenses]
+	}
+
+	return summary
+}
+
+// WriteText writes a human-readable summary
+func (s *Summary) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Packages: %d\n", s.Packages)
+	fmt.Fprintf(w, "  with a resolved license: %d\n", s.WithLicense)
+	fmt.Fprintf(w, "  without (NOASSERTION):   %d\n", s.WithoutLicense)
+	fmt.Fprintf(w, "  with a homepage:         %d\n", s.WithHomepage)
+	fmt.Fprintf(w, "Relationships: %d\n", s.Relationships)
+
+	fmt.Fprintf(w, "Top licenses (%d distinct):\n", s.DistinctLicenses)
+	for _, l := range s.TopLicenses {
+		marker := ""
+		if l.License == "NOASSERTION" {
+			marker = "  (unresolved)"
+		}
+		fmt.Fprintf(w, "  %-40s %d packages%s\n", l.License, l.Packages, marker)
+	}
+
+	return nil
+}
+
+// WriteJSON writes the summary as a single-line JSON object
+func (s *Summary) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// Unresolved returns the packages of doc whose concluded license is
+// NOASSERTION and the number of packages checked. Root packages are not
+// counted.
+func Unresolved(doc *spdx.Document) ([]spdx.Package, int) {
+	roots := rootIDs(doc)
+
+	var unresolved []spdx.Package
+	total := 0
+	for _, pkg := range doc.Packages {
+		if roots[pkg.SPDXID] {
+			continue
+		}
+		total++
+		if pkg.LicenseConcluded == "" || pkg.LicenseConcluded == "NOASSERTION" {
+			unresolved = append(unresolved, pkg)
+		}
+	}
+	return unresolved, total
+}
+
+// rootIDs returns the SPDXIDs of the packages a document describes, which
+// stand for the system itself rather than installed software
+func rootIDs(doc *spdx.Document) map[string]bool {
+	roots := make(map[string]bool)
+	for _, rel := range doc.Relationships {
+		if rel.RelationshipType == "DESCRIBES" {
+			roots[rel.RelatedSPDXElement] = true
+		}
+	}
+	return roots
+}
@@ -0,0 +1,102 @@
+package ubuntu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// aptShowBatchSize bounds the number of packages passed to a single
+// apt-cache invocation to stay well clear of argument length limits
+const aptShowBatchSize = 500
+
+// lookupDownloadSizes asks apt for the .deb download size of each package
+// version. Packages apt doesn't know about are absent from the result.
+// The map is keyed by aptKey.
+func (g *Generator) lookupDownloadSizes(packages []DpkgPackage) (map[string]int64, error) {
+	var args []string
+	for _, pkg := range packages {
+		if pkg.Version == "" {
+			continue
+		}
+		name := pkg.Name
+		if pkg.Architecture != "" {
+			name += ":" + pkg.Architecture
+		}
+		args = append(args, name+"="+pkg.Version)
+	}
+
+	sizes := make(map[string]int64)
+	for start := 0; start < len(args); start += aptShowBatchSize {
+		end := start + aptShowBatchSize
+		if end > len(args) {
+			end = len(args)
+		}
+
+		cmdArgs := append([]string{"show", "--no-all-versions"}, args[start:end]...)
+		output, err := g.Runner.Output("apt-cache", cmdArgs...)
+		if err != nil {
+			// apt-cache exits non-zero when any version is unknown but
+			// still prints the stanzas it found
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, err
+			}
+		}
+
+		parseAptSizes(output, sizes)
+	}
+
+	return sizes, nil
+}
+
+// readDownloadSizes reads the .deb download sizes from the Packages lists
+// in listsDir, for a DpkgRoot whose apt-cache cannot be asked. The map is
+// keyed by aptKey.
+func (g *Generator) readDownloadSizes(listsDir string) (map[string]int64, error) {
+	entries, err := os.ReadDir(listsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64)
+	lists := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.Contains(name, "_Packages") || !strings.Contains(name, "_dists_") {
+			continue
+		}
+
+		data, err := g.readAptList(filepath.Join(listsDir, name))
+		if err != nil {
+			continue
+		}
+		lists++
+		parseAptSizes(data, sizes)
+	}
+
+	if lists == 0 {
+		return nil, fmt.Errorf("no package lists in %s", listsDir)
+	}
+	return sizes, nil
+}
+
+// parseAptSizes reads the Size field from apt-cache show stanzas
+func parseAptSizes(output []byte, sizes map[string]int64) {
+	readStanzas(bytes.NewReader(output), func(fields map[string]string) {
+		size, err := strconv.ParseInt(fields["Size"], 10, 64)
+		if err != nil || fields["Package"] == "" {
+			return
+		}
+		sizes[aptKey(fields["Package"], fields["Version"], fields["Architecture"])] = size
+	})
+}
+
+func aptKey(name, version, arch string) string {
+	return name + "\x00" + version + "\x00" + arch
+}
@@ -0,0 +1,90 @@
+package ubuntu
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeRoot creates the files of a dpkg root, by path relative to it
+func writeRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+const rootStatus = `Package: bash
+Status: install ok installed
+Architecture: amd64
+Version: 5.1-6ubuntu1.1
+
+`
+
+func TestDownloadSizesFromDpkgRoot(t *testing.T) {
+	root := writeRoot(t, map[string]string{
+		dpkgStatusFile: rootStatus,
+		aptListsDir + "/archive.ubuntu.com_ubuntu_dists_jammy_main_binary-amd64_Packages": `Package: bash
+Architecture: amd64
+Version: 5.1-6ubuntu1
+Size: 768660
+
+Package: bash
+Architecture: amd64
+Version: 5.1-6ubuntu1.1
+Size: 769300
+`,
+	})
+
+	runner := &fakeRunner{}
+	g := NewGenerator(false, false)
+	g.Runner = runner
+	g.DpkgRoot = root
+	g.DownloadSizes = true
+
+	doc, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var annotations []string
+	for _, annotation := range doc.Packages[1].Annotations {
+		annotations = append(annotations, annotation.Comment)
+	}
+	if want := []string{"download-size: 769300 bytes"}; !reflect.DeepEqual(annotations, want) {
+		t.Errorf("annotations %q, want %q", annotations, want)
+	}
+	for _, run := range runner.runs {
+		if strings.HasPrefix(run, "apt-cache") {
+			t.Errorf("asked the host: %s", run)
+		}
+	}
+}
+
+func TestDownloadSizesWithoutRootLists(t *testing.T) {
+	runner := &fakeRunner{}
+	g := NewGenerator(false, false)
+	g.Runner = runner
+	g.DpkgRoot = writeRoot(t, map[string]string{dpkgStatusFile: rootStatus})
+	g.DownloadSizes = true
+
+	doc, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if annotations := doc.Packages[0].Annotations; len(annotations) != 0 {
+		t.Errorf("root annotated without sizes: %+v", annotations)
+	}
+	if len(runner.runs) != 0 {
+		t.Errorf("ran %q", runner.runs)
+	}
+}
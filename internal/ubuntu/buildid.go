@@ -0,0 +1,145 @@
+package ubuntu
+
+import (
+	"bufio"
+	"debug/elf"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// debugBuildIDDir is where -dbgsym packages install their debug files,
+// as .build-id/<first two hex digits>/<remaining digits>.debug
+const debugBuildIDDir = "/usr/lib/debug/.build-id/"
+
+// packageFiles lists the files dpkg recorded for a package
+func (g *Generator) packageFiles(pkg DpkgPackage) ([]string, error) {
+	var output []byte
+	var err error
+	if g.DpkgRoot != "" {
+		output, err = g.readPackageList(pkg)
+	} else {
+		output, err = g.Runner.Output("dpkg", "-L", g.dpkgName(pkg))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		if path := scanner.Text(); path != "" && !strings.HasSuffix(path, "/") {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// debugBuildIDs maps every build-id provided by an installed debug
+// symbols package to that package's index in packages. Names alone would
+// mix up the architectures of a multi-arch -dbgsym package.
+func (g *Generator) debugBuildIDs(packages []DpkgPackage) map[string]int {
+	ids := make(map[string]int)
+
+	for i, pkg := range packages {
+		if !strings.HasSuffix(pkg.Name, "-dbgsym") && !strings.HasSuffix(pkg.Name, "-dbg") {
+			continue
+		}
+
+		files, err := g.packageFiles(pkg)
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			rest, ok := strings.CutPrefix(file, debugBuildIDDir)
+			if !ok || !strings.HasSuffix(rest, ".debug") {
+				continue
+			}
+			id := strings.ReplaceAll(strings.TrimSuffix(rest, ".debug"), "/", "")
+			ids[id] = i
+		}
+	}
+
+	return ids
+}
+
+// elfBuildID returns the GNU build-id of an ELF file, or "" if the file
+// isn't ELF or carries no build-id note
+func elfBuildID(path string) string {
+	file, err := elf.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	section := file.Section(".note.gnu.build-id")
+	if section == nil {
+		return ""
+	}
+
+	data, err := section.Data()
+	if err != nil || len(data) < 16 {
+		return ""
+	}
+
+	// Note layout: namesz, descsz, type, name (padded to 4 bytes), desc.
+	// The sizes come from the file, so they are widened before the
+	// arithmetic to keep a corrupt note from wrapping around.
+	nameSize := uint64(file.ByteOrder.Uint32(data[0:4]))
+	descSize := uint64(file.ByteOrder.Uint32(data[4:8]))
+	descStart := 12 + (nameSize+3)&^3
+	if descStart > uint64(len(data)) || descSize > uint64(len(data))-descStart {
+		return ""
+	}
+
+	return hex.EncodeToString(data[descStart : descStart+descSize])
+}
+
+// debugLinks relates packages whose ELF binaries have their debug symbols
+// installed to the corresponding debug symbols package. ids holds the
+// SPDXID of each package, in the same order.
+func (g *Generator) debugLinks(packages []DpkgPackage, ids []string) []spdx.Relationship {
+	buildIDs := g.debugBuildIDs(packages)
+	if len(buildIDs) == 0 {
+		return nil
+	}
+
+	var relationships []spdx.Relationship
+	for i, pkg := range packages {
+		files, err := g.packageFiles(pkg)
+		if err != nil {
+			continue
+		}
+
+		linked := make(map[int]bool)
+		for _, file := range files {
+			// Debug files themselves are ELF too; don't link them
+			if strings.HasPrefix(file, "/usr/lib/debug/") {
+				continue
+			}
+
+			g.files.acquire()
+			buildID := elfBuildID(g.rootPath(file))
+			g.files.release()
+
+			debugPkg, ok := buildIDs[buildID]
+			if !ok || linked[debugPkg] {
+				continue
+			}
+			linked[debugPkg] = true
+
+			relationships = append(relationships, spdx.Relationship{
+				SPDXElementID:      ids[debugPkg],
+				RelatedSPDXElement: ids[i],
+				RelationshipType:   "OTHER",
+				Comment:            fmt.Sprintf("debug symbols for %s (build-id match)", filepath.Base(file)),
+			})
+		}
+	}
+
+	return relationships
+}
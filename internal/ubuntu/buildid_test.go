@@ -0,0 +1,210 @@
+package ubuntu
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var libc6Multiarch = []DpkgPackage{
+	{Name: "libc6", Version: "2.35-0ubuntu3.8", Architecture: "amd64"},
+	{Name: "libc6", Version: "2.35-0ubuntu3.8", Architecture: "i386"},
+}
+
+func TestPackageFilesMultiarch(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"dpkg -L libc6:amd64": "/lib/x86_64-linux-gnu/libc.so.6\n",
+		"dpkg -L libc6:i386":  "/lib/i386-linux-gnu/libc.so.6\n",
+	}}
+	g := NewGenerator(true, false)
+	g.Runner = runner
+	g.multiarch = multiarchNames(libc6Multiarch)
+
+	for _, tc := range []struct {
+		pkg  DpkgPackage
+		want []string
+	}{
+		{libc6Multiarch[0], []string{"/lib/x86_64-linux-gnu/libc.so.6"}},
+		{libc6Multiarch[1], []string{"/lib/i386-linux-gnu/libc.so.6"}},
+	} {
+		files, err := g.packageFiles(tc.pkg)
+		if err != nil {
+			t.Fatalf("%s:%s: %v", tc.pkg.Name, tc.pkg.Architecture, err)
+		}
+		if !reflect.DeepEqual(files, tc.want) {
+			t.Errorf("%s:%s: got %q, want %q", tc.pkg.Name, tc.pkg.Architecture, files, tc.want)
+		}
+	}
+}
+
+func TestPackageFilesMultiarchDpkgRoot(t *testing.T) {
+	root := t.TempDir()
+	infoDir := filepath.Join(root, dpkgInfoDir)
+	if err := os.MkdirAll(infoDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lists := map[string]string{
+		"libc6:amd64.list": "/lib/x86_64-linux-gnu/libc.so.6\n",
+		"libc6:i386.list":  "/lib/i386-linux-gnu/libc.so.6\n",
+		"bash.list":        "/bin/bash\n",
+	}
+	for name, content := range lists {
+		if err := os.WriteFile(filepath.Join(infoDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := NewGenerator(true, false)
+	g.DpkgRoot = root
+	g.multiarch = multiarchNames(libc6Multiarch)
+
+	for _, tc := range []struct {
+		pkg  DpkgPackage
+		want string
+	}{
+		{libc6Multiarch[0], "/lib/x86_64-linux-gnu/libc.so.6"},
+		{libc6Multiarch[1], "/lib/i386-linux-gnu/libc.so.6"},
+		{DpkgPackage{Name: "bash", Architecture: "amd64"}, "/bin/bash"},
+	} {
+		files, err := g.packageFiles(tc.pkg)
+		if err != nil {
+			t.Fatalf("%s:%s: %v", tc.pkg.Name, tc.pkg.Architecture, err)
+		}
+		if len(files) != 1 || files[0] != tc.want {
+			t.Errorf("%s:%s: got %q, want %q", tc.pkg.Name, tc.pkg.Architecture, files, tc.want)
+		}
+	}
+
+	// Without an architecture the name alone is ambiguous
+	if files, err := g.packageFiles(DpkgPackage{Name: "libc6"}); err == nil {
+		t.Errorf("libc6 without architecture: got %q, want an error", files)
+	}
+}
+
+// writeELF writes a minimal little-endian ELF64 file whose only section
+// besides the section names is a .note.gnu.build-id holding note
+func writeELF(t *testing.T, note []byte) string {
+	t.Helper()
+	names := []byte("\x00.shstrtab\x00.note.gnu.build-id\x00")
+	namesOffset := uint64(binary.Size(elf.Header64{}))
+	noteOffset := namesOffset + uint64(len(names))
+	sectionsOffset := noteOffset + uint64(len(note))
+
+	var buf bytes.Buffer
+	header := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     sectionsOffset,
+		Ehsize:    uint16(binary.Size(elf.Header64{})),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     3,
+		Shstrndx:  1,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_STRTAB), Off: namesOffset, Size: uint64(len(names)), Addralign: 1},
+		{Name: 11, Type: uint32(elf.SHT_NOTE), Off: noteOffset, Size: uint64(len(note)), Addralign: 4},
+	}
+	for _, part := range []interface{}{header, names, note, sections} {
+		if err := binary.Write(&buf, binary.LittleEndian, part); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "binary")
+	if err := os.WriteFile(path, buf.Bytes(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// ntGNUBuildID is the note type of a GNU build-id
+const ntGNUBuildID = 3
+
+// buildIDNote lays out a GNU build-id note with the given header sizes
+// followed by body
+func buildIDNote(nameSize, descSize uint32, body []byte) []byte {
+	note := binary.LittleEndian.AppendUint32(nil, nameSize)
+	note = binary.LittleEndian.AppendUint32(note, descSize)
+	note = binary.LittleEndian.AppendUint32(note, ntGNUBuildID)
+	return append(note, body...)
+}
+
+func TestELFBuildID(t *testing.T) {
+	id := []byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x23, 0x45, 0x67}
+	for _, tc := range []struct {
+		name string
+		note []byte
+		want string
+	}{
+		{"valid", buildIDNote(4, uint32(len(id)), append([]byte("GNU\x00"), id...)), "deadbeef01234567"},
+		{"truncated desc", buildIDNote(4, 64, append([]byte("GNU\x00"), id...)), ""},
+		{"name past the end", buildIDNote(64, 4, append([]byte("GNU\x00"), id...)), ""},
+		// In 32 bits the padded name size wraps to a small offset and
+		// the descriptor end wraps below its start
+		{"wrapping sizes", buildIDNote(0xfffffffc, 0xfffffffc, append([]byte("GNU\x00"), id...)), ""},
+		{"wrapping name size", buildIDNote(0xfffffffd, 4, append([]byte("GNU\x00"), id...)), ""},
+		{"too short", []byte("GNU\x00"), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := elfBuildID(writeELF(t, tc.note)); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	notELF := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(notELF, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := elfBuildID(notELF); got != "" {
+		t.Errorf("non-ELF file: got %q", got)
+	}
+}
+
+func TestDebugLinksMultiarch(t *testing.T) {
+	note := func(id []byte) []byte {
+		return buildIDNote(4, uint32(len(id)), append([]byte("GNU\x00"), id...))
+	}
+	amd64Binary := writeELF(t, note([]byte{0xaa, 0x01, 0x02, 0x03}))
+	i386Binary := writeELF(t, note([]byte{0xbb, 0x01, 0x02, 0x03}))
+
+	packages := []DpkgPackage{
+		{Name: "libfoo", Version: "1", Architecture: "amd64"},
+		{Name: "libfoo", Version: "1", Architecture: "i386"},
+		{Name: "libfoo-dbgsym", Version: "1", Architecture: "amd64"},
+		{Name: "libfoo-dbgsym", Version: "1", Architecture: "i386"},
+	}
+	g := NewGenerator(true, false)
+	g.Runner = &fakeRunner{outputs: map[string]string{
+		"dpkg -L libfoo:amd64":        amd64Binary + "\n",
+		"dpkg -L libfoo:i386":         i386Binary + "\n",
+		"dpkg -L libfoo-dbgsym:amd64": debugBuildIDDir + "aa/010203.debug\n",
+		"dpkg -L libfoo-dbgsym:i386":  debugBuildIDDir + "bb/010203.debug\n",
+	}}
+	g.multiarch = multiarchNames(packages)
+	g.files = newFileLimiter(0)
+
+	links := g.debugLinks(packages, []string{"SPDXRef-amd64", "SPDXRef-i386", "SPDXRef-dbgsym-amd64", "SPDXRef-dbgsym-i386"})
+	var got [][2]string
+	for _, link := range links {
+		got = append(got, [2]string{link.SPDXElementID, link.RelatedSPDXElement})
+	}
+	want := [][2]string{
+		{"SPDXRef-dbgsym-amd64", "SPDXRef-amd64"},
+		{"SPDXRef-dbgsym-i386", "SPDXRef-i386"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package ubuntu
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// readStanzas parses deb822 control data (dpkg status, apt Packages and
+// Release files, apt-cache output) and calls fn for every stanza.
+// Continuation lines are appended to their field separated by newlines.
+func readStanzas(r io.Reader, fn func(fields map[string]string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	fields := make(map[string]string)
+	lastKey := ""
+
+	flush := func() {
+		if len(fields) > 0 {
+			fn(fields)
+		}
+		fields = make(map[string]string)
+		lastKey = ""
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if lastKey != "" {
+				fields[lastKey] += "\n" + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		lastKey = key
+		fields[key] = strings.TrimSpace(value)
+	}
+	flush()
+
+	return scanner.Err()
+}
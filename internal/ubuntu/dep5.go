@@ -0,0 +1,102 @@
+package ubuntu
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dep5Copyright holds what the generator uses from a machine-readable
+// (DEP-5) debian/copyright file
+type dep5Copyright struct {
+	// HeaderLicense is the optional License field of the header stanza
+	HeaderLicense string
+	// MainLicense is the license of the "Files: *" stanza
+	MainLicense string
+	// Licenses lists the distinct short names of all Files stanzas in
+	// order of appearance
+	Licenses []string
+	// Copyrights lists the distinct copyright lines of all Files stanzas
+	Copyrights []string
+
+	UpstreamContact string
+	Source          string
+}
+
+var (
+	dep5FormatPattern = regexp.MustCompile(`(?i)copyright-format|dep5`)
+	urlPattern        = regexp.MustCompile(`(?:https?|git|ftp)://[^\s<>]+`)
+)
+
+// parseDEP5 parses a machine-readable copyright file, returning nil if the
+// content is not in that format
+func parseDEP5(content string) *dep5Copyright {
+	var stanzas []map[string]string
+	_ = readStanzas(strings.NewReader(content), func(fields map[string]string) {
+		stanzas = append(stanzas, fields)
+	})
+
+	if len(stanzas) == 0 || !dep5FormatPattern.MatchString(stanzas[0]["Format"]) {
+		return nil
+	}
+
+	header := stanzas[0]
+	c := &dep5Copyright{
+		HeaderLicense:   firstLine(header["License"]),
+		UpstreamContact: firstLine(header["Upstream-Contact"]),
+		Source:          urlPattern.FindString(header["Source"]),
+	}
+
+	seenLicenses := make(map[string]bool)
+	seenCopyrights := make(map[string]bool)
+	for _, stanza := range stanzas[1:] {
+		files, ok := stanza["Files"]
+		if !ok {
+			// Standalone License stanzas only carry license texts
+			continue
+		}
+
+		// An empty Files field is malformed but should not stop the parse
+		patterns := strings.Fields(files)
+		license := firstLine(stanza["License"])
+		if license != "" {
+			if len(patterns) > 0 && patterns[0] == "*" && c.MainLicense == "" {
+				c.MainLicense = license
+			}
+			if key := strings.ToLower(license); !seenLicenses[key] {
+				seenLicenses[key] = true
+				c.Licenses = append(c.Licenses, license)
+			}
+		}
+
+		for _, line := range strings.Split(stanza["Copyright"], "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || line == "." || seenCopyrights[line] {
+				continue
+			}
+			seenCopyrights[line] = true
+			c.Copyrights = append(c.Copyrights, line)
+		}
+	}
+
+	return c
+}
+
+// declaredLicense is the license the package declares as a whole: the
+// header License if given, otherwise that of "Files: *"
+func (c *dep5Copyright) declaredLicense() string {
+	if c.HeaderLicense != "" {
+		return c.HeaderLicense
+	}
+	if c.MainLicense != "" {
+		return c.MainLicense
+	}
+	if len(c.Licenses) > 0 {
+		return c.Licenses[0]
+	}
+	return ""
+}
+
+func firstLine(value string) string {
+	line, _, _ := strings.Cut(value, "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,70 @@
+package ubuntu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDEP5(t *testing.T) {
+	content := `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: zlib
+Upstream-Contact: zlib@gzip.org
+Source: https://zlib.net/
+
+Files: *
+Copyright: 1995-2022 Jean-loup Gailly and Mark Adler
+License: Zlib
+
+Files: debian/*
+Copyright: 2000-2022 Mark Brown
+License: Zlib
+
+Files: contrib/dotzlib/*
+Copyright: 2004 Henrik Ravn
+License: BSL-1.0
+
+License: Zlib
+ This software is provided 'as-is', without any express or implied
+ warranty.
+`
+	c := parseDEP5(content)
+	if c == nil {
+		t.Fatal("not recognized as DEP-5")
+	}
+	if c.MainLicense != "Zlib" || c.declaredLicense() != "Zlib" {
+		t.Errorf("main license %q, declared %q", c.MainLicense, c.declaredLicense())
+	}
+	if want := []string{"Zlib", "BSL-1.0"}; !reflect.DeepEqual(c.Licenses, want) {
+		t.Errorf("licenses %q, want %q", c.Licenses, want)
+	}
+	if len(c.Copyrights) != 3 {
+		t.Errorf("copyrights %q", c.Copyrights)
+	}
+	if c.UpstreamContact != "zlib@gzip.org" || c.Source != "https://zlib.net/" {
+		t.Errorf("contact %q, source %q", c.UpstreamContact, c.Source)
+	}
+}
+
+func TestParseDEP5EmptyFiles(t *testing.T) {
+	for _, files := range []string{"", "   "} {
+		content := "Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/\n\n" +
+			"Files:" + files + "\nCopyright: nobody\nLicense: MIT\n\n" +
+			"Files: *\nCopyright: somebody\nLicense: GPL-2+\n"
+		c := parseDEP5(content)
+		if c == nil {
+			t.Fatalf("Files: %q: not recognized as DEP-5", files)
+		}
+		if c.MainLicense != "GPL-2+" {
+			t.Errorf("Files: %q: main license %q, want GPL-2+", files, c.MainLicense)
+		}
+		if want := []string{"MIT", "GPL-2+"}; !reflect.DeepEqual(c.Licenses, want) {
+			t.Errorf("Files: %q: licenses %q, want %q", files, c.Licenses, want)
+		}
+	}
+}
+
+func TestParseDEP5NotMachineReadable(t *testing.T) {
+	if c := parseDEP5("This package was debianized by someone.\n\nLicense: GPL\n"); c != nil {
+		t.Errorf("free-form copyright parsed as DEP-5: %+v", c)
+	}
+}
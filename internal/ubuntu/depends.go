@@ -0,0 +1,185 @@
+package ubuntu
+
+import (
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// relation is one alternative of a dpkg relationship field clause, such as
+// "libc6 (>= 2.34)". Arch holds an architecture qualifier like the "any"
+// of "python3:any".
+type relation struct {
+	Name     string
+	Arch     string
+	Operator string
+	Version  string
+}
+
+// splitRelationField splits a Depends/Pre-Depends/Provides value into its
+// comma-separated clauses
+func splitRelationField(field string) []string {
+	var clauses []string
+	for _, clause := range strings.Split(field, ",") {
+		if clause = strings.TrimSpace(clause); clause != "" {
+			clauses = append(clauses, clause)
+		}
+	}
+	return clauses
+}
+
+// parseClause parses "foo:any (>= 1.0) | bar" into its alternatives
+func parseClause(clause string) []relation {
+	var alternatives []relation
+	for _, alt := range strings.Split(clause, "|") {
+		alt = strings.TrimSpace(alt)
+
+		var rel relation
+		if name, constraint, ok := strings.Cut(alt, "("); ok {
+			alt = name
+			constraint = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(constraint), ")"))
+			version := strings.TrimLeft(constraint, "<>=")
+			rel.Operator = constraint[:len(constraint)-len(version)]
+			rel.Version = strings.TrimSpace(version)
+		}
+
+		rel.Name, rel.Arch, _ = strings.Cut(strings.TrimSpace(alt), ":")
+		if rel.Name != "" {
+			alternatives = append(alternatives, rel)
+		}
+	}
+	return alternatives
+}
+
+// satisfiedBy reports whether a package or provide at version meets the
+// relation's version constraint
+func (r relation) satisfiedBy(version string) bool {
+	if r.Operator == "" {
+		return true
+	}
+	if version == "" {
+		return false
+	}
+
+	c := compareVersions(version, r.Version)
+	switch r.Operator {
+	case "<<":
+		return c < 0
+	case "<=", "<":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=", ">":
+		return c >= 0
+	case ">>":
+		return c > 0
+	}
+	return false
+}
+
+// archMatches reports whether a package of architecture arch can satisfy
+// a dependency of a package of architecture from. Architecture: all
+// packages go with every architecture.
+func archMatches(arch, from string) bool {
+	return arch == from || arch == "all" || from == "all" || arch == "" || from == ""
+}
+
+// provider is a package offering a virtual package through Provides,
+// optionally at a version ("Provides: foo (= 1.2)")
+type provider struct {
+	index   int
+	version string
+}
+
+// dependencyRelationships turns each package's Depends and Pre-Depends into
+// DEPENDS_ON relationships between the given package SPDXIDs. For every
+// clause the first alternative satisfied by an installed package, or by a
+// provide whose version meets the constraint, is used. With a package
+// installed for several architectures, the one of the depending package's
+// architecture is preferred unless the dependency is qualified with :any.
+// Clauses nothing installed satisfies are left out so every relationship
+// points at a package in the document.
+func dependencyRelationships(packages []DpkgPackage, ids []string) ([]spdx.Relationship, int) {
+	byName := make(map[string][]int)
+	provides := make(map[string][]provider)
+	for i, pkg := range packages {
+		byName[pkg.Name] = append(byName[pkg.Name], i)
+		for _, clause := range pkg.Provides {
+			for _, rel := range parseClause(clause) {
+				provides[rel.Name] = append(provides[rel.Name], provider{index: i, version: rel.Version})
+			}
+		}
+	}
+
+	resolve := func(rel relation, from string) int {
+		switch rel.Arch {
+		case "any":
+			from = ""
+		case "", "native":
+		default:
+			from = rel.Arch
+		}
+
+		// Another architecture only satisfies the dependency when none of
+		// the wanted one does, as with Multi-Arch: foreign packages
+		other := -1
+		for _, i := range byName[rel.Name] {
+			if !rel.satisfiedBy(packages[i].Version) {
+				continue
+			}
+			if archMatches(packages[i].Architecture, from) {
+				return i
+			}
+			if other < 0 {
+				other = i
+			}
+		}
+		if other >= 0 {
+			return other
+		}
+
+		// Unversioned provides never satisfy a versioned dependency
+		for _, p := range provides[rel.Name] {
+			if !rel.satisfiedBy(p.version) {
+				continue
+			}
+			if archMatches(packages[p.index].Architecture, from) {
+				return p.index
+			}
+			if other < 0 {
+				other = p.index
+			}
+		}
+		return other
+	}
+
+	var relationships []spdx.Relationship
+	unresolved := 0
+	for i, pkg := range packages {
+		seen := make(map[int]bool)
+		for _, clause := range append(append([]string{}, pkg.PreDepends...), pkg.Depends...) {
+			target := -1
+			for _, rel := range parseClause(clause) {
+				if target = resolve(rel, pkg.Architecture); target >= 0 {
+					break
+				}
+			}
+			if target < 0 {
+				unresolved++
+				continue
+			}
+			if target == i || seen[target] {
+				continue
+			}
+			seen[target] = true
+
+			relationships = append(relationships, spdx.Relationship{
+				SPDXElementID:      ids[i],
+				RelatedSPDXElement: ids[target],
+				RelationshipType:   "DEPENDS_ON",
+			})
+		}
+	}
+
+	return relationships, unresolved
+}
@@ -0,0 +1,117 @@
+package ubuntu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"2.35-0ubuntu3.6", "2.35-0ubuntu3.6", 0},
+		{"2.35-0ubuntu3.6", "2.35-0ubuntu3.10", -1},
+		{"2.35", "2.34", 1},
+		{"1:1.0", "2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+dfsg", -1},
+		{"1.0-1", "1.0", 1},
+		{"4:11.2.0-1ubuntu1", "4:11.2.0-1ubuntu1", 0},
+	} {
+		got := compareVersions(tc.a, tc.b)
+		if got < 0 {
+			got = -1
+		} else if got > 0 {
+			got = 1
+		}
+		if got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestParseProvides(t *testing.T) {
+	var got []relation
+	for _, clause := range splitRelationField("libc-dev (= 2.35-0ubuntu3.6), libc6-dev-amd64:any, mail-transport-agent") {
+		got = append(got, parseClause(clause)...)
+	}
+	want := []relation{
+		{Name: "libc-dev", Operator: "=", Version: "2.35-0ubuntu3.6"},
+		{Name: "libc6-dev-amd64", Arch: "any"},
+		{Name: "mail-transport-agent"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	alternatives := parseClause("libc6-dev (>= 2.36) | libc-dev (>=2.34)")
+	want = []relation{
+		{Name: "libc6-dev", Operator: ">=", Version: "2.36"},
+		{Name: "libc-dev", Operator: ">=", Version: "2.34"},
+	}
+	if !reflect.DeepEqual(alternatives, want) {
+		t.Errorf("got %+v, want %+v", alternatives, want)
+	}
+}
+
+func TestRelationSatisfiedByProvide(t *testing.T) {
+	for _, tc := range []struct {
+		dependency string
+		provided   string
+		want       bool
+	}{
+		{"libc-dev (>= 2.34)", "2.35-0ubuntu3.6", true},
+		{"libc-dev (<< 2.30)", "2.35-0ubuntu3.6", false},
+		{"libc-dev (= 2.35-0ubuntu3.6)", "2.35-0ubuntu3.6", true},
+		{"libc-dev (>> 2.35-0ubuntu3.6)", "2.35-0ubuntu3.6", false},
+		{"libc-dev (<= 2.35)", "2.35-0ubuntu3.6", false},
+		{"libc-dev", "2.35-0ubuntu3.6", true},
+		// An unversioned provide only satisfies unversioned dependencies
+		{"libc-dev", "", true},
+		{"libc-dev (>= 2.34)", "", false},
+	} {
+		rel := parseClause(tc.dependency)[0]
+		if got := rel.satisfiedBy(tc.provided); got != tc.want {
+			t.Errorf("%s satisfied by a provide at %q: got %v, want %v", tc.dependency, tc.provided, got, tc.want)
+		}
+	}
+}
+
+func TestDependencyRelationshipsProvides(t *testing.T) {
+	packages := []DpkgPackage{
+		{Name: "libc6-dev", Version: "2.35-0ubuntu3.6", Provides: []string{"libc-dev (= 2.35-0ubuntu3.6)"}},
+		{Name: "musl-dev", Version: "1.2.2-4", Provides: []string{"libc-dev"}},
+		{Name: "postfix", Version: "3.6.4-1ubuntu1", Provides: []string{"mail-transport-agent"}},
+		{Name: "gcc", Version: "4:11.2.0-1ubuntu1", Depends: []string{"libc6-dev (>= 2.36) | libc-dev (>= 2.34)"}},
+		{Name: "old-gcc", Version: "1", Depends: []string{"libc-dev (<< 2.30)"}},
+		{Name: "any-libc", Version: "1", Depends: []string{"libc-dev"}},
+		{Name: "mailer", Version: "1", Depends: []string{"mail-transport-agent (>= 3)", "mail-transport-agent"}},
+	}
+	ids := []string{"SPDXRef-libc6-dev", "SPDXRef-musl-dev", "SPDXRef-postfix", "SPDXRef-gcc", "SPDXRef-old-gcc", "SPDXRef-any-libc", "SPDXRef-mailer"}
+
+	relationships, unresolved := dependencyRelationships(packages, ids)
+
+	var got [][2]string
+	for _, rel := range relationships {
+		if rel.RelationshipType != "DEPENDS_ON" {
+			t.Errorf("unexpected relationship type %s", rel.RelationshipType)
+		}
+		got = append(got, [2]string{rel.SPDXElementID, rel.RelatedSPDXElement})
+	}
+	want := [][2]string{
+		// libc6-dev itself is too old, its versioned provide satisfies the alternative
+		{"SPDXRef-gcc", "SPDXRef-libc6-dev"},
+		// An unversioned dependency takes the first provider
+		{"SPDXRef-any-libc", "SPDXRef-libc6-dev"},
+		// The unversioned provide only satisfies the unversioned clause
+		{"SPDXRef-mailer", "SPDXRef-postfix"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("relationships %v, want %v", got, want)
+	}
+	// old-gcc's versioned provide is too new, mailer's is unversioned
+	if unresolved != 2 {
+		t.Errorf("unresolved %d, want 2", unresolved)
+	}
+}
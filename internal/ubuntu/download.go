@@ -0,0 +1,151 @@
+package ubuntu
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// aptSourcesDir holds the apt sources besides /etc/apt/sources.list
+const aptSourcesDir = "/etc/apt/sources.list.d"
+
+// loadDownloadURLs maps every package version listed in the local apt
+// lists to the URL of its .deb: the repository URI joined with the
+// Filename field. Each list is read once, however many packages are
+// installed. When several repositories offer a version the first list in
+// name order wins. The map is keyed by aptKey.
+func (g *Generator) loadDownloadURLs(listsDir string) (map[string]string, error) {
+	entries, err := os.ReadDir(listsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := g.aptSourceURIs()
+
+	urls := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		distsIdx := strings.Index(name, "_dists_")
+		if distsIdx == -1 || !strings.Contains(name, "_Packages") {
+			continue
+		}
+
+		base := repositoryURI(name[:distsIdx], repositories)
+		if base == "" {
+			continue
+		}
+
+		data, err := g.readAptList(filepath.Join(listsDir, name))
+		if err != nil {
+			continue
+		}
+
+		readStanzas(bytes.NewReader(data), func(fields map[string]string) {
+			if fields["Filename"] == "" {
+				return
+			}
+			key := aptKey(fields["Package"], fields["Version"], fields["Architecture"])
+			if _, ok := urls[key]; !ok {
+				urls[key] = base + "/" + strings.TrimPrefix(fields["Filename"], "/")
+			}
+		})
+	}
+
+	return urls, nil
+}
+
+// aptListPrefix is apt's file name for a repository URI (URItoFileName):
+// the URI without scheme and credentials, with _ and other special
+// characters percent-encoded and / replaced by _
+func aptListPrefix(uri string) string {
+	parsed, err := url.Parse(strings.TrimSuffix(uri, "/"))
+	if err != nil {
+		return ""
+	}
+	path := strings.ReplaceAll(parsed.Host+parsed.Path, "_", "%5f")
+	return strings.ReplaceAll(path, "/", "_")
+}
+
+// repositoryURI recovers the repository URI of a list file prefix. The
+// configured sources give the scheme; a repository no longer configured is
+// assumed to be served over http, like the archive mirrors.
+func repositoryURI(prefix string, repositories map[string]string) string {
+	if uri, ok := repositories[prefix]; ok {
+		return uri
+	}
+
+	path, err := url.PathUnescape(strings.ReplaceAll(prefix, "_", "/"))
+	if err != nil {
+		return ""
+	}
+	return "http://" + path
+}
+
+// aptSourceURIs maps the list file prefix of every configured repository
+// to its URI, from one-line (.list) and deb822 (.sources) source files
+func (g *Generator) aptSourceURIs() map[string]string {
+	var uris []string
+
+	files := []string{g.rootPath("/etc/apt/sources.list")}
+	entries, _ := os.ReadDir(g.rootPath(aptSourcesDir))
+	for _, entry := range entries {
+		files = append(files, filepath.Join(g.rootPath(aptSourcesDir), entry.Name()))
+	}
+
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(path, ".sources"):
+			readStanzas(bytes.NewReader(data), func(fields map[string]string) {
+				uris = append(uris, strings.Fields(fields["URIs"])...)
+			})
+		case strings.HasSuffix(path, ".list"):
+			uris = append(uris, oneLineSourceURIs(data)...)
+		}
+	}
+
+	repositories := make(map[string]string)
+	for _, uri := range uris {
+		parsed, err := url.Parse(strings.TrimSuffix(uri, "/"))
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		// Credentials of private repositories must not end up in the SBOM
+		parsed.User = nil
+		repositories[aptListPrefix(parsed.String())] = parsed.String()
+	}
+	return repositories
+}
+
+// oneLineSourceURIs returns the URIs of "deb [options] uri suite ..."
+// lines
+func oneLineSourceURIs(data []byte) []string {
+	var uris []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "deb" {
+			continue
+		}
+		fields = fields[1:]
+		if strings.HasPrefix(fields[0], "[") {
+			for len(fields) > 0 && !strings.HasSuffix(fields[0], "]") {
+				fields = fields[1:]
+			}
+			if len(fields) < 2 {
+				continue
+			}
+			fields = fields[1:]
+		}
+		uris = append(uris, fields[0])
+	}
+	return uris
+}
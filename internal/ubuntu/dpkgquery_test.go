@@ -0,0 +1,154 @@
+package ubuntu
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dpkgQueryRecord formats fields as dpkg-query prints them for
+// dpkgQueryFormat, leaving fields not given empty
+func dpkgQueryRecord(fields map[string]string) string {
+	values := make([]string, len(dpkgQueryFields))
+	for i, field := range dpkgQueryFields {
+		values[i] = fields[field]
+	}
+	return strings.Join(values, dpkgFieldSeparator) + dpkgRecordSeparator
+}
+
+// queryGenerator returns a generator whose dpkg-query prints records
+func queryGenerator(records ...string) *Generator {
+	g := NewGenerator(false, false)
+	g.Runner = &fakeRunner{outputs: map[string]string{
+		"dpkg-query -W -f=" + dpkgQueryFormat(): strings.Join(records, ""),
+	}}
+	return g
+}
+
+func TestGetInstalledPackagesDescriptionTab(t *testing.T) {
+	g := queryGenerator(
+		dpkgQueryRecord(map[string]string{
+			"Package":      "tabby",
+			"Version":      "1.0-1",
+			"Architecture": "amd64",
+			"Status":       "install ok installed",
+			"Depends":      "libc6 (>= 2.34)",
+			"Source":       "tabby-src",
+			"Section":      "utils",
+			"Description":  "column\taligned synopsis\n Extended\tdescription\n .\n with\tmore tabs",
+			"Priority":     "optional",
+		}),
+		dpkgQueryRecord(map[string]string{
+			"Package":      "after",
+			"Version":      "2.0",
+			"Architecture": "all",
+			"Status":       "install ok installed",
+			"Description":  "the record after it",
+		}),
+	)
+
+	packages, err := g.getInstalledPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Skipped()) != 0 {
+		t.Errorf("skipped %v", g.Skipped())
+	}
+
+	want := []DpkgPackage{
+		{
+			Name:          "tabby",
+			Version:       "1.0-1",
+			Architecture:  "amd64",
+			Status:        "install ok installed",
+			Depends:       []string{"libc6 (>= 2.34)"},
+			Source:        "tabby-src",
+			SourceVersion: "1.0-1",
+			Section:       "utils",
+			Description:   "column\taligned synopsis",
+			Priority:      "optional",
+		},
+		{
+			Name:          "after",
+			Version:       "2.0",
+			Architecture:  "all",
+			Status:        "install ok installed",
+			Source:        "after",
+			SourceVersion: "2.0",
+			Description:   "the record after it",
+		},
+	}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("got %+v\nwant %+v", packages, want)
+	}
+}
+
+func TestPackageStatus(t *testing.T) {
+	statuses := map[string]string{
+		"bash":               "install ok installed",
+		"openssl":            "hold ok installed",
+		"popularity-contest": "deinstall ok config-files",
+		"half-done":          "install reinstreq half-configured",
+		"gone":               "purge ok not-installed",
+	}
+	var records []string
+	for _, name := range []string{"bash", "openssl", "popularity-contest", "half-done", "gone"} {
+		records = append(records, dpkgQueryRecord(map[string]string{"Package": name, "Status": statuses[name]}))
+	}
+
+	sources := map[string]func(includeConfigFiles bool) *Generator{
+		"dpkg-query": func(includeConfigFiles bool) *Generator {
+			g := queryGenerator(records...)
+			g.IncludeConfigFiles = includeConfigFiles
+			return g
+		},
+		"status file": func(includeConfigFiles bool) *Generator {
+			g := NewGenerator(false, false)
+			g.DpkgRoot = "testdata/status"
+			g.IncludeConfigFiles = includeConfigFiles
+			return g
+		},
+	}
+
+	for source, newGenerator := range sources {
+		for _, tc := range []struct {
+			includeConfigFiles bool
+			want               []string
+			skipped            []string
+		}{
+			{false, []string{"bash", "openssl"}, []string{"popularity-contest", "half-done", "gone"}},
+			{true, []string{"bash", "openssl", "popularity-contest"}, []string{"half-done", "gone"}},
+		} {
+			g := newGenerator(tc.includeConfigFiles)
+			var packages []DpkgPackage
+			var err error
+			if source == "status file" {
+				packages, err = g.readDpkgStatus()
+			} else {
+				packages, err = g.getInstalledPackages()
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", source, err)
+			}
+
+			var got []string
+			for _, pkg := range packages {
+				got = append(got, pkg.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s, IncludeConfigFiles %v: kept %v, want %v", source, tc.includeConfigFiles, got, tc.want)
+			}
+
+			var skipped []string
+			for _, s := range g.Skipped() {
+				if s.Reason != SkipStatus || s.Detail != statuses[s.Name] {
+					t.Errorf("%s: unexpected skip %+v", source, s)
+				}
+				skipped = append(skipped, s.Name)
+			}
+			if !reflect.DeepEqual(skipped, tc.skipped) {
+				t.Errorf("%s, IncludeConfigFiles %v: skipped %v, want %v", source, tc.includeConfigFiles, skipped, tc.skipped)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ubuntu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+)
+
+// dpkgStatusFile is the dpkg database of installed packages, relative to
+// the filesystem root
+const dpkgStatusFile = "/var/lib/dpkg/status"
+
+// rootPath resolves an absolute path on the system being described, which
+// is the host unless DpkgRoot points at a mounted image
+func (g *Generator) rootPath(path string) string {
+	if g.DpkgRoot != "" {
+		return filepath.Join(g.DpkgRoot, path)
+	}
+	if g.hostRoot != "" {
+		return filepath.Join(g.hostRoot, path)
+	}
+	return path
+}
+
+// readDpkgStatus enumerates installed packages by parsing the dpkg status
+// file under DpkgRoot directly, without running dpkg-query
+func (g *Generator) readDpkgStatus() ([]DpkgPackage, error) {
+	statusPath := g.rootPath(dpkgStatusFile)
+
+	file, err := os.Open(statusPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dpkg status file: %w", err)
+	}
+	defer file.Close()
+
+	var packages []DpkgPackage
+	err = readStanzas(file, func(fields map[string]string) {
+		// The synopsis is the first line, the extended description follows
+		// on continuation lines
+		description, _, _ := strings.Cut(fields["Description"], "\n")
+
+		pkg := DpkgPackage{
+			Name:         fields["Package"],
+			Version:      fields["Version"],
+			Architecture: fields["Architecture"],
+			Status:       fields["Status"],
+			Maintainer:   fields["Maintainer"],
+			Homepage:     fields["Homepage"],
+			Depends:      splitRelationField(fields["Depends"]),
+			PreDepends:   splitRelationField(fields["Pre-Depends"]),
+			Provides:     splitRelationField(fields["Provides"]),
+			Section:      fields["Section"],
+			Description:  description,
+			Essential:    fields["Essential"] == "yes",
+			Priority:     fields["Priority"],
+		}
+		pkg.Source, pkg.SourceVersion = parseSource(fields["Source"], pkg.Name, pkg.Version)
+
+		if pkg.Name == "" {
+			g.skip(SkippedPackage{
+				Reason: SkipParseError,
+				Detail: "stanza without Package field",
+			})
+			return
+		}
+
+		if !g.keepStatus(pkg.Status) {
+			g.skip(SkippedPackage{
+				Name:         pkg.Name,
+				Version:      pkg.Version,
+				Architecture: pkg.Architecture,
+				Reason:       SkipStatus,
+				Detail:       pkg.Status,
+			})
+			return
+		}
+
+		if g.filterOut(pkg) {
+			return
+		}
+
+		packages = append(packages, pkg)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", statusPath, err)
+	}
+
+	logging.Infof("Found %d installed packages in %s", len(packages), statusPath)
+	return packages, nil
+}
+
+// readPackageList reads the file list dpkg keeps for a package under
+// DpkgRoot
+func (g *Generator) readPackageList(pkg DpkgPackage) ([]byte, error) {
+	infoDir := g.rootPath(dpkgInfoDir)
+
+	var err error
+	for _, name := range packageListNames(pkg) {
+		var content []byte
+		content, err = os.ReadFile(filepath.Join(infoDir, name))
+		if err == nil || !os.IsNotExist(err) {
+			return content, err
+		}
+	}
+
+	// Without an architecture, a qualified list can only be used when it
+	// is the sole one for the name
+	if pkg.Architecture == "" {
+		matches, _ := filepath.Glob(filepath.Join(infoDir, pkg.Name+":*.list"))
+		if len(matches) == 1 {
+			return os.ReadFile(matches[0])
+		}
+	}
+	return nil, err
+}
+
+// packageListNames returns the names pkg's file list may have under
+// dpkgInfoDir, most specific first. Multi-Arch: same packages are
+// recorded as <name>:<arch>.list.
+func packageListNames(pkg DpkgPackage) []string {
+	names := []string{pkg.Name + ".list"}
+	if pkg.Architecture != "" && pkg.Architecture != "all" {
+		names = append([]string{pkg.Name + ":" + pkg.Architecture + ".list"}, names...)
+	}
+	return names
+}
+
+// dpkgName is the package name to pass to dpkg tools. A package installed
+// for several architectures is ambiguous by name alone, so it gets its
+// architecture qualifier.
+func (g *Generator) dpkgName(pkg DpkgPackage) string {
+	if g.multiarch[pkg.Name] && pkg.Architecture != "" {
+		return pkg.Name + ":" + pkg.Architecture
+	}
+	return pkg.Name
+}
+
+// dpkgState returns the state word of a dpkg Status ("<want> <flag>
+// <state>"), such as installed, half-configured or config-files
+func dpkgState(status string) string {
+	fields := strings.Fields(status)
+	if len(fields) != 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+// keepStatus reports whether a package in this dpkg Status belongs in the
+// SBOM. The want word is ignored, so held packages are kept, but only the
+// installed state counts: a removed package whose configuration files
+// remain (deinstall ok config-files) is kept only with IncludeConfigFiles.
+func (g *Generator) keepStatus(status string) bool {
+	switch dpkgState(status) {
+	case "installed":
+		return true
+	case "config-files":
+		return g.IncludeConfigFiles
+	default:
+		return false
+	}
+}
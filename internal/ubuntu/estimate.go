@@ -0,0 +1,50 @@
+package ubuntu
+
+// Estimate is what Generate would process, as reported by a dry run
+type Estimate struct {
+	// Packages is the number of packages that would be described
+	Packages int
+	// Skipped is the number of enumerated packages left out by status or
+	// filters
+	Skipped int
+	// Files is the number of files that would be hashed with
+	// IncludeFiles, after HashPaths; zero without IncludeFiles
+	Files int
+}
+
+// Estimate enumerates the packages Generate would describe and, with
+// IncludeFiles, counts the files their dpkg file lists name, without
+// reading copyright files, hashing or building a document. Files that
+// turn out to be unreadable are counted, so the file count is an upper
+// bound.
+func (g *Generator) Estimate() (*Estimate, error) {
+	packages, err := g.listPackages()
+	if err != nil {
+		return nil, err
+	}
+	g.multiarch = multiarchNames(packages)
+
+	estimate := &Estimate{Packages: len(packages), Skipped: len(g.skipped)}
+
+	// Selections captures have no files to hash
+	if !g.IncludeFiles || g.SelectionsFile != "" {
+		return estimate, nil
+	}
+
+	bar := g.progress("Listing files", len(packages))
+	defer bar.Done()
+	for _, pkg := range packages {
+		files, err := g.packageFiles(pkg)
+		bar.Add(1)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if g.shouldHash(file) {
+				estimate.Files++
+			}
+		}
+	}
+
+	return estimate, nil
+}
@@ -0,0 +1,74 @@
+package ubuntu
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// filterOut reports whether the package is excluded by the IncludePackages
+// and ExcludePackages globs or the Architectures list, recording it as
+// skipped if so. Exclusion always wins; a non-empty include list acts as
+// an allowlist.
+func (g *Generator) filterOut(pkg DpkgPackage) bool {
+	detail := ""
+	if pattern := matchGlob(g.ExcludePackages, pkg.Name); pattern != "" {
+		detail = fmt.Sprintf("matches --exclude %s", pattern)
+	} else if len(g.IncludePackages) > 0 && matchGlob(g.IncludePackages, pkg.Name) == "" {
+		detail = "matches no --include pattern"
+	} else if !g.keepArchitecture(pkg.Architecture) {
+		detail = fmt.Sprintf("architecture %s not selected by --arch", pkg.Architecture)
+	}
+
+	if detail == "" {
+		return false
+	}
+
+	g.skip(SkippedPackage{
+		Name:         pkg.Name,
+		Version:      pkg.Version,
+		Architecture: pkg.Architecture,
+		Reason:       SkipFiltered,
+		Detail:       detail,
+	})
+	return true
+}
+
+// matchGlob returns the first pattern matching name, or ""
+func matchGlob(patterns []string, name string) string {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// keepArchitecture reports whether packages of arch are kept. Without an
+// Architectures list everything is; otherwise Architecture: all packages
+// and those of unknown architecture (bare names in a selections capture)
+// are always kept.
+func (g *Generator) keepArchitecture(arch string) bool {
+	if len(g.Architectures) == 0 || arch == "" || arch == "all" {
+		return true
+	}
+	for _, keep := range g.Architectures {
+		if arch == keep {
+			return true
+		}
+	}
+	return false
+}
+
+// multiarchNames returns the names of packages installed for more than one
+// architecture, such as libc6 for both amd64 and i386
+func multiarchNames(packages []DpkgPackage) map[string]bool {
+	archs := make(map[string]string)
+	multiarch := make(map[string]bool)
+	for _, pkg := range packages {
+		if arch, ok := archs[pkg.Name]; ok && arch != pkg.Architecture {
+			multiarch[pkg.Name] = true
+		}
+		archs[pkg.Name] = pkg.Architecture
+	}
+	return multiarch
+}
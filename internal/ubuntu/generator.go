@@ -1,18 +1,24 @@
 package ubuntu
 
 import (
-	"bufio"
-	"crypto/sha256"
-	"encoding/json"
+	"crypto/sha1"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/progress"
 	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/version"
 )
 
 type DpkgPackage struct {
@@ -22,38 +28,299 @@ type DpkgPackage struct {
 	Status       string
 	Maintainer   string
 	Homepage     string
+	Section      string
 	Description  string
 	License      string
 	Copyright    string
+	// ConcludedLicense covers all files of the package when the copyright
+	// file lists them; empty means the same as License
+	ConcludedLicense string
+	// UpstreamContact and UpstreamSource come from the header of a
+	// machine-readable copyright file
+	UpstreamContact string
+	UpstreamSource  string
+	// Source and SourceVersion name the source package the binary was
+	// built from, defaulting to the binary's own name and version
+	Source        string
+	SourceVersion string
+	// Depends, PreDepends and Provides hold the comma-separated clauses of
+	// the dpkg fields, with version constraints and alternatives intact
+	Depends    []string
+	PreDepends []string
+	Provides   []string
+	// Essential is dpkg's Essential: yes, marking packages the system
+	// cannot work without and dpkg refuses to remove
+	Essential bool
+	// Priority is the archive priority: required, important, standard,
+	// optional or extra
+	Priority string
 }
 
 type Generator struct {
 	IncludeFiles bool
+	// ShowProgress draws a progress bar with the rate and time left while
+	// copyright files are read and files hashed, when log messages go to
+	// a terminal. Otherwise progress is logged every progress.Interval
+	// packages.
 	ShowProgress bool
+
+	// SelectionsFile, when set, builds the SBOM from a `dpkg --get-selections`
+	// capture instead of querying the local dpkg database
+	SelectionsFile string
+
+	// USNDatabase is the path to a local Ubuntu Security Notices database
+	// (usn-db database.json) used to attach advisory references
+	USNDatabase string
+
+	// HashPaths restricts file hashing to files matching one of these
+	// globs (filepath.Match syntax). Empty means every file is hashed.
+	HashPaths []string
+
+	// DownloadSizes annotates each package and the root with the .deb
+	// download size known to apt, read from DpkgRoot's apt lists when set
+	DownloadSizes bool
+
+	// RequireReadable makes Generate fail if any copyright or package file
+	// could not be read due to insufficient permissions
+	RequireReadable bool
+
+	// Strict makes Generate fail when dpkg-query exits with an error,
+	// instead of describing the packages it listed before failing
+	Strict bool
+
+	// AptOrigins annotates each package with the origin of the apt
+	// repository it was installed from and whether that is the official
+	// archive
+	AptOrigins bool
+
+	// ThirdPartyOnly keeps only packages that are not from the official
+	// archive (third-party repositories, PPAs, or of unknown origin)
+	ThirdPartyOnly bool
+
+	// Since, when set, keeps only packages installed or upgraded at or
+	// after it, going by the modification time of their dpkg file list.
+	// Packages without a list are kept unless StrictSince is set.
+	Since       time.Time
+	StrictSince bool
+
+	// KernelModules adds the running kernel's loaded modules as packages,
+	// with their inter-module dependencies and firmware requirements
+	KernelModules bool
+
+	// AllArchAs controls the purl arch qualifier of Architecture: all
+	// packages: "all" (default, technically correct) or "host" to use the
+	// native dpkg architecture for scanners that match on it
+	AllArchAs string
+
+	// LicenseIgnoreFile lists raw License: values (or "re:" regexes) that
+	// always normalize to NOASSERTION
+	LicenseIgnoreFile string
+
+	// TrustedMaintainersFile lists the email domains of trusted
+	// maintainers; packages maintained from any other domain are annotated
+	// with UntrustedMaintainer
+	TrustedMaintainersFile string
+
+	// DebugLinks relates packages to their installed debug symbols
+	// packages by matching ELF build-ids (reads every packaged file)
+	DebugLinks bool
+
+	// SWID attaches a deterministic SWID tag ID reference to each package
+	SWID bool
+
+	// MaxOpenFiles bounds the number of files held open at once by license
+	// reading and file hashing combined
+	MaxOpenFiles int
+
+	// Runner executes dpkg, apt and kernel tools; defaults to the host
+	Runner CommandRunner
+
+	// DpkgRoot, when set, is the root of a filesystem (such as a mounted
+	// image) whose dpkg status file, copyright files and package file lists
+	// are read directly instead of querying the host's dpkg
+	DpkgRoot string
+
+	// IncludePackages, when non-empty, limits the SBOM to packages whose
+	// names match one of these globs; ExcludePackages drops matching
+	// packages and takes precedence
+	IncludePackages []string
+	ExcludePackages []string
+
+	// EmitFiles adds an SPDX File element with checksums for every hashed
+	// file when IncludeFiles is set. This can make documents very large.
+	EmitFiles bool
+
+	// ChecksumAlgorithms are the SPDX algorithms (SHA1, SHA256, SHA512)
+	// of the File element checksums. SHA1 is always included, as SPDX
+	// requires it for files and verification codes are built from it.
+	// Empty means SHA1 and SHA256.
+	ChecksumAlgorithms []string
+
+	// Architectures, when non-empty, limits the SBOM to packages of these
+	// dpkg architectures (e.g. amd64); Architecture: all packages are
+	// always kept
+	Architectures []string
+
+	// Jobs is the number of workers reading copyright files and, with
+	// IncludeFiles, hashing package files
+	Jobs int
+
+	// MaxCopyrightLength truncates copyright text to this many characters;
+	// zero keeps it whole
+	MaxCopyrightLength int
+
+	// ResolveDownloadURLs sets each package's download location to the URL
+	// of its .deb in the configured apt repositories, from the local apt
+	// lists. Versions no longer in the lists stay NOASSERTION.
+	ResolveDownloadURLs bool
+
+	// IncludeConfigFiles keeps removed packages whose configuration files
+	// are still present (dpkg state config-files). Their relationship to
+	// the system root carries a comment saying so.
+	IncludeConfigFiles bool
+
+	// Reproducible makes the output depend only on the installed packages:
+	// the creation time comes from SOURCE_DATE_EPOCH (or the Unix epoch),
+	// the namespace is derived from the package set, and package SPDXIDs
+	// from names and versions instead of enumeration order
+	Reproducible bool
+
+	created       string
+	distro        string
+	assignedIDs   map[string]bool
+	hostArch      string
+	multiarch     map[string]bool
+	files         fileLimiter
+	licenseIgnore *licenseIgnoreList
+	trusted       *maintainerAllowlist
+	copyrights    *copyrightCache
+	denied        permissionLog
+	skipped       []SkippedPackage
+	usnAdvisories map[string][]string
+	downloadSizes map[string]int64
+	downloadURLs  map[string]string
+	aptOrigins    map[string]string
+
+	// hostRoot prefixes the paths read from the host filesystem; tests
+	// point it at a fixture tree
+	hostRoot string
 }
 
 func NewGenerator(includeFiles, showProgress bool) *Generator {
 	return &Generator{
-		IncludeFiles: includeFiles,
-		ShowProgress: showProgress,
+		IncludeFiles:       includeFiles,
+		ShowProgress:       showProgress,
+		MaxOpenFiles:       defaultMaxOpenFiles,
+		MaxCopyrightLength: defaultMaxCopyrightLength,
+		Runner:             execRunner{},
+		Jobs:               runtime.NumCPU(),
 	}
 }
 
 func (g *Generator) Generate() (*spdx.Document, error) {
-	packages, err := g.getInstalledPackages()
+	g.assignedIDs = map[string]bool{"SPDXRef-Ubuntu-System": true}
+	g.files = newFileLimiter(g.MaxOpenFiles)
+	g.copyrights = newCopyrightCache()
+	g.denied = permissionLog{}
+
+	for _, algorithm := range g.ChecksumAlgorithms {
+		if spdx.NewHash(algorithm) == nil {
+			return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+		}
+	}
+
+	if g.LicenseIgnoreFile != "" {
+		ignore, err := loadLicenseIgnore(g.LicenseIgnoreFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load license ignore list: %w", err)
+		}
+		g.licenseIgnore = ignore
+	}
+
+	if g.TrustedMaintainersFile != "" {
+		trusted, err := loadTrustedMaintainers(g.TrustedMaintainersFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trusted maintainers: %w", err)
+		}
+		g.trusted = trusted
+	}
+
+	packages, err := g.listPackages()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get packages: %w", err)
+		return nil, err
+	}
+
+	osRelease := readOSRelease(g.rootPath("/etc/os-release"))
+	g.distro = distroQualifier(osRelease)
+	if g.distro == "" {
+		logging.Warnf("distribution release unknown (no usable os-release), omitting the purl distro qualifier")
+	}
+
+	if g.USNDatabase != "" {
+		// Instances of a package for several architectures share the
+		// version, so the name is enough
+		installed := make(map[string]string, len(packages))
+		for _, pkg := range packages {
+			installed[pkg.Name] = pkg.Version
+		}
+		advisories, err := loadUSNAdvisories(g.USNDatabase, osRelease["VERSION_CODENAME"], installed)
+		if err != nil {
+			logging.Warnf("skipping USN advisory references: %v", err)
+		} else {
+			g.usnAdvisories = advisories
+		}
+	}
+
+	// Filtering is done first so dropped packages cost nothing, and
+	// captured selections have no copyright files to read
+	if g.SelectionsFile == "" {
+		g.readLicenses(packages)
+	}
+
+	if g.ResolveDownloadURLs {
+		urls, err := g.loadDownloadURLs(g.rootPath(aptListsDir))
+		if err != nil {
+			logging.Warnf("apt lists unavailable, download locations will be NOASSERTION: %v", err)
+		}
+		g.downloadURLs = urls
+	}
+
+	if g.DownloadSizes {
+		var sizes map[string]int64
+		var err error
+		// The host's apt-cache knows the host's archives, not the root's
+		if g.DpkgRoot != "" {
+			sizes, err = g.readDownloadSizes(g.rootPath(aptListsDir))
+		} else {
+			sizes, err = g.lookupDownloadSizes(packages)
+		}
+		if err != nil {
+			logging.Warnf("skipping download sizes, apt metadata unavailable: %v", err)
+		} else {
+			g.downloadSizes = sizes
+		}
+	}
+
+	if g.AllArchAs == "host" {
+		g.hostArch = g.hostArchitecture(packages)
+	}
+	g.multiarch = multiarchNames(packages)
+
+	created, err := spdx.CreationTime(g.Reproducible)
+	if err != nil {
+		return nil, err
 	}
+	g.created = created.Format(time.RFC3339)
 
 	doc := &spdx.Document{
 		SPDXVersion:       "SPDX-2.3",
 		DataLicense:       "CC0-1.0",
 		SPDXID:            "SPDXRef-DOCUMENT",
-		Name:              fmt.Sprintf("Ubuntu-System-SBOM-%s", time.Now().Format("2006-01-02")),
-		DocumentNamespace: fmt.Sprintf("https://sbom.ubuntu.system/%s", generateUUID()),
+		Name:              fmt.Sprintf("Ubuntu-System-SBOM-%s", created.Format("2006-01-02")),
+		DocumentNamespace: fmt.Sprintf("https://sbom.ubuntu.system/%s", spdx.NewUUID()),
 		CreationInfo: spdx.CreationInfo{
-			Created:            time.Now().UTC().Format(time.RFC3339),
-			Creators:           []string{"Tool: ubuntu-sbom-generator-1.0"},
+			Created:            g.created,
+			Creators:           []string{version.Tool()},
 			LicenseListVersion: "3.20",
 		},
 		Packages:      []spdx.Package{},
@@ -62,31 +329,127 @@ func (g *Generator) Generate() (*spdx.Document, error) {
 
 	// Add root package representing the Ubuntu system
 	rootPkg := spdx.Package{
-		SPDXID:           "SPDXRef-Ubuntu-System",
-		Name:             "Ubuntu-System",
-		DownloadLocation: "NOASSERTION",
-		FilesAnalyzed:    false,
-		LicenseConcluded: "NOASSERTION",
-		LicenseDeclared:  "NOASSERTION",
-		CopyrightText:    "NOASSERTION",
+		SPDXID:                "SPDXRef-Ubuntu-System",
+		Name:                  "Ubuntu-System",
+		DownloadLocation:      "NOASSERTION",
+		FilesAnalyzed:         false,
+		LicenseConcluded:      "NOASSERTION",
+		LicenseDeclared:       "NOASSERTION",
+		CopyrightText:         "NOASSERTION",
+		PrimaryPackagePurpose: "OPERATING-SYSTEM",
+	}
+	// Tie the root to the release it describes, so consumers can tell
+	// which OS the SBOM is of from one package
+	if codename := osRelease["VERSION_CODENAME"]; codename != "" {
+		rootPkg.Name += "-" + codename
+	}
+	rootPkg.PackageVersion = osRelease["VERSION_ID"]
+	if purl := releasePurl(osRelease); purl != "" {
+		rootPkg.ExternalRefs = []spdx.ExternalRef{{
+			Category: "PACKAGE-MANAGER",
+			Type:     "purl",
+			Locator:  purl,
+		}}
 	}
 	doc.Packages = append(doc.Packages, rootPkg)
 
 	// Process each package
+	var totalSize int64
+	sizedCount := 0
+	packageIDs := make([]string, len(packages))
 	for i, pkg := range packages {
-		if g.ShowProgress && i%100 == 0 {
-			fmt.Printf("Processing package %d/%d...\n", i+1, len(packages))
-		}
-
 		spdxPkg := g.packageToSPDX(pkg, i+1)
+		packageIDs[i] = spdxPkg.SPDXID
+		if g.AptOrigins || g.ThirdPartyOnly {
+			origin := g.aptOrigins[aptKey(pkg.Name, pkg.Version, pkg.Architecture)]
+			spdxPkg.Annotations = append(spdxPkg.Annotations, g.annotation(originComment(origin)))
+		}
+		if url, ok := g.downloadURLs[aptKey(pkg.Name, pkg.Version, pkg.Architecture)]; ok {
+			spdxPkg.DownloadLocation = url
+		}
+		if size, ok := g.downloadSizes[aptKey(pkg.Name, pkg.Version, pkg.Architecture)]; ok {
+			spdxPkg.Annotations = append(spdxPkg.Annotations, g.annotation(fmt.Sprintf("download-size: %d bytes", size)))
+			totalSize += size
+			sizedCount++
+		}
 		doc.Packages = append(doc.Packages, spdxPkg)
 
 		// Add relationship
-		doc.Relationships = append(doc.Relationships, spdx.Relationship{
+		relationship := spdx.Relationship{
 			SPDXElementID:      "SPDXRef-Ubuntu-System",
 			RelatedSPDXElement: spdxPkg.SPDXID,
 			RelationshipType:   "CONTAINS",
-		})
+		}
+		if dpkgState(pkg.Status) == "config-files" {
+			relationship.Comment = "config-files: package removed, only its configuration files remain"
+		}
+		doc.Relationships = append(doc.Relationships, relationship)
+
+		// The system runs on its kernel; with several installed, any of
+		// them may be the one booted
+		if isKernelImage(pkg.Name) {
+			doc.Relationships = append(doc.Relationships, spdx.Relationship{
+				SPDXElementID:      spdxPkg.SPDXID,
+				RelatedSPDXElement: "SPDXRef-Ubuntu-System",
+				RelationshipType:   "RUNTIME_DEPENDENCY_OF",
+				Comment:            "kernel image",
+			})
+		}
+	}
+
+	// If include-files is set, calculate package verification.
+	// Selections captures have no files to hash.
+	if g.IncludeFiles && g.SelectionsFile == "" {
+		for i, result := range g.hashPackages(packages) {
+			if result.code == "" {
+				continue
+			}
+			// doc.Packages[0] is the root, packages follow in order
+			pkg := &doc.Packages[i+1]
+			pkg.FilesAnalyzed = true
+			pkg.VerificationCode = &spdx.Verification{Value: result.code}
+
+			for _, file := range result.files {
+				doc.Files = append(doc.Files, file)
+				doc.Relationships = append(doc.Relationships, spdx.Relationship{
+					SPDXElementID:      pkg.SPDXID,
+					RelatedSPDXElement: file.SPDXID,
+					RelationshipType:   "CONTAINS",
+				})
+			}
+		}
+		if g.EmitFiles {
+			logging.Infof("Added %d file elements", len(doc.Files))
+		}
+	}
+
+	if g.SelectionsFile == "" {
+		dependencies, unresolved := dependencyRelationships(packages, packageIDs)
+		doc.Relationships = append(doc.Relationships, dependencies...)
+		logging.Infof("Added %d dependency relationships (%d dependencies not satisfied by installed packages)", len(dependencies), unresolved)
+	}
+
+	if g.DebugLinks && g.SelectionsFile == "" {
+		links := g.debugLinks(packages, packageIDs)
+		doc.Relationships = append(doc.Relationships, links...)
+		logging.Infof("Linked %d packages to their debug symbols packages", len(links))
+	}
+
+	if g.KernelModules {
+		modules, err := g.getLoadedModules()
+		if err != nil {
+			logging.Warnf("skipping kernel modules, lsmod/modinfo unavailable: %v", err)
+		} else {
+			modulePkgs, moduleRels := g.kernelModulesToSPDX(modules, "SPDXRef-Ubuntu-System")
+			doc.Packages = append(doc.Packages, modulePkgs...)
+			doc.Relationships = append(doc.Relationships, moduleRels...)
+			logging.Infof("Added %d loaded kernel modules", len(modulePkgs))
+		}
+	}
+
+	if g.downloadSizes != nil {
+		doc.Packages[0].Annotations = append(doc.Packages[0].Annotations, g.annotation(
+			fmt.Sprintf("total-download-size: %d bytes (%d of %d packages known to apt)", totalSize, sizedCount, len(packages))))
 	}
 
 	// Add document describes relationship
@@ -96,86 +459,352 @@ func (g *Generator) Generate() (*spdx.Document, error) {
 		RelationshipType:   "DESCRIBES",
 	})
 
+	spdx.DefineLicenseRefs(doc)
+
+	if g.Reproducible {
+		doc.DocumentNamespace = fmt.Sprintf("https://sbom.ubuntu.system/%s", spdx.ContentUUID(doc))
+	}
+
+	if err := g.denied.err(); err != nil {
+		if g.RequireReadable {
+			return nil, err
+		}
+		logging.Warnf("%v", err)
+	}
+
 	return doc, nil
 }
 
-func (g *Generator) getInstalledPackages() ([]DpkgPackage, error) {
-	cmd := exec.Command("dpkg-query", "-W", "-f=${Package}\t${Version}\t${Architecture}\t${Status}\t${Maintainer}\t${Homepage}\t${Description}\n")
-	output, err := cmd.Output()
+// listPackages enumerates the packages to describe, from a selections
+// capture, DpkgRoot or the host's dpkg, with the filters applied
+func (g *Generator) listPackages() ([]DpkgPackage, error) {
+	// Selections captures and dpkg roots are plain files and can be
+	// processed anywhere, everything else needs the local dpkg database
+	if runtime.GOOS != "linux" && g.SelectionsFile == "" && g.DpkgRoot == "" {
+		return nil, fmt.Errorf("ubuntu SBOM generation requires a Debian-based Linux system with dpkg (running on %s); use --from-selections to build from a captured package list", runtime.GOOS)
+	}
+
+	g.skipped = nil
+
+	var packages []DpkgPackage
+	var err error
+	if g.SelectionsFile != "" {
+		packages, err = g.readSelections(g.SelectionsFile)
+	} else if g.DpkgRoot != "" {
+		packages, err = g.readDpkgStatus()
+	} else {
+		packages, err = g.getInstalledPackages()
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get packages: %w", err)
+	}
+
+	if g.AptOrigins || g.ThirdPartyOnly {
+		origins, err := g.loadAptOrigins(g.rootPath(aptListsDir))
+		if err != nil {
+			logging.Warnf("apt lists unavailable, package origins will be unknown: %v", err)
+		}
+		g.aptOrigins = origins
+
+		if g.ThirdPartyOnly {
+			packages = g.filterThirdParty(packages)
+		}
+	}
+
+	if !g.Since.IsZero() {
+		packages = g.filterSince(packages)
+	}
+
+	return packages, nil
+}
+
+// filterThirdParty drops packages that come from the official archive
+func (g *Generator) filterThirdParty(packages []DpkgPackage) []DpkgPackage {
+	var kept []DpkgPackage
+	for _, pkg := range packages {
+		origin := g.aptOrigins[aptKey(pkg.Name, pkg.Version, pkg.Architecture)]
+		if classifyOrigin(origin) == OriginOfficial {
+			g.skip(SkippedPackage{
+				Name:         pkg.Name,
+				Version:      pkg.Version,
+				Architecture: pkg.Architecture,
+				Reason:       SkipFiltered,
+				Detail:       "official archive package excluded by --third-party-only",
+			})
+			continue
+		}
+		kept = append(kept, pkg)
+	}
+
+	logging.Infof("Kept %d third-party packages", len(kept))
+	return kept
+}
+
+func originComment(origin string) string {
+	if origin == "" {
+		return fmt.Sprintf("apt-origin: %s", OriginUnknown)
+	}
+	return fmt.Sprintf("apt-origin: %s (%s)", origin, classifyOrigin(origin))
+}
+
+// progress starts reporting a loop over packages, as a bar with
+// ShowProgress on a terminal and as periodic log lines otherwise
+func (g *Generator) progress(label string, packages int) *progress.Bar {
+	return progress.New(label, "packages", packages, g.ShowProgress)
+}
+
+// annotation creates a package annotation attributed to this generator
+func (g *Generator) annotation(comment string) spdx.Annotation {
+	return spdx.Annotation{
+		AnnotationDate: g.created,
+		AnnotationType: "OTHER",
+		Annotator:      version.Tool(),
+		Comment:        comment,
+	}
+}
+
+// dpkgQueryFields are the fields requested from dpkg-query, in the order
+// getInstalledPackages reads them
+var dpkgQueryFields = []string{
+	"Package", "Version", "Architecture", "Status", "Maintainer", "Homepage",
+	"Depends", "Pre-Depends", "Provides", "Source", "Section", "Description",
+	"Essential", "Priority",
+}
+
+// Field values, descriptions in particular, can contain tabs and newlines,
+// so dpkg-query output is delimited with the ASCII unit and record
+// separators, which never appear in package metadata
+const (
+	dpkgFieldSeparator  = "\x1f"
+	dpkgRecordSeparator = "\x1e\n"
+)
+
+// dpkgQueryFormat is the dpkg-query --showformat for dpkgQueryFields
+func dpkgQueryFormat() string {
+	return "${" + strings.Join(dpkgQueryFields, "}"+dpkgFieldSeparator+"${") + "}" + dpkgRecordSeparator
+}
+
+func (g *Generator) getInstalledPackages() ([]DpkgPackage, error) {
+	output, queryErr := g.Runner.Output("dpkg-query", "-W", "-f="+dpkgQueryFormat())
+	if queryErr != nil {
+		// A broken database entry makes dpkg-query fail after listing
+		// every other package, which are still worth describing
+		var exitErr *exec.ExitError
+		if !errors.As(queryErr, &exitErr) {
+			return nil, queryErr
+		}
+		queryErr = fmt.Errorf("dpkg-query failed: %w: %s", queryErr, strings.TrimSpace(string(exitErr.Stderr)))
+		if g.Strict || len(output) == 0 {
+			return nil, queryErr
+		}
 	}
 
 	var packages []DpkgPackage
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "\t")
-
-		if len(parts) >= 7 && strings.Contains(parts[3], "installed") {
-			pkg := DpkgPackage{
-				Name:         parts[0],
-				Version:      parts[1],
-				Architecture: parts[2],
-				Status:       parts[3],
-				Maintainer:   parts[4],
-				Homepage:     parts[5],
-				Description:  parts[6],
-			}
 
-			// Try to get license information
-			pkg.License, pkg.Copyright = g.getPackageLicense(pkg.Name)
+	for _, record := range strings.Split(string(output), dpkgRecordSeparator) {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		parts := strings.Split(record, dpkgFieldSeparator)
+
+		if len(parts) != len(dpkgQueryFields) {
+			g.skip(SkippedPackage{
+				Name:   parts[0],
+				Reason: SkipParseError,
+				Detail: fmt.Sprintf("expected %d fields, got %d", len(dpkgQueryFields), len(parts)),
+			})
+			continue
+		}
+
+		// The synopsis is the first line, the extended description follows
+		// on continuation lines
+		description, _, _ := strings.Cut(parts[11], "\n")
+		description = strings.TrimSpace(description)
+
+		pkg := DpkgPackage{
+			Name:         parts[0],
+			Version:      parts[1],
+			Architecture: parts[2],
+			Status:       parts[3],
+			Maintainer:   parts[4],
+			Homepage:     parts[5],
+			Depends:      splitRelationField(parts[6]),
+			PreDepends:   splitRelationField(parts[7]),
+			Provides:     splitRelationField(parts[8]),
+			Section:      parts[10],
+			Description:  description,
+			Essential:    parts[12] == "yes",
+			Priority:     parts[13],
+		}
+		pkg.Source, pkg.SourceVersion = parseSource(parts[9], pkg.Name, pkg.Version)
+
+		if !g.keepStatus(pkg.Status) {
+			g.skip(SkippedPackage{
+				Name:         pkg.Name,
+				Version:      pkg.Version,
+				Architecture: pkg.Architecture,
+				Reason:       SkipStatus,
+				Detail:       pkg.Status,
+			})
+			continue
+		}
 
-			packages = append(packages, pkg)
+		if g.filterOut(pkg) {
+			continue
 		}
+
+		packages = append(packages, pkg)
 	}
 
-	fmt.Printf("Found %d installed packages\n", len(packages))
+	if queryErr != nil {
+		logging.Warnf("%v; continuing with the %d packages it listed", queryErr, len(packages))
+	}
+	logging.Infof("Found %d installed packages", len(packages))
 	return packages, nil
 }
 
-func (g *Generator) getPackageLicense(packageName string) (string, string) {
-	copyrightPath := fmt.Sprintf("/usr/share/doc/%s/copyright", packageName)
+// copyrightInfo is what a copyright file tells about the packages
+// documented by it
+type copyrightInfo struct {
+	license         string
+	concluded       string
+	copyright       string
+	upstreamContact string
+	upstreamSource  string
+}
+
+// getPackageLicense fills in the package's license, copyright and upstream
+// details from its copyright file. Multiarch instances of a package share
+// one doc directory, so each copyright file is read and parsed only once.
+func (g *Generator) getPackageLicense(pkg *DpkgPackage) {
+	// dpkg keeps docs under the bare package name, without :arch
+	name, _, _ := strings.Cut(pkg.Name, ":")
+	copyrightPath := g.rootPath(fmt.Sprintf("/usr/share/doc/%s/copyright", name))
+
+	info := g.copyrights.get(copyrightPath, g.readCopyright)
+
+	pkg.License = info.license
+	pkg.ConcludedLicense = info.concluded
+	pkg.Copyright = info.copyright
+	pkg.UpstreamContact = info.upstreamContact
+	pkg.UpstreamSource = info.upstreamSource
+}
+
+// readCopyright reads a copyright file. Machine-readable (DEP-5) files are
+// parsed fully; anything else falls back to the first License: line and
+// the lines that look like copyright statements.
+func (g *Generator) readCopyright(copyrightPath string) copyrightInfo {
+	info := copyrightInfo{license: "NOASSERTION", copyright: "NOASSERTION"}
 
+	g.files.acquire()
 	content, err := os.ReadFile(copyrightPath)
+	g.files.release()
 	if err != nil {
-		return "NOASSERTION", "NOASSERTION"
+		g.denied.record(copyrightPath, err)
+		return info
 	}
 
 	text := string(content)
 
+	if dep5 := parseDEP5(text); dep5 != nil {
+		return g.dep5Info(dep5)
+	}
+
 	// Extract license
-	license := "NOASSERTION"
 	licenseRe := regexp.MustCompile(`(?i)License:\s*(.+?)(?:\n\n|\n[A-Z]|\z)`)
 	if matches := licenseRe.FindStringSubmatch(text); len(matches) > 1 {
-		license = normalizeLicense(strings.TrimSpace(matches[1]))
+		info.license = g.normalizeLicense(matches[1])
 	}
 
-	// Get first 200 chars of copyright or NOASSERTION
-	copyright := "NOASSERTION"
-	if len(text) > 0 {
-		if len(text) > 200 {
-			copyright = text[:200] + "..."
-		} else {
-			copyright = text
+	info.copyright = g.truncateCopyright(strings.Join(copyrightLines(text), "\n"))
+	return info
+}
+
+// dep5Info takes the declared license from the package-wide license, the
+// concluded license from every Files stanza, and copyright from the
+// collected copyright lines
+func (g *Generator) dep5Info(c *dep5Copyright) copyrightInfo {
+	concluded := make([]string, 0, len(c.Licenses))
+	for _, license := range c.Licenses {
+		concluded = append(concluded, g.normalizeLicense(license))
+	}
+
+	return copyrightInfo{
+		license:         g.normalizeLicense(c.declaredLicense()),
+		concluded:       spdx.ConjoinLicenses(concluded),
+		copyright:       g.truncateCopyright(strings.Join(c.Copyrights, "\n")),
+		upstreamContact: c.UpstreamContact,
+		upstreamSource:  c.Source,
+	}
+}
+
+// normalizeLicense maps a raw License: value to SPDX, honoring the ignore
+// list
+func (g *Generator) normalizeLicense(raw string) string {
+	if raw == "" || g.licenseIgnore.matches(raw) {
+		return "NOASSERTION"
+	}
+	return spdx.NormalizeLicense(raw)
+}
+
+// defaultMaxCopyrightLength keeps documents of systems with long
+// copyright files manageable
+const defaultMaxCopyrightLength = 200
+
+// copyrightStatement matches a line that states a copyright, such as
+// "Copyright (C) 2004 Jane Doe", "Copyright: 2004 Jane Doe", "(c) 2004
+// Jane Doe" or "Foo is Copyright 2004 Jane Doe", but not license text like
+// "copyright notice"
+var copyrightStatement = regexp.MustCompile(`(?i)(^copyright\s*:|copyright\s*(\(c\)|©|[0-9])|^\(c\)\s*[0-9]|^©)`)
+
+// copyrightLines returns the distinct copyright statements of a free-form
+// copyright file, without a "Copyright:" field name
+func copyrightLines(text string) []string {
+	var lines []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !copyrightStatement.MatchString(line) {
+			continue
 		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "copyright") {
+			line = strings.TrimSpace(value)
+		}
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
 	}
+	return lines
+}
 
-	return license, copyright
+// truncateCopyright limits copyright text to MaxCopyrightLength
+// characters, marking cut text with "..."
+func (g *Generator) truncateCopyright(text string) string {
+	if len(text) == 0 {
+		return "NOASSERTION"
+	}
+	if g.MaxCopyrightLength > 0 {
+		if runes := []rune(text); len(runes) > g.MaxCopyrightLength {
+			return string(runes[:g.MaxCopyrightLength]) + "..."
+		}
+	}
+	return text
 }
 
 func (g *Generator) packageToSPDX(pkg DpkgPackage, id int) spdx.Package {
 	spdxPkg := spdx.Package{
-		SPDXID:           fmt.Sprintf("SPDXRef-Ubuntu-Package-%d-%s", id, sanitizeName(pkg.Name)),
-		Name:             pkg.Name,
-		PackageVersion:   pkg.Version,
-		DownloadLocation: "NOASSERTION",
-		FilesAnalyzed:    false,
-		LicenseConcluded: pkg.License,
-		LicenseDeclared:  pkg.License,
-		CopyrightText:    pkg.Copyright,
-		Description:      pkg.Description,
+		SPDXID:                g.packageSPDXID(pkg, id),
+		Name:                  pkg.Name,
+		PackageVersion:        pkg.Version,
+		DownloadLocation:      "NOASSERTION",
+		FilesAnalyzed:         false,
+		LicenseConcluded:      pkg.License,
+		LicenseDeclared:       pkg.License,
+		CopyrightText:         pkg.Copyright,
+		Description:           pkg.Description,
+		PrimaryPackagePurpose: primaryPurpose(pkg),
 	}
 
 	if pkg.Homepage != "" && pkg.Homepage != "(none)" {
@@ -186,170 +815,244 @@ func (g *Generator) packageToSPDX(pkg DpkgPackage, id int) spdx.Package {
 		spdxPkg.Supplier = fmt.Sprintf("Organization: %s", pkg.Maintainer)
 	}
 
+	if pkg.ConcludedLicense != "" {
+		spdxPkg.LicenseConcluded = pkg.ConcludedLicense
+	}
+
+	if pkg.Essential {
+		spdxPkg.Annotations = append(spdxPkg.Annotations, g.annotation("essential: yes"))
+	}
+	if pkg.Priority != "" {
+		spdxPkg.Annotations = append(spdxPkg.Annotations, g.annotation("priority: "+pkg.Priority))
+	}
+	if g.trusted != nil && !g.trusted.trusts(pkg.Maintainer) {
+		spdxPkg.Annotations = append(spdxPkg.Annotations, g.annotation(UntrustedMaintainer))
+	}
+
+	// The Debian maintainer supplies the package, the upstream contact
+	// originates the software
+	if pkg.UpstreamContact != "" {
+		spdxPkg.Originator = fmt.Sprintf("Organization: %s", pkg.UpstreamContact)
+	}
+	if pkg.UpstreamSource != "" {
+		spdxPkg.DownloadLocation = pkg.UpstreamSource
+	}
+
 	// Add external reference for the package
+	purlPkg := pkg
+	if pkg.Architecture == "all" && g.hostArch != "" {
+		purlPkg.Architecture = g.hostArch
+	}
 	spdxPkg.ExternalRefs = []spdx.ExternalRef{
 		{
 			Category: "PACKAGE-MANAGER",
 			Type:     "purl",
-			Locator:  fmt.Sprintf("pkg:deb/ubuntu/%s@%s?arch=%s", pkg.Name, pkg.Version, pkg.Architecture),
+			Locator:  buildPurl(purlPkg, g.distro),
 		},
 	}
 
-	// If include-files is set, calculate package verification
-	if g.IncludeFiles {
-		if checksum := g.calculatePackageChecksum(pkg.Name); checksum != "" {
-			spdxPkg.Checksums = []spdx.Checksum{
-				{
-					Algorithm: "SHA256",
-					Value:     checksum,
-				},
+	if pkg.Source != "" {
+		spdxPkg.ExternalRefs = append(spdxPkg.ExternalRefs, sourceExternalRef(pkg, g.distro))
+	}
+
+	if g.SWID {
+		spdxPkg.ExternalRefs = append(spdxPkg.ExternalRefs, swidExternalRef(pkg))
+	}
+
+	// Attach the Ubuntu Security Notices this package version is affected by
+	if ids, ok := g.usnAdvisories[pkg.Name]; ok {
+		spdxPkg.ExternalRefs = append(spdxPkg.ExternalRefs, usnExternalRefs(ids)...)
+	}
+
+	return spdxPkg
+}
+
+// buildPurl returns the package's deb purl, qualified with the distro
+// release (e.g. ubuntu-22.04) unless it is unknown
+func buildPurl(pkg DpkgPackage, distro string) string {
+	purl := fmt.Sprintf("pkg:deb/ubuntu/%s", pkg.Name)
+	if pkg.Version != "" {
+		purl += "@" + pkg.Version
+	}
+	// Qualifiers are sorted by key as the purl spec requires
+	var qualifiers []string
+	if pkg.Architecture != "" {
+		qualifiers = append(qualifiers, "arch="+pkg.Architecture)
+	}
+	if distro != "" {
+		qualifiers = append(qualifiers, "distro="+distro)
+	}
+	if upstream := upstreamQualifier(pkg); upstream != "" {
+		qualifiers = append(qualifiers, "upstream="+upstream)
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	return purl
+}
+
+// dpkgArchitectures maps Go architecture names to their dpkg equivalents
+// where they differ
+var dpkgArchitectures = map[string]string{
+	"386":     "i386",
+	"arm":     "armhf",
+	"ppc64le": "ppc64el",
+}
+
+// hostArchitecture returns the native dpkg architecture, falling back to
+// the architecture this binary was built for. Under DpkgRoot the host's
+// dpkg says nothing about the image, so the architecture of the image's
+// own dpkg package is used.
+func (g *Generator) hostArchitecture(packages []DpkgPackage) string {
+	if g.DpkgRoot != "" {
+		for _, pkg := range packages {
+			if pkg.Name == "dpkg" && pkg.Architecture != "" {
+				return pkg.Architecture
 			}
 		}
+	} else if output, err := g.Runner.Output("dpkg", "--print-architecture"); err == nil {
+		if arch := strings.TrimSpace(string(output)); arch != "" {
+			return arch
+		}
 	}
 
-	return spdxPkg
+	if arch, ok := dpkgArchitectures[runtime.GOARCH]; ok {
+		return arch
+	}
+	return runtime.GOARCH
 }
 
-func (g *Generator) calculatePackageChecksum(packageName string) string {
-	cmd := exec.Command("dpkg", "-L", packageName)
-	output, err := cmd.Output()
+// hashPackageFiles computes the SPDX package verification code: the SHA1
+// of the sorted, concatenated SHA1 digests of the package's files. With
+// EmitFiles it also returns a File element per hashed file, numbered after
+// the package's id.
+func (g *Generator) hashPackageFiles(pkg DpkgPackage, id int) packageHashes {
+	var result packageHashes
+
+	files, err := g.packageFiles(pkg)
 	if err != nil {
-		return ""
+		return result
+	}
+
+	// Verification codes only need SHA1
+	algorithms := []string{"SHA1"}
+	if g.EmitFiles {
+		algorithms = g.fileChecksumAlgorithms()
 	}
 
-	h := sha256.New()
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	var digests []string
+	for _, filePath := range files {
+		if !g.shouldHash(filePath) {
+			continue
+		}
 
-	for scanner.Scan() {
-		filePath := scanner.Text()
-		if filePath == "" || strings.HasSuffix(filePath, "/") {
+		g.files.acquire()
+		checksums, err := hashFile(g.rootPath(filePath), algorithms)
+		g.files.release()
+		if err != nil {
+			g.denied.record(filePath, err)
 			continue
 		}
+		digests = append(digests, checksums[0].Value)
 
-		if fileHash := hashFile(filePath); fileHash != "" {
-			h.Write([]byte(fileHash))
+		if g.EmitFiles {
+			result.files = append(result.files, spdx.File{
+				SPDXID:    fmt.Sprintf("SPDXRef-Ubuntu-File-%d-%d", id, len(result.files)+1),
+				FileName:  "." + filePath,
+				Checksums: checksums,
+			})
 		}
 	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	if len(digests) == 0 {
+		return result
+	}
+
+	sort.Strings(digests)
+	result.code = fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(digests, ""))))
+	return result
 }
 
-func hashFile(path string) string {
-	file, err := os.Open(path)
-	if err != nil {
-		return ""
+func (g *Generator) shouldHash(filePath string) bool {
+	if len(g.HashPaths) == 0 {
+		return true
 	}
-	defer file.Close()
 
-	h := sha256.New()
-	if _, err := io.Copy(h, file); err != nil {
-		return ""
+	for _, pattern := range g.HashPaths {
+		if matched, _ := filepath.Match(pattern, filePath); matched {
+			return true
+		}
 	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return false
+}
+
+// fileChecksumAlgorithms returns ChecksumAlgorithms with SHA1 first
+func (g *Generator) fileChecksumAlgorithms() []string {
+	if len(g.ChecksumAlgorithms) == 0 {
+		return []string{"SHA1", "SHA256"}
+	}
+	algorithms := []string{"SHA1"}
+	for _, algorithm := range g.ChecksumAlgorithms {
+		if algorithm != "SHA1" {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
 }
 
-func (g *Generator) Save(doc *spdx.Document, outputPath string) error {
-	file, err := os.Create(outputPath)
+// hashFile returns a file's checksums for the given algorithms, computed
+// in one pass, in the same order
+func hashFile(path string, algorithms []string) ([]spdx.Checksum, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	hashes := make([]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, len(algorithms))
+	for i, algorithm := range algorithms {
+		hashes[i] = spdx.NewHash(algorithm)
+		writers[i] = hashes[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return nil, err
+	}
 
-	return encoder.Encode(doc)
+	checksums := make([]spdx.Checksum, len(algorithms))
+	for i, algorithm := range algorithms {
+		checksums[i] = spdx.Checksum{Algorithm: algorithm, Value: fmt.Sprintf("%x", hashes[i].Sum(nil))}
+	}
+	return checksums, nil
 }
 
-func normalizeLicense(license string) string {
-	// Map common license strings to SPDX identifiers
-	license = strings.TrimSpace(license)
-
-	// If empty, return NOASSERTION
-	if license == "" {
-		return "NOASSERTION"
+// packageSPDXID numbers packages in enumeration order, or names them by
+// name and version for reproducible output. sanitizeName maps distinct
+// names such as foo+bar and foo.bar:amd64 onto the same characters, so an
+// ID that is already assigned gets a numeric suffix.
+func (g *Generator) packageSPDXID(pkg DpkgPackage, id int) string {
+	// Instances of a package for several architectures share name and
+	// usually version, so the architecture keeps their IDs apart
+	name := pkg.Name
+	if g.multiarch[pkg.Name] && pkg.Architecture != "" {
+		name += "-" + pkg.Architecture
 	}
 
-	// Normalize to lowercase for case-insensitive matching
-	licenseLower := strings.ToLower(license)
-
-	// Check for known SPDX patterns (case-insensitive)
-	replacements := map[string]string{
-		"gpl-2":                       "GPL-2.0-only",
-		"gpl-2+":                      "GPL-2.0-or-later",
-		"gpl-3":                       "GPL-3.0-only",
-		"gpl-3+":                      "GPL-3.0-or-later",
-		"lgpl-2":                      "LGPL-2.0-only",
-		"lgpl-2+":                     "LGPL-2.0-or-later",
-		"lgpl-2.1":                    "LGPL-2.1-only",
-		"lgpl-2.1+":                   "LGPL-2.1-or-later",
-		"lgpl-3":                      "LGPL-3.0-only",
-		"lgpl-3+":                     "LGPL-3.0-or-later",
-		"apache-2":                    "Apache-2.0",
-		"apache":                      "NOASSERTION",
-		"bsd":                         "BSD-3-Clause",
-		"mit/x11":                     "MIT",
-		"expat":                       "MIT",
-		"mit-1":                       "MIT",
-		"mit-style":                   "MIT",
-		"psf":                         "Python-2.0",
-		"public-domain":               "NOASSERTION",
-		"openldap-2.8":                "NOASSERTION",
-		"hylafax":                     "NOASSERTION",
-		"ubuntu-font-licence-1.0":     "Ubuntu-Font-1.0",
-		"go":                          "NOASSERTION",
-		"epl-1":                       "EPL-1.0",
-		"dom4j":                       "NOASSERTION",
-		"fastcgi":                     "NOASSERTION",
-		"other":                       "NOASSERTION",
-		"eclipse-public-license-v1.0": "EPL-1.0",
-		"edl-1.0":                     "BSD-3-Clause",
-		"nrl-2-clause":                "NOASSERTION",
-		"tidy":                        "NOASSERTION",
-		"purdue":                      "NOASSERTION",
-		"mpl-2":                       "MPL-2.0",
-	}
-
-	// Check for exact match first (case-insensitive)
-	if mapped, ok := replacements[licenseLower]; ok {
-		return mapped
-	}
-
-	// Check for prefix match (case-insensitive)
-	for old, new := range replacements {
-		if strings.HasPrefix(licenseLower, old) {
-			return new
-		}
-	}
-
-	// Check if it looks like a valid SPDX identifier
-	validSPDXPattern := regexp.MustCompile(`^[A-Za-z0-9.\-]+(\s+(AND|OR|WITH)\s+[A-Za-z0-9.\-]+)*$`)
-
-	if validSPDXPattern.MatchString(license) {
-		return license
-	}
-
-	// If it contains copyright statements, full sentences, or invalid characters, return NOASSERTION
-	invalidPatterns := []string{
-		"Copyright", "copyright", "Permission is hereby", "The files",
-		"Formerly,", "build-aux", "Portions", "free software",
-		"<", ">", "'", ",",
-	}
-
-	for _, pattern := range invalidPatterns {
-		if strings.Contains(license, pattern) {
-			return "NOASSERTION"
-		}
-	}
-
-	// If license string is longer than 50 chars, it's probably license text
-	if len(license) > 50 {
-		return "NOASSERTION"
+	spdxID := fmt.Sprintf("SPDXRef-Ubuntu-Package-%d-%s", id, sanitizeName(name))
+	if g.Reproducible {
+		spdxID = "SPDXRef-Ubuntu-Package-" + sanitizeName(name)
+		if pkg.Version != "" {
+			spdxID += "-" + sanitizeName(pkg.Version)
+		}
 	}
 
-	// Default: if we can't confidently map it, use NOASSERTION
-	return "NOASSERTION"
+	if g.assignedIDs == nil {
+		g.assignedIDs = make(map[string]bool)
+	}
+	spdxID = spdx.UniqueID(spdxID, g.assignedIDs)
+	g.assignedIDs[spdxID] = true
+	return spdxID
 }
 
 func sanitizeName(name string) string {
@@ -357,14 +1060,3 @@ func sanitizeName(name string) string {
 	re := regexp.MustCompile(`[^a-zA-Z0-9-.]`)
 	return re.ReplaceAllString(name, "-")
 }
-
-func generateUUID() string {
-	// Simple UUID v4 generation
-	b := make([]byte, 16)
-	for i := range b {
-		b[i] = byte(time.Now().UnixNano() & 0xff)
-	}
-
-	return fmt.Sprintf("%x-%x-%x-%x-%x",
-		b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
-}
@@ -0,0 +1,135 @@
+package ubuntu
+
+import (
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// multiarchGenerator returns a generator for a system with libc6 and
+// libgcc-s1 installed for amd64 and i386
+func multiarchGenerator(t *testing.T) *Generator {
+	t.Helper()
+	installed := func(name, arch, depends string) string {
+		return dpkgQueryRecord(map[string]string{
+			"Package":      name,
+			"Version":      "1.0",
+			"Architecture": arch,
+			"Status":       "install ok installed",
+			"Depends":      depends,
+		})
+	}
+	g := queryGenerator(
+		installed("libgcc-s1", "amd64", ""),
+		installed("libgcc-s1", "i386", ""),
+		installed("libc6", "amd64", "libgcc-s1"),
+		installed("libc6", "i386", "libgcc-s1"),
+		installed("wine32", "i386", "libc6 (>= 1.0)"),
+		installed("i386-tool", "i386", "libgcc-s1:any"),
+		installed("tzdata", "all", "libc6"),
+	)
+	g.hostRoot = t.TempDir()
+	return g
+}
+
+// packageKeys maps the SPDXIDs of doc's Ubuntu packages to name:arch, as
+// read from their purls
+func packageKeys(t *testing.T, doc *spdx.Document) map[string]string {
+	t.Helper()
+	keys := make(map[string]string)
+	for _, pkg := range doc.Packages[1:] {
+		var purl string
+		for _, ref := range pkg.ExternalRefs {
+			if ref.Type == "purl" && !strings.Contains(ref.Locator, "arch=source") {
+				purl = ref.Locator
+			}
+		}
+		_, query, _ := strings.Cut(purl, "?")
+		arch := ""
+		for _, qualifier := range strings.Split(query, "&") {
+			if value, ok := strings.CutPrefix(qualifier, "arch="); ok {
+				arch = value
+			}
+		}
+		if arch == "" {
+			t.Errorf("%s has no arch in purl %q", pkg.SPDXID, purl)
+		}
+		keys[pkg.SPDXID] = pkg.Name + ":" + arch
+	}
+	return keys
+}
+
+func TestGenerateMultiarch(t *testing.T) {
+	doc, err := multiarchGenerator(t).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := packageKeys(t, doc)
+
+	var got []string
+	for _, key := range keys {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	want := []string{"i386-tool:i386", "libc6:amd64", "libc6:i386", "libgcc-s1:amd64", "libgcc-s1:i386", "tzdata:all", "wine32:i386"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("packages %q, want %q", got, want)
+	}
+
+	var depends []string
+	for _, rel := range doc.Relationships {
+		if rel.RelationshipType == "DEPENDS_ON" {
+			depends = append(depends, keys[rel.SPDXElementID]+" -> "+keys[rel.RelatedSPDXElement])
+		}
+	}
+	sort.Strings(depends)
+	want = []string{
+		// :any takes the first installed architecture
+		"i386-tool:i386 -> libgcc-s1:amd64",
+		"libc6:amd64 -> libgcc-s1:amd64",
+		"libc6:i386 -> libgcc-s1:i386",
+		// Architecture: all packages take the first too
+		"tzdata:all -> libc6:amd64",
+		"wine32:i386 -> libc6:i386",
+	}
+	if !reflect.DeepEqual(depends, want) {
+		t.Errorf("dependencies %q, want %q", depends, want)
+	}
+}
+
+func TestGenerateArchitectureFilter(t *testing.T) {
+	g := multiarchGenerator(t)
+	g.Architectures = []string{"i386"}
+	doc, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, key := range packageKeys(t, doc) {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	want := []string{"i386-tool:i386", "libc6:i386", "libgcc-s1:i386", "tzdata:all", "wine32:i386"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("packages %q, want %q", got, want)
+	}
+	if err := spdx.CheckConsistency(doc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateForgetsDeniedReads(t *testing.T) {
+	g := multiarchGenerator(t)
+	g.RequireReadable = true
+	// As left by an earlier run that could not read a copyright file
+	g.denied.record("/usr/share/doc/libc6/copyright", fs.ErrPermission)
+
+	if _, err := g.Generate(); err != nil {
+		t.Errorf("denied reads of an earlier run failed this one: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package ubuntu
+
+import (
+	"sync"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// packageHashes is the result of hashing one package's files
+type packageHashes struct {
+	// code is the package verification code, "" if nothing was hashed
+	code string
+	// files holds a File element per hashed file when EmitFiles is set
+	files []spdx.File
+}
+
+// hashPackages hashes the files of every package on a pool of Jobs
+// workers. Results are indexed like packages so the caller can apply them
+// in a deterministic order.
+func (g *Generator) hashPackages(packages []DpkgPackage) []packageHashes {
+	jobs := g.Jobs
+	if jobs <= 0 {
+		jobs = 1
+	}
+
+	if g.ShowProgress {
+		logging.Infof("Hashing files of %d packages with %d workers...", len(packages), jobs)
+	}
+
+	bar := g.progress("Hashing files", len(packages))
+	defer bar.Done()
+	results := make([]packageHashes, len(packages))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = g.hashPackageFiles(packages[i], i+1)
+				bar.Add(1)
+			}
+		}()
+	}
+
+	for i := range packages {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,27 @@
+package ubuntu
+
+import "testing"
+
+func TestPackageSPDXIDCollisions(t *testing.T) {
+	g := NewGenerator(false, false)
+	g.Reproducible = true
+	g.multiarch = map[string]bool{"libfoo": true}
+
+	for _, tc := range []struct {
+		pkg  DpkgPackage
+		want string
+	}{
+		{DpkgPackage{Name: "foo+bar", Version: "1.0"}, "SPDXRef-Ubuntu-Package-foo-bar-1.0"},
+		{DpkgPackage{Name: "foo_bar", Version: "1.0"}, "SPDXRef-Ubuntu-Package-foo-bar-1.0-2"},
+		// Sanitizes to the suffixed ID the previous package was given
+		{DpkgPackage{Name: "foo-bar", Version: "1.0-2"}, "SPDXRef-Ubuntu-Package-foo-bar-1.0-2-2"},
+		{DpkgPackage{Name: "foo-bar", Version: "1.0:2"}, "SPDXRef-Ubuntu-Package-foo-bar-1.0-2-3"},
+		{DpkgPackage{Name: "libfoo", Version: "2", Architecture: "amd64"}, "SPDXRef-Ubuntu-Package-libfoo-amd64-2"},
+		{DpkgPackage{Name: "libfoo", Version: "2", Architecture: "i386"}, "SPDXRef-Ubuntu-Package-libfoo-i386-2"},
+		{DpkgPackage{Name: "libfoo-amd64", Version: "2"}, "SPDXRef-Ubuntu-Package-libfoo-amd64-2-2"},
+	} {
+		if got := g.packageSPDXID(tc.pkg, 0); got != tc.want {
+			t.Errorf("%s %s: got %s, want %s", tc.pkg.Name, tc.pkg.Version, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package ubuntu
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// imageMetadataPaths are the parts of an image root the generator reads
+// when it does not hash package files
+var imageMetadataPaths = []string{
+	"var/lib/dpkg/",
+	"var/lib/apt/lists/",
+	"usr/share/doc/",
+	"etc/os-release",
+	"usr/lib/os-release",
+}
+
+// OpenImage prepares a container image root for use as DpkgRoot. A
+// directory (an unpacked rootfs) is used as is. A tar file, optionally
+// gzip compressed, such as the output of `docker export`, is extracted to
+// a temporary directory; only the dpkg database, apt lists, package
+// documentation and os-release are extracted unless full is set, which is
+// needed to hash package files. The returned cleanup removes anything
+// extracted.
+func OpenImage(imagePath string, full bool) (string, func(), error) {
+	info, err := os.Stat(imagePath)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		return imagePath, func() {}, nil
+	}
+
+	root, err := os.MkdirTemp("", "sbom-image-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(root) }
+
+	if err := extractImage(imagePath, root, full); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract %s: %w", imagePath, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, dpkgStatusFile)); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("%s has no dpkg database (%s)", imagePath, dpkgStatusFile)
+	}
+	return root, cleanup, nil
+}
+
+func extractImage(imagePath, root string, full bool) error {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Entries are checked against the root with its own symlinks resolved,
+	// as the temporary directory may itself be reached through one
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = bufio.NewReader(file)
+	magic, _ := reader.(*bufio.Reader).Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Entries are relative to the image root, sometimes with a
+		// leading ./ or /. Anything climbing out of it is ignored.
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if name == "" || (!full && !isImageMetadata(name)) {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+
+		if err := extractEntry(archive, header, root, target); err != nil {
+			return fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+}
+
+func isImageMetadata(name string) bool {
+	for _, prefix := range imageMetadataPaths {
+		if name == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractEntry writes one archive entry at target. Earlier entries may
+// have made target's parent a symlink, so the parent is resolved first and
+// nothing is written unless it stays under root; relative symlinks are
+// held to the same rule.
+func extractEntry(archive *tar.Reader, header *tar.Header, root, target string) error {
+	dir, err := resolveInRoot(root, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	target = filepath.Join(dir, filepath.Base(target))
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		// An existing directory, or a link to one, is kept
+		if info, err := os.Lstat(target); err == nil {
+			if info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+				return nil
+			}
+			os.Remove(target)
+		}
+		return os.Mkdir(target, 0o755)
+	case tar.TypeReg:
+		// Replace rather than write through an existing symlink or hard
+		// link
+		os.Remove(target)
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, archive); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	case tar.TypeSymlink:
+		// Absolute links refer to the image root, not the host's
+		link := header.Linkname
+		if filepath.IsAbs(link) {
+			link = filepath.Join(root, link)
+		} else if !isWithin(root, filepath.Join(dir, link)) {
+			return fmt.Errorf("symlink to %s points outside the image root", header.Linkname)
+		}
+		os.Remove(target)
+		if err := os.Symlink(link, target); err != nil {
+			return err
+		}
+		// The lexical check above cannot see through symlinks within the
+		// link itself, so a link that resolves is checked again
+		if resolved, err := filepath.EvalSymlinks(target); err == nil && !isWithin(root, resolved) {
+			os.Remove(target)
+			return fmt.Errorf("symlink to %s points outside the image root", header.Linkname)
+		}
+		return nil
+	case tar.TypeLink:
+		source := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+header.Linkname), "/")))
+		sourceDir, err := resolveInRoot(root, filepath.Dir(source))
+		if err != nil {
+			return err
+		}
+		source = filepath.Join(sourceDir, filepath.Base(source))
+		os.Remove(target)
+		if err := os.Link(source, target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	default:
+		// Devices, fifos and the like carry no package metadata
+		return nil
+	}
+}
+
+// resolveInRoot returns dir with the symlinks in its existing part
+// resolved, failing when that leads outside root. The missing rest of dir
+// is joined on unresolved, to be created as plain directories.
+func resolveInRoot(root, dir string) (string, error) {
+	existing := dir
+	var missing []string
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(root, resolved) {
+		return "", fmt.Errorf("%s leads outside the image root", dir)
+	}
+	return filepath.Join(append([]string{resolved}, missing...)...), nil
+}
+
+// isWithin reports whether path is root or below it
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
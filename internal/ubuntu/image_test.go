@@ -0,0 +1,137 @@
+package ubuntu
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry is one entry of a test archive: a file with content, a
+// directory (name ending in /) or a symlink
+type tarEntry struct {
+	name    string
+	content string
+	link    string
+}
+
+func writeTar(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(entry.content))}
+		switch {
+		case entry.link != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, entry.link, 0
+		case strings.HasSuffix(entry.name, "/"):
+			header.Typeflag, header.Mode, header.Size = tar.TypeDir, 0o755, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractImage(t *testing.T) {
+	image := writeTar(t, []tarEntry{
+		{name: "var/lib/dpkg/"},
+		{name: "var/lib/dpkg/status", content: "Package: bash\n"},
+		{name: "usr/share/doc/bash/copyright", content: "License: GPL-3+\n"},
+		{name: "usr/share/doc/bash-doc", link: "bash"},
+		{name: "usr/share/doc/sh", link: "/usr/share/doc/bash"},
+		{name: "usr/bin/bash", content: "ELF"},
+	})
+	root := t.TempDir()
+	if err := extractImage(image, root, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"usr/share/doc/bash-doc/copyright", "usr/share/doc/sh/copyright"} {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil || string(data) != "License: GPL-3+\n" {
+			t.Errorf("%s: got %q, %v", path, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "usr/bin/bash")); !os.IsNotExist(err) {
+		t.Errorf("usr/bin/bash extracted without full: %v", err)
+	}
+}
+
+func TestExtractImageRefusesEscapes(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries func(outside string) []tarEntry
+	}{
+		{
+			name: "relative symlink out of the root",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{{name: "usr/share/doc/x", link: strings.Repeat("../", 8) + strings.TrimPrefix(outside, "/")}}
+			},
+		},
+		{
+			name: "write through an escaping symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "etc/x", link: strings.Repeat("../", 8) + strings.TrimPrefix(outside, "/")},
+					{name: "etc/x/owned", content: "x"},
+				}
+			},
+		},
+		{
+			name: "relative symlink climbing through another symlink",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "usr/share/doc/a/"},
+					{name: "usr/share/doc/a/l", link: ".."},
+					{name: "usr/share/doc/x", link: "a/l/../../../../.."},
+				}
+			},
+		},
+		{
+			name: "relative symlink resolved from a symlinked directory",
+			entries: func(outside string) []tarEntry {
+				return []tarEntry{
+					{name: "usr/share/doc/a/"},
+					{name: "usr/share/doc/a/l", link: ".."},
+					{name: "usr/share/doc/a/l/x", link: "../../../.."},
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			outside := t.TempDir()
+			root := filepath.Join(t.TempDir(), "root")
+			if err := os.Mkdir(root, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			image := writeTar(t, tc.entries(outside))
+			if err := extractImage(image, root, true); err == nil {
+				t.Error("escaping archive extracted without error")
+			}
+
+			entries, _ := os.ReadDir(outside)
+			if len(entries) > 0 {
+				t.Errorf("wrote outside the root: %v", entries)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(root), "owned")); !os.IsNotExist(err) {
+				t.Errorf("wrote next to the root: %v", err)
+			}
+		})
+	}
+}
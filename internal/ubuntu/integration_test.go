@@ -0,0 +1,186 @@
+package ubuntu
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ubuntu-nix-sbom/internal/merge"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// integrationDir holds the fixture system: status lists the packages
+// dpkg-query reports, root/ is the filesystem with their copyright
+// files, dpkg file lists and contents, and nix.spdx.json is an sbomnix
+// document to merge with
+const integrationDir = "testdata/integration"
+
+// fixtureRunner answers dpkg-query and dpkg -L from the fixture system
+type fixtureRunner struct {
+	*fakeRunner
+	stanzas []map[string]string
+}
+
+func newFixtureRunner(t *testing.T) *fixtureRunner {
+	t.Helper()
+	runner := &fixtureRunner{fakeRunner: &fakeRunner{outputs: map[string]string{
+		"dpkg --print-architecture": "amd64\n",
+	}}}
+
+	status, err := os.Open(filepath.Join(integrationDir, "status"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer status.Close()
+	err = readStanzas(status, func(fields map[string]string) {
+		runner.stanzas = append(runner.stanzas, fields)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dpkg -L takes the bare name unless the package is installed for
+	// several architectures
+	lists, _ := filepath.Glob(filepath.Join(integrationDir, "root/var/lib/dpkg/info/*.list"))
+	archs := make(map[string]int)
+	for _, list := range lists {
+		name, _, _ := strings.Cut(strings.TrimSuffix(filepath.Base(list), ".list"), ":")
+		archs[name]++
+	}
+	for _, list := range lists {
+		content, err := os.ReadFile(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qualified := strings.TrimSuffix(filepath.Base(list), ".list")
+		runner.outputs["dpkg -L "+qualified] = string(content)
+		if name, _, _ := strings.Cut(qualified, ":"); archs[name] == 1 {
+			runner.outputs["dpkg -L "+name] = string(content)
+		}
+	}
+
+	return runner
+}
+
+var queryField = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Output renders dpkg-query --showformat for every status stanza, like
+// dpkg-query -W does, and answers everything else from the fixed outputs
+func (r *fixtureRunner) Output(name string, args ...string) ([]byte, error) {
+	if name == "dpkg-query" && len(args) == 2 && args[0] == "-W" && strings.HasPrefix(args[1], "-f=") {
+		format := strings.TrimPrefix(args[1], "-f=")
+		var out strings.Builder
+		for _, fields := range r.stanzas {
+			out.WriteString(queryField.ReplaceAllStringFunc(format, func(field string) string {
+				return fields[strings.Trim(field, "${}")]
+			}))
+		}
+		return []byte(out.String()), nil
+	}
+	return r.fakeRunner.Output(name, args...)
+}
+
+var (
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z`)
+	namedDatePattern = regexp.MustCompile(`SBOM-\d{4}-\d{2}-\d{2}"`)
+	uuidPattern      = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+)
+
+// normalizeDocument encodes doc with what changes from run to run
+// replaced: timestamps, the date in the document name, UUIDs and the
+// checksums of source documents, which contain them
+func normalizeDocument(t *testing.T, doc *spdx.Document) []byte {
+	t.Helper()
+	for i := range doc.ExternalDocumentRefs {
+		doc.ExternalDocumentRefs[i].Checksum.Value = "0000000000000000000000000000000000000000"
+	}
+
+	var buf bytes.Buffer
+	if err := spdx.EncodeDocument(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	data := timestampPattern.ReplaceAll(buf.Bytes(), []byte("2000-01-01T00:00:00Z"))
+	data = namedDatePattern.ReplaceAll(data, []byte(`SBOM-2000-01-01"`))
+	return uuidPattern.ReplaceAll(data, []byte("00000000-0000-0000-0000-000000000000"))
+}
+
+// TestGenerateAndMerge runs the fixture system through Generate and merges
+// the result with the fixture Nix document, comparing the outcome with
+// testdata/integration/golden.spdx.json. Run with -update to rewrite it
+// after an intended change.
+func TestGenerateAndMerge(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+
+	g := NewGenerator(true, false)
+	g.Runner = newFixtureRunner(t)
+	g.hostRoot = filepath.Join(integrationDir, "root")
+	g.EmitFiles = true
+
+	ubuntuDoc, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spdx.CheckConsistency(ubuntuDoc); err != nil {
+		t.Errorf("Ubuntu document: %v", err)
+	}
+
+	ubuntuPath := filepath.Join(t.TempDir(), "ubuntu.spdx.json")
+	if err := spdx.SaveDocument(ubuntuDoc, ubuntuPath); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := merge.NewMerger().Merge(ubuntuPath, filepath.Join(integrationDir, "nix.spdx.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spdx.CheckConsistency(merged); err != nil {
+		t.Errorf("merged document: %v", err)
+	}
+	for _, violation := range spdx.Validate(merged) {
+		t.Errorf("merged document: %s", violation)
+	}
+
+	got := normalizeDocument(t, merged)
+	goldenPath := filepath.Join(integrationDir, "golden.spdx.json")
+	if *update {
+		if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("merged document differs from %s; rerun with -update and review the diff:\n%s", goldenPath, lineDiff(string(want), string(got)))
+	}
+}
+
+// lineDiff lists the lines of want and got from the first that differs,
+// enough context to find a golden file mismatch
+func lineDiff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		if i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i] {
+			continue
+		}
+		var b strings.Builder
+		for j := i; j < i+5; j++ {
+			if j < len(wantLines) {
+				b.WriteString("- " + wantLines[j] + "\n")
+			}
+		}
+		for j := i; j < i+5; j++ {
+			if j < len(gotLines) {
+				b.WriteString("+ " + gotLines[j] + "\n")
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package ubuntu
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// KernelModule is a loaded module of the running kernel as reported by
+// lsmod and modinfo
+type KernelModule struct {
+	Name     string
+	Version  string
+	License  string
+	Filename string
+	Depends  []string
+	Firmware []string
+}
+
+// moduleLicenses maps MODULE_LICENSE strings to SPDX identifiers. The
+// kernel's bare "GPL" means GPL version 2.
+var moduleLicenses = map[string]string{
+	"GPL":                       "GPL-2.0-only",
+	"GPL v2":                    "GPL-2.0-only",
+	"GPL and additional rights": "GPL-2.0-only",
+	"Dual BSD/GPL":              "BSD-3-Clause OR GPL-2.0-only",
+	"Dual MIT/GPL":              "MIT OR GPL-2.0-only",
+	"Dual MPL/GPL":              "MPL-1.1 OR GPL-2.0-only",
+}
+
+// getLoadedModules enumerates the running kernel's loaded modules. It
+// returns an error if lsmod or modinfo are unavailable.
+func (g *Generator) getLoadedModules() ([]KernelModule, error) {
+	if _, err := g.Runner.Output("modinfo", "--version"); err != nil {
+		return nil, err
+	}
+
+	output, err := g.Runner.Output("lsmod")
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []KernelModule
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	first := true
+	for scanner.Scan() {
+		// Skip the "Module Size Used by" header
+		if first {
+			first = false
+			continue
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		module, err := g.modinfo(fields[0])
+		if err != nil {
+			logging.Warnf("modinfo %s failed: %v", fields[0], err)
+			continue
+		}
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+func (g *Generator) modinfo(name string) (KernelModule, error) {
+	module := KernelModule{Name: name}
+
+	output, err := g.Runner.Output("modinfo", name)
+	if err != nil {
+		return module, err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "filename":
+			module.Filename = value
+		case "version":
+			module.Version = value
+		case "license":
+			module.License = value
+		case "depends":
+			for _, dep := range strings.Split(value, ",") {
+				if dep = strings.TrimSpace(dep); dep != "" {
+					module.Depends = append(module.Depends, dep)
+				}
+			}
+		case "firmware":
+			module.Firmware = append(module.Firmware, value)
+		}
+	}
+
+	return module, nil
+}
+
+// kernelModulesToSPDX converts loaded modules into packages contained by
+// rootID, with DEPENDS_ON relationships between modules and firmware
+// requirements recorded as annotations
+func (g *Generator) kernelModulesToSPDX(modules []KernelModule, rootID string) ([]spdx.Package, []spdx.Relationship) {
+	ids := make(map[string]string)
+	for _, module := range modules {
+		ids[module.Name] = fmt.Sprintf("SPDXRef-Ubuntu-KernelModule-%s", sanitizeName(module.Name))
+	}
+
+	var packages []spdx.Package
+	var relationships []spdx.Relationship
+
+	for _, module := range modules {
+		license, ok := moduleLicenses[module.License]
+		if !ok {
+			license = "NOASSERTION"
+		}
+
+		pkg := spdx.Package{
+			SPDXID:           ids[module.Name],
+			Name:             module.Name,
+			PackageVersion:   module.Version,
+			DownloadLocation: "NOASSERTION",
+			FilesAnalyzed:    false,
+			LicenseConcluded: license,
+			LicenseDeclared:  license,
+			CopyrightText:    "NOASSERTION",
+			Description:      fmt.Sprintf("Loaded kernel module %s", module.Filename),
+		}
+
+		for _, firmware := range module.Firmware {
+			pkg.Annotations = append(pkg.Annotations, g.annotation(fmt.Sprintf("firmware: %s", firmware)))
+		}
+
+		packages = append(packages, pkg)
+
+		relationships = append(relationships, spdx.Relationship{
+			SPDXElementID:      rootID,
+			RelatedSPDXElement: pkg.SPDXID,
+			RelationshipType:   "CONTAINS",
+		})
+
+		for _, dep := range module.Depends {
+			// Dependencies on modules that aren't loaded have no element
+			depID, ok := ids[dep]
+			if !ok {
+				continue
+			}
+			relationships = append(relationships, spdx.Relationship{
+				SPDXElementID:      pkg.SPDXID,
+				RelatedSPDXElement: depID,
+				RelationshipType:   "DEPENDS_ON",
+			})
+		}
+	}
+
+	return packages, relationships
+}
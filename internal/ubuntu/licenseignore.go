@@ -0,0 +1,76 @@
+package ubuntu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// licenseIgnoreList holds raw License: values that always normalize to
+// NOASSERTION, loaded from a --license-ignore file
+type licenseIgnoreList struct {
+	exact    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// loadLicenseIgnore reads an ignore file. Each non-empty, non-comment line
+// is either a raw license string (matched case-insensitively) or, when
+// prefixed with "re:", a regular expression matched against the raw value.
+func loadLicenseIgnore(path string) (*licenseIgnoreList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &licenseIgnoreList{exact: make(map[string]bool)}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if expr, ok := strings.CutPrefix(line, "re:"); ok {
+			re, err := regexp.Compile(strings.TrimSpace(expr))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid regular expression: %w", path, lineNum, err)
+			}
+			list.patterns = append(list.patterns, re)
+			continue
+		}
+
+		list.exact[strings.ToLower(line)] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// matches reports whether a raw license value should be ignored
+func (l *licenseIgnoreList) matches(license string) bool {
+	if l == nil {
+		return false
+	}
+
+	license = strings.TrimSpace(license)
+	if l.exact[strings.ToLower(license)] {
+		return true
+	}
+
+	for _, re := range l.patterns {
+		if re.MatchString(license) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package ubuntu
+
+import (
+	"sync"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+)
+
+// copyrightCache holds the parsed copyright files by path. Multiarch
+// instances of a package share one doc directory and may be looked up by
+// different workers at once; each file is still read only once.
+type copyrightCache struct {
+	mu      sync.Mutex
+	entries map[string]*copyrightEntry
+}
+
+type copyrightEntry struct {
+	once sync.Once
+	info copyrightInfo
+}
+
+func newCopyrightCache() *copyrightCache {
+	return &copyrightCache{entries: make(map[string]*copyrightEntry)}
+}
+
+// get returns the parsed copyright file at path, calling read the first
+// time the path is asked for
+func (c *copyrightCache) get(path string, read func(string) copyrightInfo) copyrightInfo {
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	if !ok {
+		entry = &copyrightEntry{}
+		c.entries[path] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() { entry.info = read(path) })
+	return entry.info
+}
+
+// readLicenses fills in the license and copyright details of every
+// package on a pool of Jobs workers. Reading and parsing thousands of
+// copyright files dominates generation on large systems, so it is done
+// after the package list is complete rather than while dpkg output is
+// parsed. Packages are updated in place and keep their order.
+func (g *Generator) readLicenses(packages []DpkgPackage) {
+	jobs := g.Jobs
+	if jobs <= 0 {
+		jobs = 1
+	}
+
+	if g.ShowProgress {
+		logging.Infof("Reading copyright files of %d packages with %d workers...", len(packages), jobs)
+	}
+
+	bar := g.progress("Reading copyright files", len(packages))
+	defer bar.Done()
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				g.getPackageLicense(&packages[i])
+				bar.Add(1)
+			}
+		}()
+	}
+
+	for i := range packages {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
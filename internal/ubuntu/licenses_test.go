@@ -0,0 +1,60 @@
+package ubuntu
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCopyrightCacheReadsOnce(t *testing.T) {
+	cache := newCopyrightCache()
+	var reads atomic.Int32
+	read := func(path string) copyrightInfo {
+		reads.Add(1)
+		return copyrightInfo{license: "MIT", copyright: path}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if info := cache.get("/usr/share/doc/libc6/copyright", read); info.copyright != "/usr/share/doc/libc6/copyright" {
+				t.Errorf("got %+v", info)
+			}
+		}()
+	}
+	wg.Wait()
+	cache.get("/usr/share/doc/zlib1g/copyright", read)
+
+	if n := reads.Load(); n != 2 {
+		t.Errorf("read %d copyright files, want 2", n)
+	}
+}
+
+func TestReadLicensesMultiarch(t *testing.T) {
+	g := NewGenerator(false, false)
+	g.hostRoot = "testdata/integration/root"
+	g.Jobs = 2
+	g.files = newFileLimiter(0)
+	g.copyrights = newCopyrightCache()
+
+	packages := []DpkgPackage{
+		{Name: "libc6", Version: "2.35-0ubuntu3.8", Architecture: "amd64"},
+		{Name: "libc6", Version: "2.35-0ubuntu3.8", Architecture: "i386"},
+		{Name: "libc6:i386", Version: "2.35-0ubuntu3.8", Architecture: "i386"},
+	}
+	g.readLicenses(packages)
+
+	if n := len(g.copyrights.entries); n != 1 {
+		t.Errorf("read %d copyright files for libc6, want 1", n)
+	}
+	for _, pkg := range packages {
+		if pkg.License != packages[0].License {
+			t.Errorf("%s:%s has license %q, want %q", pkg.Name, pkg.Architecture, pkg.License, packages[0].License)
+		}
+		if pkg.Copyright == "NOASSERTION" || pkg.Copyright != packages[0].Copyright {
+			t.Errorf("%s:%s has copyright %q, want %q", pkg.Name, pkg.Architecture, pkg.Copyright, packages[0].Copyright)
+		}
+	}
+}
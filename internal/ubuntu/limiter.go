@@ -0,0 +1,23 @@
+package ubuntu
+
+// defaultMaxOpenFiles keeps well under the common `ulimit -n` of 1024
+const defaultMaxOpenFiles = 64
+
+// fileLimiter is a semaphore bounding how many files the generator holds
+// open at once across license reading and file hashing
+type fileLimiter chan struct{}
+
+func newFileLimiter(limit int) fileLimiter {
+	if limit <= 0 {
+		limit = defaultMaxOpenFiles
+	}
+	return make(fileLimiter, limit)
+}
+
+func (l fileLimiter) acquire() {
+	l <- struct{}{}
+}
+
+func (l fileLimiter) release() {
+	<-l
+}
@@ -0,0 +1,85 @@
+package ubuntu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// UntrustedMaintainer is the annotation comment on packages whose
+// maintainer is not in the TrustedMaintainersFile domains
+const UntrustedMaintainer = "untrusted-maintainer"
+
+// maintainerAllowlist holds the email domains of trusted maintainers,
+// loaded from a --trusted-maintainers file
+type maintainerAllowlist struct {
+	domains map[string]bool
+}
+
+// loadTrustedMaintainers reads an allowlist file. Each non-empty,
+// non-comment line is an email domain ("ubuntu.com", or "@ubuntu.com"),
+// which also trusts its subdomains, such as lists.ubuntu.com.
+func loadTrustedMaintainers(path string) (*maintainerAllowlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &maintainerAllowlist{domains: make(map[string]bool)}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domain := strings.ToLower(strings.TrimPrefix(line, "@"))
+		if strings.ContainsAny(domain, "@<> \t") {
+			return nil, fmt.Errorf("%s:%d: %q is not an email domain", path, lineNum, line)
+		}
+		list.domains[domain] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// trusts reports whether a Maintainer field ("Name <user@domain>") has an
+// email address in a trusted domain. Maintainers without an address are
+// not trusted.
+func (l *maintainerAllowlist) trusts(maintainer string) bool {
+	domain := maintainerDomain(maintainer)
+	for domain != "" {
+		if l.domains[domain] {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		domain = parent
+	}
+	return false
+}
+
+// maintainerDomain returns the lowercased domain of the email address in
+// a Maintainer field, "" when there is none
+func maintainerDomain(maintainer string) string {
+	address := maintainer
+	if start := strings.LastIndex(maintainer, "<"); start >= 0 {
+		address, _, _ = strings.Cut(maintainer[start+1:], ">")
+	}
+	_, domain, ok := strings.Cut(strings.TrimSpace(address), "@")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(domain))
+}
@@ -0,0 +1,136 @@
+package ubuntu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const aptListsDir = "/var/lib/apt/lists"
+
+// Package origin classifications
+const (
+	OriginOfficial   = "official"
+	OriginThirdParty = "third-party"
+	OriginUnknown    = "unknown"
+)
+
+// officialOrigins are the Release file Origin values of the distribution
+// archives themselves; anything else (PPAs, vendor repositories) is
+// considered third-party
+var officialOrigins = map[string]bool{
+	"Ubuntu": true,
+	"Debian": true,
+}
+
+// loadAptOrigins maps every package version listed in the local apt lists
+// to the Origin of the repository that ships it. When a version is offered
+// by several repositories an official origin wins. The map is keyed by
+// aptKey.
+func (g *Generator) loadAptOrigins(listsDir string) (map[string]string, error) {
+	entries, err := os.ReadDir(listsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	origins := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		idx := strings.Index(name, "_Packages")
+		if idx == -1 || !strings.Contains(name, "_dists_") {
+			continue
+		}
+
+		origin := releaseOrigin(listsDir, name)
+		if origin == "" {
+			continue
+		}
+
+		data, err := g.readAptList(filepath.Join(listsDir, name))
+		if err != nil {
+			continue
+		}
+
+		readStanzas(bytes.NewReader(data), func(fields map[string]string) {
+			key := aptKey(fields["Package"], fields["Version"], fields["Architecture"])
+			if existing, ok := origins[key]; ok && officialOrigins[existing] {
+				return
+			}
+			origins[key] = origin
+		})
+	}
+
+	return origins, nil
+}
+
+// releaseOrigin finds the Release file for a Packages list and returns its
+// Origin field. List files are named <mirror>_dists_<suite>_<component>_...
+// and their Release file is <mirror>_dists_<suite>_InRelease (or _Release).
+func releaseOrigin(listsDir, packagesFile string) string {
+	distsIdx := strings.Index(packagesFile, "_dists_")
+	rest := packagesFile[distsIdx+len("_dists_"):]
+	suiteEnd := strings.Index(rest, "_")
+	if suiteEnd == -1 {
+		return ""
+	}
+	prefix := packagesFile[:distsIdx+len("_dists_")+suiteEnd]
+
+	for _, suffix := range []string{"_InRelease", "_Release"} {
+		data, err := os.ReadFile(filepath.Join(listsDir, prefix+suffix))
+		if err != nil {
+			continue
+		}
+
+		origin := ""
+		readStanzas(bytes.NewReader(data), func(fields map[string]string) {
+			if origin == "" {
+				origin = fields["Origin"]
+			}
+		})
+		if origin != "" {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// readAptList returns the contents of an apt list file. apt may store
+// lists compressed with lz4/xz/zstd, which we decompress via apt-helper.
+func (g *Generator) readAptList(path string) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(path, "_Packages"):
+		return os.ReadFile(path)
+	case strings.HasSuffix(path, ".gz"):
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		return io.ReadAll(reader)
+	default:
+		return g.Runner.Output("/usr/lib/apt/apt-helper", "cat-file", path)
+	}
+}
+
+// classifyOrigin returns the trust classification for an apt Origin value
+func classifyOrigin(origin string) string {
+	switch {
+	case origin == "":
+		return OriginUnknown
+	case officialOrigins[origin]:
+		return OriginOfficial
+	default:
+		return OriginThirdParty
+	}
+}
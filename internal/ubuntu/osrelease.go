@@ -0,0 +1,64 @@
+package ubuntu
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// readOSRelease parses an os-release(5) file into its key/value pairs.
+// A missing or unreadable file yields an empty map.
+func readOSRelease(path string) map[string]string {
+	values := make(map[string]string)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+
+	return values
+}
+
+// distroQualifier returns the purl distro qualifier for an os-release,
+// e.g. ubuntu-22.04, using the codename when there is no VERSION_ID
+// (Debian testing/unstable). It is "" when the release is unknown.
+func distroQualifier(osRelease map[string]string) string {
+	id := strings.ToLower(osRelease["ID"])
+	if id == "" {
+		return ""
+	}
+	if version := osRelease["VERSION_ID"]; version != "" {
+		return id + "-" + version
+	}
+	if codename := osRelease["VERSION_CODENAME"]; codename != "" {
+		return id + "-" + codename
+	}
+	return ""
+}
+
+// releasePurl returns a generic purl identifying the release, e.g.
+// pkg:generic/ubuntu@22.04, or "" when the release is unknown
+func releasePurl(osRelease map[string]string) string {
+	id := strings.ToLower(osRelease["ID"])
+	version := osRelease["VERSION_ID"]
+	if id == "" || version == "" {
+		return ""
+	}
+	return "pkg:generic/" + url.PathEscape(id) + "@" + url.PathEscape(version)
+}
@@ -0,0 +1,49 @@
+package ubuntu
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// maxDeniedSamples is how many unreadable paths are kept for the report
+const maxDeniedSamples = 5
+
+// permissionLog records files that exist but could not be read, so that
+// "we couldn't read it" can be told apart from "it isn't there"
+type permissionLog struct {
+	mu      sync.Mutex
+	count   int
+	samples []string
+}
+
+// record notes path if err is a permission error and reports whether it was
+func (p *permissionLog) record(path string, err error) bool {
+	if !errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.count++
+	if len(p.samples) < maxDeniedSamples {
+		p.samples = append(p.samples, path)
+	}
+	return true
+}
+
+// err summarizes the denied reads, or returns nil if there were none
+func (p *permissionLog) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.count == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d files could not be read due to insufficient permissions (e.g. %s); run as root for complete license and checksum data",
+		p.count, strings.Join(p.samples, ", "))
+}
@@ -0,0 +1,69 @@
+package ubuntu
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sectionPurposes maps Debian archive sections to SPDX primary package
+// purposes. Sections that mix kinds of software (misc, doc, fonts, ...)
+// are left out so the purpose is omitted rather than guessed.
+var sectionPurposes = map[string]string{
+	"libs":         "LIBRARY",
+	"libdevel":     "LIBRARY",
+	"oldlibs":      "LIBRARY",
+	"golang":       "LIBRARY",
+	"java":         "LIBRARY",
+	"javascript":   "LIBRARY",
+	"perl":         "LIBRARY",
+	"python":       "LIBRARY",
+	"ruby":         "LIBRARY",
+	"rust":         "LIBRARY",
+	"admin":        "APPLICATION",
+	"database":     "APPLICATION",
+	"devel":        "APPLICATION",
+	"editors":      "APPLICATION",
+	"games":        "APPLICATION",
+	"graphics":     "APPLICATION",
+	"httpd":        "APPLICATION",
+	"interpreters": "APPLICATION",
+	"mail":         "APPLICATION",
+	"net":          "APPLICATION",
+	"shells":       "APPLICATION",
+	"sound":        "APPLICATION",
+	"text":         "APPLICATION",
+	"utils":        "APPLICATION",
+	"vcs":          "APPLICATION",
+	"video":        "APPLICATION",
+	"web":          "APPLICATION",
+	"x11":          "APPLICATION",
+	"kernel":       "OPERATING-SYSTEM",
+}
+
+// primaryPurpose classifies a package for the SPDX primaryPackagePurpose
+// field from its name and dpkg Section, returning "" when unknown
+func primaryPurpose(pkg DpkgPackage) string {
+	switch {
+	case strings.HasPrefix(pkg.Name, "linux-image-"):
+		return "OPERATING-SYSTEM"
+	case pkg.Name == "linux-firmware" || strings.HasSuffix(pkg.Name, "-firmware"):
+		return "FIRMWARE"
+	}
+
+	// Sections outside main carry the archive area, e.g. universe/libs
+	section := pkg.Section
+	if i := strings.LastIndex(section, "/"); i >= 0 {
+		section = section[i+1:]
+	}
+	return sectionPurposes[section]
+}
+
+// kernelImagePattern matches versioned kernel image packages such as
+// linux-image-6.8.0-45-generic, but not metapackages like
+// linux-image-generic that only pull one in
+var kernelImagePattern = regexp.MustCompile(`^linux-image-(unsigned-)?[0-9]`)
+
+// isKernelImage reports whether a package installs a bootable kernel
+func isKernelImage(name string) bool {
+	return kernelImagePattern.MatchString(name)
+}
@@ -0,0 +1,17 @@
+package ubuntu
+
+import "os/exec"
+
+// CommandRunner runs an external command and returns its standard output.
+// Generator routes every dpkg/apt/kernel tool invocation through one so
+// the commands can be substituted, e.g. with fixture output.
+type CommandRunner interface {
+	Output(name string, args ...string) ([]byte, error)
+}
+
+// execRunner runs commands on the host
+type execRunner struct{}
+
+func (execRunner) Output(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
@@ -0,0 +1,30 @@
+package ubuntu
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// fakeRunner answers commands from fixed output keyed by the command line,
+// recording the commands it was asked to run. Unknown commands fail like
+// a missing tool would.
+type fakeRunner struct {
+	outputs map[string]string
+
+	mu   sync.Mutex
+	runs []string
+}
+
+func (r *fakeRunner) Output(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	r.mu.Lock()
+	r.runs = append(r.runs, command)
+	r.mu.Unlock()
+
+	output, ok := r.outputs[command]
+	if !ok {
+		return nil, fmt.Errorf("%s: not in fixture", command)
+	}
+	return []byte(output), nil
+}
@@ -0,0 +1,75 @@
+package ubuntu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+)
+
+// readSelections builds the package list from a `dpkg --get-selections`
+// capture. Only the package names and selection states are available, so
+// everything that requires the files to be present is left as NOASSERTION.
+func (g *Generator) readSelections(path string) ([]DpkgPackage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var packages []DpkgPackage
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"<package> <state>\", got %q", path, lineNum, line)
+		}
+
+		// Only install and hold selections describe packages present on the system
+		name, arch := fields[0], ""
+		if idx := strings.Index(name, ":"); idx != -1 {
+			name, arch = name[:idx], name[idx+1:]
+		}
+
+		state := fields[1]
+		if state != "install" && state != "hold" {
+			g.skip(SkippedPackage{
+				Name:         name,
+				Architecture: arch,
+				Reason:       SkipStatus,
+				Detail:       state,
+			})
+			continue
+		}
+
+		pkg := DpkgPackage{
+			Name:         name,
+			Architecture: arch,
+			Status:       state,
+			Source:       name,
+			License:      "NOASSERTION",
+			Copyright:    "NOASSERTION",
+		}
+		if g.filterOut(pkg) {
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	logging.Infof("Found %d selected packages in %s", len(packages), path)
+	return packages, nil
+}
@@ -0,0 +1,69 @@
+package ubuntu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/logging"
+)
+
+// dpkgInfoDir holds the file lists dpkg writes when it unpacks a package
+const dpkgInfoDir = "/var/lib/dpkg/info"
+
+// installTime returns when a package was last installed or upgraded:
+// the modification time of its dpkg file list, which is rewritten every
+// time the package is unpacked. ok is false when there is no list, and
+// for packages read from a selections capture, which need not describe
+// this system.
+func (g *Generator) installTime(pkg DpkgPackage) (time.Time, bool) {
+	if g.SelectionsFile != "" {
+		return time.Time{}, false
+	}
+
+	infoDir := g.rootPath(dpkgInfoDir)
+	for _, name := range packageListNames(pkg) {
+		if info, err := os.Stat(filepath.Join(infoDir, name)); err == nil {
+			return info.ModTime(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// filterSince drops packages installed before Since, and those whose
+// install time is unknown when StrictSince is set
+func (g *Generator) filterSince(packages []DpkgPackage) []DpkgPackage {
+	var kept []DpkgPackage
+	unknown := 0
+	for _, pkg := range packages {
+		installed, ok := g.installTime(pkg)
+		detail := ""
+		switch {
+		case !ok && g.StrictSince:
+			detail = "install time unknown, excluded by --strict-since"
+		case !ok:
+			unknown++
+		case installed.Before(g.Since):
+			detail = fmt.Sprintf("installed %s, before --since", installed.UTC().Format(time.RFC3339))
+		}
+
+		if detail != "" {
+			g.skip(SkippedPackage{
+				Name:         pkg.Name,
+				Version:      pkg.Version,
+				Architecture: pkg.Architecture,
+				Reason:       SkipFiltered,
+				Detail:       detail,
+			})
+			continue
+		}
+		kept = append(kept, pkg)
+	}
+
+	logging.Infof("Kept %d packages installed since %s", len(kept), g.Since.Format(time.RFC3339))
+	if unknown > 0 {
+		logging.Warnf("%d packages have no known install time and were kept; use --strict-since to exclude them", unknown)
+	}
+	return kept
+}
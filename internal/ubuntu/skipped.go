@@ -0,0 +1,49 @@
+package ubuntu
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// Reasons a package can be enumerated but left out of the SBOM
+const (
+	SkipStatus     = "status"
+	SkipFiltered   = "filtered"
+	SkipParseError = "parse-error"
+)
+
+// SkippedPackage describes a package that was enumerated but not included
+type SkippedPackage struct {
+	Name         string `json:"name"`
+	Version      string `json:"version,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	Reason       string `json:"reason"`
+	Detail       string `json:"detail,omitempty"`
+}
+
+// skip records a package that is being left out of the SBOM
+func (g *Generator) skip(pkg SkippedPackage) {
+	g.skipped = append(g.skipped, pkg)
+}
+
+// Skipped returns every package dropped during the last Generate, in
+// enumeration order
+func (g *Generator) Skipped() []SkippedPackage {
+	return g.skipped
+}
+
+// SaveSkippedReport writes the skipped packages as a JSON array
+func (g *Generator) SaveSkippedReport(outputPath string) error {
+	skipped := g.skipped
+	if skipped == nil {
+		skipped = []SkippedPackage{}
+	}
+
+	return spdx.WriteFileAtomic(outputPath, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(skipped)
+	})
+}
@@ -0,0 +1,55 @@
+package ubuntu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// parseSource interprets a dpkg Source field, which is empty when the
+// source package has the binary's name, a bare name, or "name (version)"
+// when the source version differs from the binary version
+func parseSource(field, name, version string) (string, string) {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return name, version
+	}
+
+	source, sourceVersion, ok := strings.Cut(field, "(")
+	if !ok {
+		return field, version
+	}
+	return strings.TrimSpace(source), strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(sourceVersion), ")"))
+}
+
+// sourceExternalRef points at the source package the binary was built
+// from, using the purl form for Debian source packages (arch=source)
+func sourceExternalRef(pkg DpkgPackage, distro string) spdx.ExternalRef {
+	locator := fmt.Sprintf("pkg:deb/ubuntu/%s", pkg.Source)
+	if pkg.SourceVersion != "" {
+		locator += "@" + pkg.SourceVersion
+	}
+	locator += "?arch=source"
+	if distro != "" {
+		locator += "&distro=" + distro
+	}
+
+	return spdx.ExternalRef{
+		Category: "OTHER",
+		Type:     "deb-source",
+		Locator:  locator,
+	}
+}
+
+// upstreamQualifier returns the purl upstream qualifier value for packages
+// built from a differently named or versioned source, or "" otherwise
+func upstreamQualifier(pkg DpkgPackage) string {
+	if pkg.Source == "" || (pkg.Source == pkg.Name && pkg.SourceVersion == pkg.Version) {
+		return ""
+	}
+	if pkg.SourceVersion != "" && pkg.SourceVersion != pkg.Version {
+		return pkg.Source + "%40" + pkg.SourceVersion
+	}
+	return pkg.Source
+}
@@ -0,0 +1,24 @@
+package ubuntu
+
+import (
+	"fmt"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// swidTagID derives a deterministic SWID tag ID (a version 5 UUID) from
+// the package supplier, name, and version, so the same package always gets
+// the same tag across runs and hosts
+func swidTagID(pkg DpkgPackage) string {
+	return spdx.NameUUID(fmt.Sprintf("swid:deb/%s/%s@%s", pkg.Maintainer, pkg.Name, pkg.Version))
+}
+
+// swidExternalRef returns the SWID external reference for a package. SPDX
+// 2.3 files the swid reference type under the SECURITY category.
+func swidExternalRef(pkg DpkgPackage) spdx.ExternalRef {
+	return spdx.ExternalRef{
+		Category: "SECURITY",
+		Type:     "swid",
+		Locator:  "swid:" + swidTagID(pkg),
+	}
+}
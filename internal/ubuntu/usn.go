@@ -0,0 +1,92 @@
+package ubuntu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// usnNotice is the subset of an Ubuntu Security Notice entry from the
+// usn-db database.json that we need to map notices onto binary packages
+type usnNotice struct {
+	ID       string `json:"id"`
+	Releases map[string]struct {
+		Binaries map[string]struct {
+			Version string `json:"version"`
+		} `json:"binaries"`
+	} `json:"releases"`
+}
+
+// loadUSNAdvisories reads a local USN database and returns, for each
+// installed binary package, the IDs of the notices it is still affected
+// by: those fixing it in a version newer than the installed one, given in
+// installed by package name. A notice that names no fixed version is
+// kept, as the package cannot be shown to be fixed. When codename is set,
+// only notices for that release are considered.
+func loadUSNAdvisories(path, codename string, installed map[string]string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var notices map[string]usnNotice
+	if err := json.Unmarshal(data, &notices); err != nil {
+		return nil, fmt.Errorf("failed to parse USN database %s: %w", path, err)
+	}
+
+	advisories := make(map[string][]string)
+	for key, notice := range notices {
+		id := notice.ID
+		if id == "" {
+			id = key
+		}
+		id = strings.TrimPrefix(id, "USN-")
+
+		for release, data := range notice.Releases {
+			if codename != "" && release != codename {
+				continue
+			}
+			for binary, fixed := range data.Binaries {
+				version, ok := installed[binary]
+				if !ok || (fixed.Version != "" && compareVersions(version, fixed.Version) >= 0) {
+					continue
+				}
+				advisories[binary] = append(advisories[binary], id)
+			}
+		}
+	}
+
+	for binary, ids := range advisories {
+		sort.Strings(ids)
+		advisories[binary] = dedupeSorted(ids)
+	}
+
+	return advisories, nil
+}
+
+// usnExternalRefs returns SECURITY/advisory references for the given notice IDs
+func usnExternalRefs(ids []string) []spdx.ExternalRef {
+	refs := make([]spdx.ExternalRef, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, spdx.ExternalRef{
+			Category: "SECURITY",
+			Type:     "advisory",
+			Locator:  fmt.Sprintf("https://ubuntu.com/security/notices/USN-%s", id),
+		})
+	}
+	return refs
+}
+
+func dedupeSorted(values []string) []string {
+	result := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package ubuntu
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const usnDatabase = `{
+  "6000-1": {
+    "id": "USN-6000-1",
+    "releases": {
+      "jammy": {"binaries": {
+        "openssl": {"version": "3.0.2-0ubuntu1.9"},
+        "libssl3": {"version": "3.0.2-0ubuntu1.9"}
+      }},
+      "focal": {"binaries": {"openssl": {"version": "1.1.1f-1ubuntu2.18"}}}
+    }
+  },
+  "6500-1": {
+    "id": "USN-6500-1",
+    "releases": {
+      "jammy": {"binaries": {"openssl": {"version": "3.0.2-0ubuntu1.12"}}}
+    }
+  },
+  "6600-1": {
+    "releases": {
+      "jammy": {"binaries": {
+        "bash": {"version": "5.1-6ubuntu1.1"},
+        "zlib1g": {"version": "1:1.2.11.dfsg-2ubuntu9.3"},
+        "curl": {"version": "7.81.0-1ubuntu1.15"}
+      }}
+    }
+  },
+  "6700-1": {
+    "id": "USN-6700-1",
+    "releases": {
+      "jammy": {"binaries": {"zlib1g": {}}}
+    }
+  }
+}`
+
+func TestLoadUSNAdvisories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	if err := os.WriteFile(path, []byte(usnDatabase), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	installed := map[string]string{
+		"openssl": "3.0.2-0ubuntu1.10",
+		"libssl3": "3.0.2-0ubuntu1.10",
+		"bash":    "5.1-6ubuntu1.1",
+		"zlib1g":  "1:1.2.11.dfsg-2ubuntu9.2",
+	}
+	advisories, err := loadUSNAdvisories(path, "jammy", installed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// openssl and libssl3 are past the 6000-1 fix, bash is at the 6600-1
+	// fix, curl is not installed, and 6700-1 names no fixed version
+	want := map[string][]string{
+		"openssl": {"6500-1"},
+		"zlib1g":  {"6600-1", "6700-1"},
+	}
+	if !reflect.DeepEqual(advisories, want) {
+		t.Errorf("got %v, want %v", advisories, want)
+	}
+}
@@ -0,0 +1,113 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+)
+
+// maxErrorBody bounds how much of a failed response is included in errors
+const maxErrorBody = 4096
+
+// ProgressFunc is called as the request body is sent with the bytes sent
+// so far and the total size
+type ProgressFunc func(sent, total int64)
+
+// Uploader sends a generated SBOM to an HTTP endpoint
+type Uploader struct {
+	URL      string
+	APIKey   string
+	Timeout  time.Duration
+	Client   *http.Client
+	Progress ProgressFunc
+}
+
+func NewUploader(url, apiKey string, timeout time.Duration) *Uploader {
+	return &Uploader{
+		URL:     url,
+		APIKey:  apiKey,
+		Timeout: timeout,
+		Client:  http.DefaultClient,
+	}
+}
+
+// Upload POSTs the file at path as JSON. The request is cancelled when ctx
+// is done or the configured timeout elapses.
+func (u *Uploader) Upload(ctx context.Context, path string) error {
+	if u.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.Timeout)
+		defer cancel()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	body := &progressReader{reader: file, total: info.Size(), progress: u.Progress}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.URL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", urlError(err))
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/json")
+	if u.APIKey != "" {
+		req.Header.Set("X-Api-Key", u.APIKey)
+	}
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("upload to %s failed: %w", spdx.RedactURL(u.URL), urlError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("upload to %s failed: HTTP %s: %s", spdx.RedactURL(u.URL), resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// urlError drops the URL that net/http and net/url errors repeat, with
+// its query and credentials, keeping only the underlying error
+func urlError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// progressReader reports bytes read through it to a ProgressFunc
+type progressReader struct {
+	reader   io.Reader
+	sent     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.sent += int64(n)
+	if p.progress != nil && n > 0 {
+		p.progress(p.sent, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,56 @@
+// Package version identifies the build of the tool. Release builds set
+// both values with the linker:
+//
+//	go build -ldflags "-X github.com/ubuntu-nix-sbom/internal/version.Version=1.2.0 \
+//	  -X github.com/ubuntu-nix-sbom/internal/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+import "runtime/debug"
+
+var (
+	// Version is the release version, "dev" for local builds
+	Version = "dev"
+
+	// Commit is the git commit the tool was built from. When not set with
+	// -ldflags it is taken from the VCS information go build records.
+	Commit = ""
+)
+
+// shortCommitLength is the length commits are abbreviated to
+const shortCommitLength = 12
+
+// String returns the version with the commit as build metadata, e.g.
+// 1.2.0+3f2a9c1d8e4b, or the version alone when the commit is unknown
+func String() string {
+	commit := Commit
+	if commit == "" {
+		commit = buildCommit()
+	}
+	if len(commit) > shortCommitLength {
+		commit = commit[:shortCommitLength]
+	}
+	if commit == "" {
+		return Version
+	}
+	return Version + "+" + commit
+}
+
+// Tool returns the SPDX creator and annotator identifying this build
+func Tool() string {
+	return "Tool: ubuntu-nix-sbom-" + String()
+}
+
+// buildCommit returns the revision go build embedded when building from a
+// git checkout, or ""
+func buildCommit() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
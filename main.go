@@ -2,30 +2,35 @@ package main
 
 import (
 	"flag"
-	"fmt"
-	"log"
+	"os"
 
-	"github.com/ubuntu-nix-sbom/internal/ubuntu"
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/pkg/sbom"
 )
 
 func main() {
 	var (
 		outputFile   = flag.String("output", "ubuntu-sbom.spdx.json", "Output file path")
 		includeFiles = flag.Bool("include-files", false, "Include file checksums for each package")
-		progress     = flag.Bool("progress", true, "Show progress indicators")
+		progress     = flag.Bool("progress", true, "Show a progress bar when stderr is a terminal")
+		quiet        = flag.Bool("quiet", false, "Only log errors")
+		logJSON      = flag.Bool("log-json", false, "Log one JSON object per line")
 	)
 	flag.Parse()
 
-	generator := ubuntu.NewGenerator(*includeFiles, *progress)
+	logging.SetQuiet(*quiet)
+	logging.SetJSON(*logJSON)
 
-	doc, err := generator.Generate()
+	doc, err := sbom.GenerateUbuntu(sbom.Options{IncludeFiles: *includeFiles, ShowProgress: *progress})
 	if err != nil {
-		log.Fatalf("Failed to generate SBOM: %v", err)
+		logging.Fatalf("Failed to generate SBOM: %v", err)
 	}
+	doc.CreationInfo.Comment = spdx.CommandLineComment(os.Args)
 
-	if err := generator.Save(doc, *outputFile); err != nil {
-		log.Fatalf("Failed to save SBOM: %v", err)
+	if err := sbom.Save(doc, *outputFile, sbom.FormatSPDX); err != nil {
+		logging.Fatalf("Failed to save SBOM: %v", err)
 	}
 
-	fmt.Printf("Ubuntu SBOM generated successfully: %s\n", *outputFile)
+	logging.Infof("Ubuntu SBOM generated successfully: %s", *outputFile)
 }
@@ -0,0 +1,447 @@
+// Package sbom is the public entry point for generating, merging and saving
+// SBOMs from Go programs. The sbom and ubuntu-sbom commands are built on it.
+package sbom
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ubuntu-nix-sbom/internal/cyclonedx"
+	"github.com/ubuntu-nix-sbom/internal/flatpak"
+	"github.com/ubuntu-nix-sbom/internal/logging"
+	"github.com/ubuntu-nix-sbom/internal/merge"
+	"github.com/ubuntu-nix-sbom/internal/nix"
+	"github.com/ubuntu-nix-sbom/internal/python"
+	"github.com/ubuntu-nix-sbom/internal/snap"
+	"github.com/ubuntu-nix-sbom/internal/spdx"
+	"github.com/ubuntu-nix-sbom/internal/ubuntu"
+)
+
+// Document is an SPDX 2.3 document
+type Document = spdx.Document
+
+// Package and Relationship are the document elements SaveStream takes one
+// at a time
+type (
+	Package      = spdx.Package
+	Relationship = spdx.Relationship
+)
+
+// DecodeError reports why content read by Load or Decode is not an SPDX
+// document, locating malformed JSON by byte offset
+type DecodeError = spdx.DecodeError
+
+// Output formats accepted by Save
+const (
+	FormatSPDX      = "spdx"
+	FormatTagValue  = "tag-value"
+	FormatCycloneDX = "cyclonedx"
+)
+
+// SPDX versions a document can be saved as. Documents are generated as
+// SPDX 2.3; setting Document.SPDXVersion to SPDXVersion22 makes Save write
+// SPDX 2.2, without the fields 2.2 consumers reject.
+const (
+	SPDXVersion23 = spdx.Version23
+	SPDXVersion22 = spdx.Version22
+)
+
+// Options configures Ubuntu SBOM generation. The zero value describes the
+// host's installed packages with license and copyright information only.
+type Options struct {
+	// IncludeFiles hashes each package's files to record its verification
+	// code
+	IncludeFiles bool
+	// ShowProgress draws progress bars with the rate and time left when
+	// stderr is a terminal; otherwise, and without it, progress is logged
+	// every 100 packages
+	ShowProgress bool
+	// HashPaths limits hashing to files matching one of these globs
+	HashPaths []string
+	// EmitFiles adds an SPDX File element for every hashed file
+	EmitFiles bool
+	// ChecksumAlgorithms are the File element checksums: SHA1, SHA256
+	// and/or SHA512, with SHA1 always included (default: SHA1 and SHA256)
+	ChecksumAlgorithms []string
+	// Jobs is the number of workers reading copyright files and hashing
+	// package files (default: number of CPUs)
+	Jobs int
+	// MaxOpenFiles bounds the number of files held open at once (default:
+	// 64)
+	MaxOpenFiles int
+
+	// DpkgRoot describes the filesystem mounted there instead of the host
+	DpkgRoot string
+	// Image describes a container image, given as an unpacked root
+	// directory or an exported rootfs tar (optionally gzip compressed),
+	// without running the host's dpkg
+	Image string
+	// SelectionsFile builds the SBOM from a `dpkg --get-selections` capture
+	SelectionsFile string
+	// IncludePackages and ExcludePackages filter packages by name glob;
+	// exclusion wins
+	IncludePackages []string
+	ExcludePackages []string
+	// Architectures keeps only packages of these dpkg architectures, plus
+	// Architecture: all packages
+	Architectures []string
+	// ThirdPartyOnly keeps only packages not from the official archive
+	ThirdPartyOnly bool
+	// Since keeps only packages installed or upgraded at or after it, for
+	// delta SBOMs; those whose install time is unknown are kept unless
+	// StrictSince is set
+	Since       time.Time
+	StrictSince bool
+	// IncludeConfigFiles keeps removed packages whose configuration files
+	// remain (dpkg state config-files)
+	IncludeConfigFiles bool
+
+	// USNDatabase is a local usn-db database.json for advisory references
+	USNDatabase string
+	// DownloadSizes annotates packages with their .deb download sizes
+	DownloadSizes bool
+	// ResolveDownloadURLs sets download locations to the .deb URLs in the
+	// configured apt repositories
+	ResolveDownloadURLs bool
+	// AptOrigins annotates packages with their apt repository origin
+	AptOrigins bool
+	// KernelModules adds the running kernel's loaded modules
+	KernelModules bool
+	// DebugLinks relates packages to their debug symbols packages
+	DebugLinks bool
+	// SWID attaches a SWID tag ID reference to each package
+	SWID bool
+	// AllArchAs is the purl arch of Architecture: all packages, "all"
+	// (default) or "host"
+	AllArchAs string
+	// MaxCopyrightLength truncates copyright text to this many characters
+	// (default 200); a negative value keeps it whole
+	MaxCopyrightLength int
+	// LicenseIgnoreFile lists raw License: values that map to NOASSERTION
+	LicenseIgnoreFile string
+	// TrustedMaintainersFile lists trusted maintainer email domains, one
+	// per line; packages maintained from other domains are annotated with
+	// UntrustedMaintainer
+	TrustedMaintainersFile string
+	// RequireReadable fails generation on unreadable copyright or package
+	// files instead of warning
+	RequireReadable bool
+	// Strict fails generation when dpkg-query exits with an error instead
+	// of describing the packages it listed
+	Strict bool
+	// Reproducible makes the output depend only on the installed packages
+	Reproducible bool
+
+	// Supplement is a hand-maintained SPDX document whose packages are
+	// added as manually-declared software
+	Supplement string
+	// SkippedReport, when set, receives a JSON list of the packages left
+	// out of the SBOM
+	SkippedReport string
+}
+
+// GenerateUbuntu builds an SBOM of the Debian/Ubuntu packages installed on
+// the host, or under opts.DpkgRoot or opts.Image
+func GenerateUbuntu(opts Options) (*Document, error) {
+	cleanup, err := opts.openImage()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	generator := opts.generator()
+	doc, err := generator.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Supplement != "" {
+		if err := merge.NewMerger().Supplement(doc, opts.Supplement); err != nil {
+			return nil, fmt.Errorf("failed to add supplementary packages: %w", err)
+		}
+	}
+
+	if opts.SkippedReport != "" {
+		if err := generator.SaveSkippedReport(opts.SkippedReport); err != nil {
+			return nil, fmt.Errorf("failed to save skipped package report: %w", err)
+		}
+		logging.Infof("Skipped package report written: %s (%d packages)", opts.SkippedReport, len(generator.Skipped()))
+	}
+
+	return doc, nil
+}
+
+// UntrustedMaintainer is the annotation comment GenerateUbuntu adds to
+// packages whose maintainer is outside Options.TrustedMaintainersFile
+const UntrustedMaintainer = ubuntu.UntrustedMaintainer
+
+// Estimate is what GenerateUbuntu would process: the number of packages,
+// of packages skipped, and of files hashed with IncludeFiles
+type Estimate = ubuntu.Estimate
+
+// EstimateUbuntu enumerates the packages GenerateUbuntu would describe
+// with opts and, with IncludeFiles, counts the files that would be hashed,
+// without reading copyright files, hashing or building a document. The
+// supplement and skipped report are ignored.
+func EstimateUbuntu(opts Options) (*Estimate, error) {
+	cleanup, err := opts.openImage()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	return opts.generator().Estimate()
+}
+
+// openImage unpacks o.Image, if set, and points o.DpkgRoot at it. The
+// returned function removes the unpacked image.
+func (o *Options) openImage() (func(), error) {
+	if o.Image == "" {
+		return func() {}, nil
+	}
+	if o.DpkgRoot != "" || o.SelectionsFile != "" {
+		return nil, fmt.Errorf("an image cannot be combined with a dpkg root or selections file")
+	}
+	root, cleanup, err := ubuntu.OpenImage(o.Image, o.IncludeFiles || o.DebugLinks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	o.DpkgRoot = root
+	return cleanup, nil
+}
+
+func (o Options) generator() *ubuntu.Generator {
+	generator := ubuntu.NewGenerator(o.IncludeFiles, o.ShowProgress)
+	generator.HashPaths = o.HashPaths
+	generator.EmitFiles = o.EmitFiles
+	generator.ChecksumAlgorithms = o.ChecksumAlgorithms
+	if o.Jobs > 0 {
+		generator.Jobs = o.Jobs
+	}
+	if o.MaxOpenFiles > 0 {
+		generator.MaxOpenFiles = o.MaxOpenFiles
+	}
+	generator.DpkgRoot = o.DpkgRoot
+	generator.SelectionsFile = o.SelectionsFile
+	generator.IncludePackages = o.IncludePackages
+	generator.ExcludePackages = o.ExcludePackages
+	generator.Architectures = o.Architectures
+	generator.ThirdPartyOnly = o.ThirdPartyOnly
+	generator.Since = o.Since
+	generator.StrictSince = o.StrictSince
+	generator.IncludeConfigFiles = o.IncludeConfigFiles
+	generator.USNDatabase = o.USNDatabase
+	generator.DownloadSizes = o.DownloadSizes
+	generator.ResolveDownloadURLs = o.ResolveDownloadURLs
+	generator.AptOrigins = o.AptOrigins
+	generator.KernelModules = o.KernelModules
+	generator.DebugLinks = o.DebugLinks
+	generator.SWID = o.SWID
+	generator.AllArchAs = o.AllArchAs
+	generator.LicenseIgnoreFile = o.LicenseIgnoreFile
+	generator.TrustedMaintainersFile = o.TrustedMaintainersFile
+	if o.MaxCopyrightLength > 0 {
+		generator.MaxCopyrightLength = o.MaxCopyrightLength
+	} else if o.MaxCopyrightLength < 0 {
+		generator.MaxCopyrightLength = 0
+	}
+	generator.RequireReadable = o.RequireReadable
+	generator.Strict = o.Strict
+	generator.Reproducible = o.Reproducible || spdx.SourceDateEpochSet()
+	return generator
+}
+
+// NixOptions configures Nix SBOM generation
+type NixOptions struct {
+	// SbomnixPath is the sbomnix executable, looked up in PATH unless it
+	// contains a slash (default: sbomnix)
+	SbomnixPath string
+	// Retries reruns sbomnix this many times after transient failures,
+	// such as an unreachable Nix daemon, with exponential backoff
+	Retries int
+	// Timeout kills sbomnix when a run takes longer (default: no limit)
+	Timeout time.Duration
+}
+
+func (o NixOptions) wrapper() *nix.Wrapper {
+	path := o.SbomnixPath
+	if path == "" {
+		path = "sbomnix"
+	}
+	wrapper := nix.NewWrapper(path)
+	wrapper.Retries = o.Retries
+	wrapper.Timeout = o.Timeout
+	return wrapper
+}
+
+// GenerateNix builds an SBOM of a Nix derivation's closure with sbomnix
+// from PATH
+func GenerateNix(derivation string) (*Document, error) {
+	return GenerateNixWithOptions(derivation, NixOptions{})
+}
+
+// GenerateNixWithOptions is GenerateNix with a configurable sbomnix
+func GenerateNixWithOptions(derivation string, opts NixOptions) (*Document, error) {
+	return opts.wrapper().GenerateDocument(derivation)
+}
+
+// WriteNix runs sbomnix for derivation and stores its document unchanged
+// at outputPath ("-" for stdout)
+func WriteNix(derivation, outputPath string, opts NixOptions) error {
+	return opts.wrapper().Generate(derivation, outputPath)
+}
+
+// SnapOptions configures snap SBOM generation
+type SnapOptions struct {
+	// SnapPath is the snap executable, looked up in PATH unless it
+	// contains a slash (default: snap)
+	SnapPath string
+	// Reproducible fixes the creation time and derives the namespace from
+	// the snap set
+	Reproducible bool
+}
+
+// GenerateSnap builds an SBOM of the installed snaps. When snap is not
+// installed it warns and returns a document with no packages.
+func GenerateSnap(opts SnapOptions) (*Document, error) {
+	generator := snap.NewGenerator()
+	if opts.SnapPath != "" {
+		generator.SnapPath = opts.SnapPath
+	}
+	generator.Reproducible = opts.Reproducible
+	return generator.Generate()
+}
+
+// FlatpakOptions configures flatpak SBOM generation
+type FlatpakOptions struct {
+	// FlatpakPath is the flatpak executable, looked up in PATH unless it
+	// contains a slash (default: flatpak)
+	FlatpakPath string
+	// IncludeRuntimes also lists installed runtimes, not only applications
+	IncludeRuntimes bool
+	// Reproducible fixes the creation time and derives the namespace from
+	// the installed flatpaks
+	Reproducible bool
+}
+
+// GenerateFlatpak builds an SBOM of the installed flatpak applications.
+// When flatpak is not installed it warns and returns a document with no
+// packages.
+func GenerateFlatpak(opts FlatpakOptions) (*Document, error) {
+	generator := flatpak.NewGenerator()
+	if opts.FlatpakPath != "" {
+		generator.FlatpakPath = opts.FlatpakPath
+	}
+	generator.IncludeRuntimes = opts.IncludeRuntimes
+	generator.Reproducible = opts.Reproducible
+	return generator.Generate()
+}
+
+// PythonOptions configures Python SBOM generation
+type PythonOptions struct {
+	// Paths are the site-packages directories to scan (default: those
+	// reported by `python3 -m site`, except dpkg's)
+	Paths []string
+	// Reproducible fixes the creation time and derives the namespace from
+	// the installed distributions
+	Reproducible bool
+}
+
+// GeneratePython builds an SBOM of the Python distributions installed
+// outside of dpkg, such as by pip
+func GeneratePython(opts PythonOptions) (*Document, error) {
+	generator := python.NewGenerator()
+	generator.Paths = opts.Paths
+	generator.Reproducible = opts.Reproducible
+	return generator.Generate()
+}
+
+// Dedupe modes for packages present in Ubuntu and another source
+const (
+	// DedupeLink keeps both copies and relates them as equivalent
+	DedupeLink = merge.DedupeLink
+	// DedupeDrop keeps only the Ubuntu copy
+	DedupeDrop = merge.DedupeDrop
+	// DedupeOff disables duplicate detection
+	DedupeOff = merge.DedupeOff
+)
+
+// MergeOptions configures merging
+type MergeOptions struct {
+	// Dedupe is DedupeLink (default), DedupeDrop or DedupeOff
+	Dedupe string
+	// Reproducible fixes the creation time and derives the namespace from
+	// the merged packages
+	Reproducible bool
+	// Extras are SPDX JSON files from other tools, such as syft, merged
+	// after the documents. Their SPDXIDs are prefixed with a label derived
+	// from the tool (Syft, ...), the packages they describe are placed
+	// under the system root, and elements that cannot be mapped are
+	// skipped with a warning.
+	Extras []string
+}
+
+func (o MergeOptions) merger() *merge.Merger {
+	merger := merge.NewMerger()
+	if o.Dedupe != "" {
+		merger.Dedupe = o.Dedupe
+	}
+	merger.Reproducible = o.Reproducible || spdx.SourceDateEpochSet()
+	merger.Extras = o.Extras
+	return merger
+}
+
+// Merge combines documents under a single system root, with each source's
+// SPDXIDs prefixed by its label (Ubuntu, Nix, Nix2, ...)
+func Merge(docs ...*Document) (*Document, error) {
+	return MergeWithOptions(MergeOptions{}, docs...)
+}
+
+// MergeWithOptions is Merge with configurable options
+func MergeWithOptions(opts MergeOptions, docs ...*Document) (*Document, error) {
+	return opts.merger().MergeDocuments(docs...)
+}
+
+// MergeFiles merges the documents stored at paths. Unlike Merge, the
+// source checksums recorded in the result are those of the files as read.
+func MergeFiles(opts MergeOptions, paths ...string) (*Document, error) {
+	return opts.merger().MergeAll(paths...)
+}
+
+// Load reads an SPDX JSON document, optionally gzip or zstd compressed.
+// Content that is not an SPDX 2.2 or 2.3 document fails with a
+// *DecodeError.
+func Load(path string) (*Document, error) {
+	return spdx.Load(path)
+}
+
+// Decode reads an SPDX JSON document from r like Load
+func Decode(r io.Reader) (*Document, error) {
+	return spdx.Decode(r)
+}
+
+// Save writes doc to outputPath ("-" for stdout) in the given format:
+// FormatSPDX (default when empty), FormatTagValue or FormatCycloneDX
+func Save(doc *Document, outputPath, format string) error {
+	switch format {
+	case FormatSPDX, "":
+		return spdx.SaveDocument(doc, outputPath)
+	case FormatTagValue:
+		return spdx.SaveTagValue(doc, outputPath)
+	case FormatCycloneDX:
+		return cyclonedx.SaveDocument(doc, outputPath)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// SaveStream writes an SPDX JSON document to w while its packages and
+// relationships arrive on channels, encoding each as it comes, for
+// documents too large to hold in memory at once. meta supplies the other
+// fields. pkgs is read until closed, then rels: send every package before
+// the first relationship, or feed the channels from separate goroutines.
+// The output is the same as Save with FormatSPDX.
+func SaveStream(w io.Writer, pkgs <-chan Package, rels <-chan Relationship, meta Document) error {
+	return spdx.SaveStream(w, pkgs, rels, meta)
+}